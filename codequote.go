@@ -0,0 +1,822 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+func expandCodeQuotes(_ context.Context, pqs []*pullQuote) ([]*expanded, error) {
+	exp := make([]*expanded, 0, len(pqs))
+	for _, pq := range pqs {
+		pat, sym, hasSym := splitObjPath(pq.objPath) // no symbol quotes the whole file
+
+		s, err := func() (string, error) {
+			src, err := ioutil.ReadFile(pat)
+			if err != nil {
+				return "", err
+			}
+			b := bytes.TrimRight(src, "\r\n")
+			if hasSym {
+				if b, err = sprintBraceDecl(src, sym, braceLangs[strings.ToLower(pq.lang)]); err != nil {
+					return "", err
+				}
+			}
+			if pq.sub.isSet() {
+				if b, _, err = pq.sub.apply(b); err != nil {
+					return "", fmt.Errorf("selecting within %q: %w", sym, err)
+				}
+				b = dedentTabs(dedentSpaces(b))
+			}
+			return string(b), nil
+		}()
+		if err != nil {
+			return nil, fmt.Errorf("error within %v: %w", pat, err)
+		}
+		exp = append(exp, &expanded{String: s})
+	}
+	return exp, nil
+}
+
+// braceLang describes what it takes to tokenize a brace-delimited language well enough to find its declarations.
+type braceLang struct {
+	name string
+	// asi languages may end statements at line breaks
+	asi bool
+	// singleQuote is how `'` reads: 's' for strings, 'c' for character literals, or 'r' for Rust's characters and
+	// lifetimes
+	singleQuote byte
+	// templates are JavaScript's backtick strings, which may nest code
+	templates bool
+	// regexps are JavaScript's `/.../flags` literals
+	regexps bool
+	// textBlocks are `"""` strings
+	textBlocks bool
+	// nestedComments may contain other block comments
+	nestedComments bool
+	// preprocessor directives begin with `#` and run to the end of the line
+	preprocessor bool
+	// rawStrings are C++'s `R"(...)"`, C#'s `@"..."`, or Rust's `r#"..."#`
+	rawStrings bool
+}
+
+var (
+	braceLangList = []*braceLang{
+		{name: "c", singleQuote: 'c', preprocessor: true},
+		{name: "cpp", singleQuote: 'c', preprocessor: true, rawStrings: true},
+		{name: "csharp", singleQuote: 'c', preprocessor: true, rawStrings: true, textBlocks: true},
+		{name: "java", singleQuote: 'c', textBlocks: true},
+		{name: "javascript", asi: true, singleQuote: 's', templates: true, regexps: true},
+		{name: "kotlin", asi: true, singleQuote: 'c', textBlocks: true, nestedComments: true},
+		{name: "rust", singleQuote: 'r', nestedComments: true, rawStrings: true},
+		{name: "scala", asi: true, singleQuote: 'c', textBlocks: true, nestedComments: true},
+		{name: "swift", asi: true, singleQuote: 'c', textBlocks: true, nestedComments: true},
+		{name: "typescript", asi: true, singleQuote: 's', templates: true, regexps: true},
+	}
+	// braceLangs are keyed by name and by any alias usable with lang
+	braceLangs = map[string]*braceLang{}
+	// braceLangExts maps file extensions to language names
+	braceLangExts = map[string]string{
+		".c": "c", ".h": "c",
+		".cc": "cpp", ".cpp": "cpp", ".cxx": "cpp", ".hh": "cpp", ".hpp": "cpp", ".hxx": "cpp",
+		".cs":   "csharp",
+		".java": "java",
+		".js":   "javascript", ".jsx": "javascript", ".mjs": "javascript", ".cjs": "javascript",
+		".kt": "kotlin", ".kts": "kotlin",
+		".rs":    "rust",
+		".scala": "scala",
+		".swift": "swift",
+		".ts":    "typescript", ".tsx": "typescript", ".mts": "typescript", ".cts": "typescript",
+	}
+	braceLangAliases = map[string]string{
+		"c++": "cpp", "cxx": "cpp", "cs": "csharp", "c#": "csharp", "js": "javascript", "jsx": "javascript",
+		"kt": "kotlin", "rs": "rust", "ts": "typescript", "tsx": "typescript",
+	}
+)
+
+func init() {
+	for _, l := range braceLangList {
+		braceLangs[l.name] = l
+	}
+	for alias, name := range braceLangAliases {
+		braceLangs[alias] = braceLangs[name]
+	}
+}
+
+// braceLangForPath detects a language by file extension, returning "" if there's none.
+func braceLangForPath(pat string) string {
+	return braceLangExts[strings.ToLower(filepath.Ext(pat))]
+}
+
+func braceLangNames() string {
+	names := make([]string, 0, len(braceLangs))
+	for n := range braceLangs {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// braceTok is a token: 'i' for an identifier, keyword, or number; 'p' for punctuation; 's' for a string or character
+// literal; 'c' for a comment; or '#' for a preprocessor directive.
+type braceTok struct {
+	kind      byte
+	text      string
+	off, end  int
+	line      int
+	firstLine bool // whether it's the first token on its line
+}
+
+type braceFile struct {
+	src  []byte
+	lang *braceLang
+	toks []braceTok
+	// match pairs the indices of brackets, braces, and parentheses
+	match map[int]int
+}
+
+// braceDecl is a declaration's name token and, if it has one, its body's braces.
+type braceDecl struct {
+	name       int
+	open, shut int
+	// impl is a Rust impl block, whose methods are searched but which is quoted only if nothing else matches
+	impl bool
+}
+
+var (
+	// braceDeclKeywords precede the names they declare
+	braceDeclKeywords = map[string]bool{
+		"class": true, "interface": true, "struct": true, "enum": true, "union": true, "trait": true, "record": true,
+		"type": true, "namespace": true, "module": true, "object": true, "protocol": true, "extension": true,
+		"function": true, "fn": true, "fun": true, "func": true, "def": true,
+		"const": true, "let": true, "var": true, "val": true, "typealias": true, "mod": true,
+	}
+	// braceNotCallers are keywords which may precede a parenthesized expression without declaring a function
+	braceNotCallers = map[string]bool{
+		"return": true, "new": true, "await": true, "yield": true, "throw": true, "case": true, "else": true,
+		"typeof": true, "delete": true, "in": true, "of": true, "instanceof": true, "do": true, "sizeof": true,
+		"if": true, "while": true, "for": true, "switch": true, "catch": true, "when": true, "match": true,
+	}
+	// braceStatements are keywords which may be followed by parentheses and a block, like `if (ok) {`
+	braceStatements = map[string]bool{
+		"if": true, "while": true, "for": true, "switch": true, "catch": true, "synchronized": true, "using": true,
+		"lock": true, "foreach": true, "fixed": true, "return": true, "sizeof": true, "typeof": true, "await": true,
+		"yield": true, "throw": true, "super": true, "this": true, "do": true, "else": true, "try": true,
+		"finally": true,
+	}
+	// braceAfterName may follow a keyword-declared name, e.g. `class Foo extends Bar`
+	braceAfterName = map[string]bool{
+		"extends": true, "implements": true, "where": true, "with": true, "constructor": true, "private": true,
+		"protected": true, "internal": true, "public": true, "final": true, "sealed": true, "permits": true,
+	}
+	// braceModifiers may precede a declaration on their own line in languages without semicolons
+	braceModifiers = map[string]bool{
+		"export": true, "default": true, "async": true, "public": true, "private": true, "protected": true,
+		"internal": true, "static": true, "abstract": true, "override": true, "readonly": true, "declare": true,
+		"open": true, "final": true, "sealed": true, "data": true, "inline": true, "suspend": true, "lateinit": true,
+	}
+)
+
+// sprintBraceDecl finds the declaration at a dotted path like `Client.connect` and returns its text, along with the
+// doc comments and annotations above it, dedented.
+func sprintBraceDecl(src []byte, sym string, lang *braceLang) ([]byte, error) {
+	f, err := tokenizeBraces(src, lang)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		scopes = [][2]int{{0, len(f.toks)}}
+		found  []braceDecl
+		path   = strings.Split(sym, ".")
+	)
+	for i, seg := range path {
+		var (
+			avail []string
+			seen  = make(map[string]bool)
+		)
+		found = found[:0]
+		for _, sc := range scopes {
+			for _, d := range f.findDecls(sc[0], sc[1]) {
+				name := f.toks[d.name].text
+				if name == seg {
+					found = append(found, d)
+				}
+				if p := strings.Join(append(path[:i:i], name), "."); !seen[p] {
+					seen[p] = true
+					avail = append(avail, p)
+				}
+			}
+		}
+		if len(found) == 0 {
+			return nil, fmt.Errorf("couldn't find %q; available: %v", sym, summarizeKeys(avail))
+		}
+		scopes = scopes[:0]
+		for _, d := range found {
+			if d.open >= 0 {
+				scopes = append(scopes, [2]int{d.open + 1, d.shut})
+			}
+		}
+	}
+
+	d := found[0]
+	for _, c := range found {
+		if !c.impl {
+			d = c
+			break
+		}
+	}
+	start, end := f.declStart(d), f.declEnd(d)
+	return dedentTabs(dedentSpaces(src[start:end])), nil
+}
+
+// findDecls finds the declarations among the tokens in [lo, hi) which aren't nested in any brackets.
+func (f *braceFile) findDecls(lo, hi int) []braceDecl {
+	var decls []braceDecl
+	for i := lo; i < hi; i++ {
+		t := f.toks[i]
+		if t.kind == 'p' && (t.text == "{" || t.text == "(" || t.text == "[") {
+			i = f.match[i] // skip the group
+			continue
+		}
+		if t.kind != 'i' || braceStatements[t.text] || braceDeclKeywords[t.text] {
+			continue
+		}
+		if d, ok := f.declAt(lo, hi, i); ok {
+			decls = append(decls, d)
+		}
+	}
+	return decls
+}
+
+// declAt decides whether the name at i is declared there, rather than merely used.
+func (f *braceFile) declAt(lo, hi, i int) (braceDecl, bool) {
+	d := braceDecl{name: i, open: -1, shut: -1}
+	prev, next := f.prevTok(lo, i), f.nextTok(i, hi)
+
+	switch {
+	case prev >= 0 && f.toks[prev].kind == 'i' && braceDeclKeywords[f.toks[prev].text],
+		prev >= 1 && f.toks[prev].text == "*" && f.toks[f.prevTok(lo, prev)].text == "function": // function* gen
+		if next >= 0 {
+			switch n := f.toks[next]; {
+			case n.kind == 'p' && strings.Contains("*&),", n.text):
+				return d, false // a use, like C's `struct foo *p`
+			case n.kind == 'i' && !braceAfterName[n.text]:
+				return d, false
+			}
+		}
+	case prev >= 0 && f.lang.name == "rust" && f.implHead(lo, i):
+		d.impl = true
+	case next >= 0 && (f.toks[next].text == "(" || f.toks[next].text == "<"): // maybe a function or method
+		if prev >= 0 {
+			if p := f.toks[prev]; p.text == "." || p.text == "=" || p.text == "," || p.text == "(" ||
+				p.kind == 'i' && braceNotCallers[p.text] {
+				return d, false
+			}
+		}
+		paren := next
+		if f.toks[next].text == "<" { // generics, e.g. `foo<T>(`
+			for paren < hi && f.toks[paren].text != "(" && f.toks[paren].text != "{" && f.toks[paren].text != ";" {
+				paren++
+			}
+			if paren >= hi || f.toks[paren].text != "(" {
+				return d, false
+			}
+		}
+		j := f.nextTok(f.match[paren], hi)
+		for j >= 0 && f.toks[j].text != "{" && f.toks[j].text != ";" && f.toks[j].text != "}" &&
+			f.toks[j].text != "=" && f.toks[j].text != "," && f.toks[j].text != ")" {
+			if f.toks[j].text == "(" || f.toks[j].text == "[" {
+				j = f.match[j] // e.g. `throws` lists or C++ attributes
+			}
+			if f.lang.asi && f.toks[j].line > f.toks[f.match[paren]].line && f.toks[j].firstLine &&
+				f.toks[j].text != ":" && f.toks[j].text != "->" && f.toks[j].text != "{" {
+				return d, false // a call which ends its statement
+			}
+			j = f.nextTok(j, hi)
+		}
+		switch {
+		case j < 0:
+			return d, false
+		case f.toks[j].text == "{":
+		case f.toks[j].text == ";" && prev >= 0 && (f.toks[prev].kind == 'i' || strings.Contains(">]*&", f.toks[prev].text)):
+			// a prototype or abstract method, like `void connect();`
+		default:
+			return d, false
+		}
+	default:
+		return d, false
+	}
+
+	d.open, d.shut = f.body(i, hi)
+	return d, true
+}
+
+// implHead reports whether the name at i is the type of a Rust impl block, like `impl<T> Foo<T>` or `impl Bar for Foo`.
+func (f *braceFile) implHead(lo, i int) bool {
+	j := i - 1
+	for ; j >= lo; j-- {
+		t := f.toks[j]
+		if t.kind == 'c' {
+			continue
+		}
+		if t.text == ";" || t.text == "}" || t.text == "{" {
+			return false
+		}
+		if t.text == "impl" {
+			break
+		}
+	}
+	if j < lo {
+		return false
+	}
+	for k := i + 1; k < len(f.toks); k++ { // the name must be the last path segment before the body
+		switch t := f.toks[k]; {
+		case t.text == "{":
+			return true
+		case t.kind == 'i' && t.text == "for":
+			return false
+		case t.text == "<":
+			for depth := 0; k < len(f.toks); k++ {
+				if f.toks[k].text == "<" {
+					depth++
+				} else if f.toks[k].text == ">" {
+					if depth--; depth == 0 {
+						break
+					}
+				}
+			}
+		case t.kind == 'i' && t.text == "where":
+		case t.kind == 'c':
+		case t.text == ";":
+			return false
+		}
+	}
+	return false
+}
+
+// body returns the braces of the block which the declaration at i owns, or -1s if it ends at a semicolon.
+func (f *braceFile) body(i, hi int) (int, int) {
+	for j := i + 1; j < hi; j++ {
+		switch t := f.toks[j]; {
+		case t.text == "(" || t.text == "[":
+			j = f.match[j]
+		case t.text == "{":
+			return j, f.match[j]
+		case t.text == ";" || t.text == "}":
+			return -1, -1
+		case f.lang.asi && t.firstLine && j > i+1 && f.continues(j):
+			return -1, -1
+		}
+	}
+	return -1, -1
+}
+
+// continues reports whether the line beginning at token j ends the statement before it, in languages without
+// semicolons.
+func (f *braceFile) continues(j int) bool {
+	p := f.prevTok(0, j)
+	if p < 0 {
+		return true
+	}
+	switch f.toks[p].text {
+	case "=", "=>", "->", ",", ":", "(", "[", "{", "+", "-", "*", "/", "|", "&", "?", ".":
+		return false
+	}
+	switch f.toks[j].text {
+	case ".", "?", ":", "{", "=>", "->", "|", "&", "where", "extends", "implements":
+		return false
+	}
+	return true
+}
+
+// declEnd returns the offset just past the declaration: its body's closing brace, along with any semicolon on the
+// same line; or its semicolon; or, in languages without them, the end of its last line.
+func (f *braceFile) declEnd(d braceDecl) int {
+	j := d.shut
+	if j < 0 {
+		for j = d.name + 1; j < len(f.toks); j++ {
+			t := f.toks[j]
+			if t.text == "(" || t.text == "[" || t.text == "{" {
+				j = f.match[j]
+				continue
+			}
+			if t.text == ";" {
+				return t.end
+			}
+			if t.text == "}" || f.lang.asi && t.firstLine && f.continues(j) {
+				j = f.prevTok(0, j)
+				return f.toks[j].end
+			}
+		}
+		return f.toks[len(f.toks)-1].end
+	}
+	if n := f.nextTok(j, len(f.toks)); n >= 0 && f.toks[n].text == ";" && f.toks[n].line == f.toks[j].line {
+		return f.toks[n].end
+	}
+	return f.toks[j].end
+}
+
+// declStart returns the offset of the start of the line on which the declaration begins: its doc comments, or else its
+// annotations, modifiers, and the like.
+func (f *braceFile) declStart(d braceDecl) int {
+	s := d.name
+	for i := d.name - 1; i >= 0; i-- {
+		t := f.toks[i]
+		if t.kind == 'c' || t.kind == '#' || t.text == ";" || t.text == "{" || t.text == "}" {
+			break
+		}
+		unit := i
+		if t.text == ")" || t.text == "]" {
+			unit = f.match[i]
+		}
+		if f.lang.asi && f.toks[s].line > t.line && !f.headLine(unit) {
+			break
+		}
+		s, i = unit, unit
+	}
+
+	// doc comments directly above, with no blank lines between
+	for i := s - 1; i >= 0; i-- {
+		t := f.toks[i]
+		if t.kind != 'c' || !t.firstLine || f.toks[s].line-f.lineOf(t.end) > 1 {
+			break
+		}
+		s = i
+	}
+
+	start := f.toks[s].off
+	if ls := lineStart(f.src, start); len(bytes.TrimSpace(f.src[ls:start])) == 0 {
+		start = ls
+	}
+	return start
+}
+
+// headLine reports whether the line holding token i can begin a declaration in a language without semicolons: it's
+// an annotation, or nothing but modifiers.
+func (f *braceFile) headLine(i int) bool {
+	first := i
+	for first > 0 && f.toks[first-1].line == f.toks[i].line {
+		first--
+	}
+	if f.toks[first].text == "@" {
+		return true
+	}
+	for j := first; j < len(f.toks) && f.toks[j].line == f.toks[i].line; j++ {
+		if !braceModifiers[f.toks[j].text] {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *braceFile) lineOf(off int) int {
+	return bytes.Count(f.src[:off], []byte("\n")) + 1
+}
+
+// prevTok returns the index of the last token before i, at or after lo, which isn't a comment; or -1.
+func (f *braceFile) prevTok(lo, i int) int {
+	for i--; i >= lo; i-- {
+		if f.toks[i].kind != 'c' {
+			return i
+		}
+	}
+	return -1
+}
+
+// nextTok returns the index of the first token after i, before hi, which isn't a comment; or -1.
+func (f *braceFile) nextTok(i, hi int) int {
+	for i++; i < hi; i++ {
+		if f.toks[i].kind != 'c' {
+			return i
+		}
+	}
+	return -1
+}
+
+// tokenizeBraces splits src into tokens, pairing its brackets.
+func tokenizeBraces(src []byte, lang *braceLang) (*braceFile, error) {
+	f := &braceFile{src: src, lang: lang, match: make(map[int]int)}
+	var (
+		stack    []int
+		line     = 1
+		lastLine = 0
+	)
+	add := func(kind byte, off, end int) {
+		t := braceTok{kind: kind, off: off, end: end, line: line, firstLine: line != lastLine}
+		if kind == 'i' || kind == 'p' {
+			t.text = string(src[off:end])
+		}
+		lastLine = line
+		line += bytes.Count(src[off:end], []byte("\n"))
+		if kind == 'c' || kind == 's' || kind == '#' {
+			lastLine = line
+		}
+		f.toks = append(f.toks, t)
+	}
+	errorf := func(off int, format string, args ...interface{}) error {
+		return fmt.Errorf("line %d: %v", bytes.Count(src[:off], []byte("\n"))+1, fmt.Sprintf(format, args...))
+	}
+
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f' || c == '\v':
+			i++
+		case bytes.HasPrefix(src[i:], []byte("//")):
+			end := bytes.IndexByte(src[i:], '\n')
+			if end < 0 {
+				end = len(src) - i
+			}
+			add('c', i, i+end)
+			i += end
+		case bytes.HasPrefix(src[i:], []byte("/*")):
+			end, err := skipBlockComment(src, i, lang.nestedComments)
+			if err != nil {
+				return nil, errorf(i, "%v", err)
+			}
+			add('c', i, end)
+			i = end
+		case c == '#' && lang.preprocessor && len(bytes.TrimSpace(src[lineStart(src, i):i])) == 0:
+			end := i
+			for end < len(src) && src[end] != '\n' || end > 0 && end < len(src) && src[end-1] == '\\' {
+				end++
+			}
+			add('#', i, end)
+			i = end
+		case c == '/' && lang.regexps && f.regexpAllowed():
+			end, err := scanRegexp(src, i)
+			if err != nil {
+				return nil, errorf(i, "%v", err)
+			}
+			add('s', i, end)
+			i = end
+		default:
+			end, kind, err := scanBraceToken(src, i, lang)
+			if err != nil {
+				return nil, errorf(i, "%v", err)
+			}
+			if kind == 'p' {
+				switch c {
+				case '(', '[', '{':
+					stack = append(stack, len(f.toks))
+				case ')', ']', '}':
+					if len(stack) == 0 {
+						return nil, errorf(i, "unexpected %q", c)
+					}
+					open := stack[len(stack)-1]
+					if want := map[byte]byte{')': '(', ']': '[', '}': '{'}[c]; src[f.toks[open].off] != want {
+						return nil, errorf(i, "unexpected %q; %q on line %d is unclosed", c, src[f.toks[open].off], f.toks[open].line)
+					}
+					stack = stack[:len(stack)-1]
+					f.match[open], f.match[len(f.toks)] = len(f.toks), open
+				}
+			}
+			add(kind, i, end)
+			i = end
+		}
+	}
+	if len(stack) > 0 {
+		open := f.toks[stack[len(stack)-1]]
+		return nil, fmt.Errorf("line %d: unclosed %q", open.line, open.text)
+	}
+	return f, nil
+}
+
+// braceRegexpKeywords may precede an expression, so a `/` after one begins a regular expression rather than dividing
+var braceRegexpKeywords = map[string]bool{
+	"return": true, "typeof": true, "case": true, "in": true, "of": true, "new": true, "delete": true, "void": true,
+	"throw": true, "yield": true, "await": true, "else": true, "do": true, "instanceof": true,
+}
+
+// regexpAllowed reports whether a `/` following the tokens so far begins a regular expression: whether the last of
+// them can't end an expression, like an operator, `(`, `,`, or `return`.
+func (f *braceFile) regexpAllowed() bool {
+	p := f.prevTok(0, len(f.toks))
+	if p < 0 {
+		return true
+	}
+	switch t := f.toks[p]; t.kind {
+	case 'i':
+		return braceRegexpKeywords[t.text]
+	case 's':
+		return false
+	default:
+		return t.text != ")" && t.text != "]"
+	}
+}
+
+// scanRegexp scans a regular expression literal, whose character classes may contain unescaped slashes, and its flags.
+func scanRegexp(src []byte, i int) (int, error) {
+	class := false
+	for j := i + 1; j < len(src); j++ {
+		switch src[j] {
+		case '\\':
+			j++
+		case '[':
+			class = true
+		case ']':
+			class = false
+		case '/':
+			if class {
+				continue
+			}
+			for j++; j < len(src) && (src[j] >= 'a' && src[j] <= 'z' || src[j] >= 'A' && src[j] <= 'Z'); j++ {
+			}
+			return j, nil
+		case '\n':
+			return 0, fmt.Errorf("unterminated regular expression")
+		}
+	}
+	return 0, fmt.Errorf("unterminated regular expression")
+}
+
+func skipBlockComment(src []byte, i int, nested bool) (int, error) {
+	depth := 0
+	for j := i; j < len(src)-1; j++ {
+		switch {
+		case src[j] == '/' && src[j+1] == '*' && (nested || depth == 0):
+			depth++
+			j++
+		case src[j] == '*' && src[j+1] == '/':
+			if depth--; depth == 0 {
+				return j + 2, nil
+			}
+			j++
+		}
+	}
+	return 0, fmt.Errorf("unterminated comment")
+}
+
+// scanBraceToken scans the identifier, number, literal, or punctuation at i.
+func scanBraceToken(src []byte, i int, lang *braceLang) (int, byte, error) {
+	c := src[i]
+	switch {
+	case lang.rawStrings && lang.name == "rust" && (c == 'r' || c == 'b'):
+		j := i + 1
+		if c == 'b' && j < len(src) && src[j] == 'r' {
+			j++
+		}
+		if c == 'r' || j > i+1 {
+			hashes := 0
+			for j < len(src) && src[j] == '#' {
+				hashes++
+				j++
+			}
+			if j < len(src) && src[j] == '"' {
+				end := bytes.Index(src[j+1:], append([]byte{'"'}, bytes.Repeat([]byte{'#'}, hashes)...))
+				if end < 0 {
+					return 0, 0, fmt.Errorf("unterminated raw string")
+				}
+				return j + 1 + end + 1 + hashes, 's', nil
+			}
+		}
+	case lang.rawStrings && lang.name == "cpp" && c == 'R' && i+1 < len(src) && src[i+1] == '"':
+		open := bytes.IndexByte(src[i+2:], '(')
+		if open >= 0 {
+			delim := append(append([]byte{')'}, src[i+2:i+2+open]...), '"')
+			if end := bytes.Index(src[i+2+open:], delim); end >= 0 {
+				return i + 2 + open + end + len(delim), 's', nil
+			}
+		}
+		return 0, 0, fmt.Errorf("unterminated raw string")
+	case lang.rawStrings && lang.name == "csharp" && (c == '@' || c == '$') && bytes.HasPrefix(bytes.TrimLeft(src[i:i+min(3, len(src)-i)], "@$"), []byte(`"`)):
+		j := i
+		for src[j] != '"' {
+			j++
+		}
+		if bytes.Contains(src[i:j], []byte("@")) { // verbatim: doubled quotes escape
+			for j++; j < len(src); j++ {
+				if src[j] == '"' {
+					if j+1 < len(src) && src[j+1] == '"' {
+						j++
+						continue
+					}
+					return j + 1, 's', nil
+				}
+			}
+			return 0, 0, fmt.Errorf("unterminated string")
+		}
+		end, err := scanQuoted(src, j, lang)
+		return end, 's', err
+	}
+
+	switch r, size := utf8.DecodeRune(src[i:]); {
+	case r == '_' || r == '$' || unicode.IsLetter(r) || unicode.IsDigit(r):
+		j := i + size
+		for j < len(src) {
+			r, size := utf8.DecodeRune(src[j:])
+			if r != '_' && r != '$' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+				break
+			}
+			j += size
+		}
+		return j, 'i', nil
+	case c == '"':
+		end, err := scanQuoted(src, i, lang)
+		return end, 's', err
+	case c == '`' && lang.templates:
+		end, err := scanTemplate(src, i, lang)
+		return end, 's', err
+	case c == '\'':
+		switch lang.singleQuote {
+		case 's':
+			end, err := scanQuoted(src, i, lang)
+			return end, 's', err
+		case 'r': // a char like 'a' or '\n', or else a lifetime like 'a
+			if j := i + 1; j < len(src) && src[j] == '\\' {
+				if end := bytes.IndexByte(src[j:], '\''); end >= 0 {
+					return j + end + 1, 's', nil
+				}
+			} else if _, size := utf8.DecodeRune(src[j:]); j+size < len(src) && src[j+size] == '\'' {
+				return j + size + 1, 's', nil
+			}
+			return i + 1, 'p', nil
+		default:
+			end, err := scanQuoted(src, i, lang)
+			return end, 's', err
+		}
+	case c == '-' && i+1 < len(src) && src[i+1] == '>', c == '=' && i+1 < len(src) && src[i+1] == '>',
+		c == ':' && i+1 < len(src) && src[i+1] == ':':
+		return i + 2, 'p', nil
+	default:
+		_, size := utf8.DecodeRune(src[i:])
+		return i + size, 'p', nil
+	}
+}
+
+// scanQuoted scans a string or character literal, honoring backslash escapes and, where supported, `"""` text blocks.
+func scanQuoted(src []byte, i int, lang *braceLang) (int, error) {
+	q := src[i]
+	if q == '"' && lang.textBlocks && bytes.HasPrefix(src[i:], []byte(`"""`)) {
+		for j := i + 3; j < len(src); j++ {
+			if src[j] == '\\' {
+				j++
+				continue
+			}
+			if bytes.HasPrefix(src[j:], []byte(`"""`)) {
+				for j+3 < len(src) && src[j+3] == '"' { // closing quotes may abut
+					j++
+				}
+				return j + 3, nil
+			}
+		}
+		return 0, fmt.Errorf("unterminated text block")
+	}
+	for j := i + 1; j < len(src); j++ {
+		switch src[j] {
+		case '\\':
+			j++
+		case q:
+			return j + 1, nil
+		case '\n':
+			return 0, fmt.Errorf("unterminated %v", map[bool]string{true: "string", false: "character literal"}[q == '"' || lang.singleQuote == 's'])
+		}
+	}
+	return 0, fmt.Errorf("unterminated string")
+}
+
+// scanTemplate scans a JavaScript template literal, including any code within `${}`.
+func scanTemplate(src []byte, i int, lang *braceLang) (int, error) {
+	for j := i + 1; j < len(src); j++ {
+		switch {
+		case src[j] == '\\':
+			j++
+		case src[j] == '`':
+			return j + 1, nil
+		case bytes.HasPrefix(src[j:], []byte("${")):
+			depth := 0
+			for j += 2; j < len(src); {
+				switch c := src[j]; {
+				case c == '}' && depth == 0:
+					goto next
+				case c == '{':
+					depth++
+					j++
+				case c == '}':
+					depth--
+					j++
+				case c == '"' || c == '\'' || c == '`':
+					end, _, err := scanBraceToken(src, j, lang)
+					if err != nil {
+						return 0, err
+					}
+					j = end
+				default:
+					j++
+				}
+			}
+		next:
+		}
+	}
+	return 0, fmt.Errorf("unterminated template literal")
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"testing"
+)
+
+func Test_sprintBraceDecl(t *testing.T) {
+	for _, c := range []struct {
+		name, lang, sym string
+		src             string
+		out, err        string
+	}{
+		{
+			name: "template literals nest code",
+			lang: "javascript",
+			sym:  "f",
+			src:  "function f() {\n  return `${ {a: '}'}.a }`;\n}\nfunction g() {}\n",
+			out:  "function f() {\n  return `${ {a: '}'}.a }`;\n}",
+		},
+		{
+			name: "regular expressions",
+			lang: "typescript",
+			sym:  "f",
+			src:  "const close = /\\}/g, quote = /[\"/]/;\nfunction f(a: number, b: number) {\n  return a / b / 2 + (x) / 3 + [1][0] / 4 + \"s\".split(/'/).length;\n}\n",
+			out:  "function f(a: number, b: number) {\n  return a / b / 2 + (x) / 3 + [1][0] / 4 + \"s\".split(/'/).length;\n}",
+		},
+		{
+			name: "unterminated regular expression",
+			lang: "javascript",
+			sym:  "f",
+			src:  "const re = /abc\nfunction f() {}\n",
+			err:  "line 1: unterminated regular expression",
+		},
+		{
+			name: "statements without semicolons",
+			lang: "typescript",
+			sym:  "handler",
+			src:  "const a = 1\nexport const handler = async (req: Req) => {\n  return a\n}\nhandler()\n",
+			out:  "export const handler = async (req: Req) => {\n  return a\n}",
+		},
+		{
+			name: "calls aren't declarations",
+			lang: "javascript",
+			sym:  "main",
+			src:  "main();\nfunction main() { run() }\n",
+			out:  "function main() { run() }",
+		},
+		{
+			name: "uses aren't declarations",
+			lang: "c",
+			sym:  "point",
+			src:  "struct point *origin(void);\n\n/* A point. */\nstruct point { int x, y; };\n",
+			out:  "/* A point. */\nstruct point { int x, y; };",
+		},
+		{
+			name: "prototypes",
+			lang: "cpp",
+			sym:  "Conn.close",
+			src:  "class Conn {\n public:\n  // close hangs up.\n  void close() noexcept;\n};\n",
+			out:  "// close hangs up.\nvoid close() noexcept;",
+		},
+		{
+			name: "doc comments stop at blank lines",
+			lang: "java",
+			sym:  "A",
+			src:  "/* license */\n\n/** A is a letter. */\n@Deprecated(since = \"2\")\nrecord A(int x) {}\n",
+			out:  "/** A is a letter. */\n@Deprecated(since = \"2\")\nrecord A(int x) {}",
+		},
+		{
+			name: "verbatim strings",
+			lang: "csharp",
+			sym:  "Paths.Root",
+			src:  "static class Paths {\n    public static string Root() {\n        return @\"C:\\\"\"{\";\n    }\n}\n",
+			out:  "public static string Root() {\n    return @\"C:\\\"\"{\";\n}",
+		},
+		{
+			name: "kotlin",
+			lang: "kotlin",
+			sym:  "Repo.find",
+			src:  "class Repo(val db: Db) {\n    /* outer /* nested */ */\n    @JvmStatic\n    fun find(id: Int): User? =\n        db.get(id)\n\n    fun other() {}\n}\n",
+			out:  "/* outer /* nested */ */\n@JvmStatic\nfun find(id: Int): User? =\n    db.get(id)",
+		},
+		{
+			name: "rust chars and lifetimes",
+			lang: "rust",
+			sym:  "brace",
+			src:  "fn brace<'a>(s: &'a str) -> char {\n    '{'\n}\n",
+			out:  "fn brace<'a>(s: &'a str) -> char {\n    '{'\n}",
+		},
+		{
+			name: "rust impl blocks",
+			lang: "rust",
+			sym:  "S.new",
+			src:  "impl Default for S {\n    fn default() -> S { S }\n}\nimpl S {\n    fn new() -> S { S }\n}\nstruct S;\n",
+			out:  "fn new() -> S { S }",
+		},
+		{
+			name: "missing",
+			lang: "java",
+			sym:  "A.nope",
+			src:  "class A {\n  void f() {}\n  int g;\n  class B {}\n}\n",
+			err:  `couldn't find "A.nope"; available: "A.f", "A.B"`,
+		},
+		{
+			name: "unclosed",
+			lang: "c",
+			sym:  "f",
+			src:  "int f() {\n  if (x) {\n}\n",
+			err:  `line 1: unclosed "{"`,
+		},
+		{
+			name: "mismatched",
+			lang: "c",
+			sym:  "f",
+			src:  "int f() {\n  g(];\n}\n",
+			err:  `line 2: unexpected ']'; '(' on line 2 is unclosed`,
+		},
+		{
+			name: "unterminated",
+			lang: "typescript",
+			sym:  "f",
+			src:  "function f() {\n  return 'a\n}\n",
+			err:  "line 2: unterminated string",
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			out, err := sprintBraceDecl([]byte(c.src), c.sym, braceLangs[c.lang])
+			if err != nil {
+				if err.Error() != c.err {
+					t.Fatalf("wanted error %q but got %q", c.err, err)
+				}
+				return
+			}
+			if c.err != "" {
+				t.Fatalf("wanted error %q but got none", c.err)
+			}
+			if string(out) != c.out {
+				t.Errorf("wanted:\n%v\ngot:\n%v", c.out, string(out))
+			}
+		})
+	}
+}
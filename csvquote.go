@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+func expandCSVQuotes(_ context.Context, pqs []*pullQuote) ([]*expanded, error) {
+	exp := make([]*expanded, 0, len(pqs))
+	for _, pq := range pqs {
+		pat, _, _ := splitObjPath(pq.objPath)
+
+		s, err := func() (string, error) {
+			f, err := os.Open(pat)
+			if err != nil {
+				return "", err
+			}
+			defer func() {
+				_ = f.Close()
+			}()
+			rows, header, err := readCSV(f, pq.delimiter, pq.flags&noHeader == 0, pq.where, pq.rows)
+			if err != nil {
+				return "", fmt.Errorf("error within %v: %w", pat, err)
+			}
+			format := pq.tableFmt
+			if format.columns == nil {
+				format.columns = header
+			}
+			return sprintTable(rows, format)
+		}()
+		if err != nil {
+			return nil, err
+		}
+		exp = append(exp, &expanded{String: s})
+	}
+	return exp, nil
+}
+
+// readCSV decodes the rows of a CSV file into objects keyed by column, along with the columns in order. Without a
+// header, columns are named by number, from 1. Cells which are JSON numbers are decoded as json.Numbers, so that where
+// can compare them and tables can align them; everything else is a string. Rows are selected as records are from a
+// stream of JSON values: rng applies to the rows matching where.
+func readCSV(r io.Reader, delim rune, header bool, where *jsonFilter, rng *recordRange) ([]interface{}, []string, error) {
+	br := bufio.NewReader(r)
+	if bom, err := br.Peek(3); err == nil && string(bom) == "\xef\xbb\xbf" {
+		_, _ = br.Discard(3)
+	}
+	cr := csv.NewReader(br)
+	cr.Comma = delim
+	cr.FieldsPerRecord = -1 // checked below, to say which is ragged
+
+	first, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil, errors.New("no rows")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	columns := first
+	if header {
+		seen := make(map[string]bool, len(columns))
+		for i, c := range columns {
+			if seen[c] {
+				line, col := cr.FieldPos(i)
+				return nil, nil, fmt.Errorf("line %d, column %d: duplicate column %q", line, col, c)
+			}
+			seen[c] = true
+		}
+	} else {
+		columns = make([]string, len(first))
+		for i := range columns {
+			columns[i] = strconv.Itoa(i + 1)
+		}
+	}
+
+	var (
+		rows    []interface{}
+		matched int
+		rec     = first
+	)
+	if header {
+		rec, err = cr.Read()
+	}
+	for ; err == nil; rec, err = cr.Read() {
+		if len(rec) != len(columns) {
+			line, _ := cr.FieldPos(0)
+			if header {
+				return nil, nil, fmt.Errorf("line %d: row has %d fields, but the header has %d", line, len(rec), len(columns))
+			}
+			return nil, nil, fmt.Errorf("line %d: row has %d fields, but the first row has %d", line, len(rec), len(columns))
+		}
+		row := newJSONObject()
+		for i, c := range columns {
+			row.set(c, csvValue(rec[i]))
+		}
+		if where != nil && !where.match(row) {
+			continue
+		}
+		if rng == nil || matched >= rng.start {
+			rows = append(rows, row)
+		}
+		if matched++; rng != nil && rng.end >= 0 && matched >= rng.end {
+			break
+		}
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, nil, err
+	}
+
+	switch {
+	case len(rows) > 0:
+		return rows, columns, nil
+	case rng != nil:
+		return nil, nil, fmt.Errorf("no rows in %v; %d match", rng, matched)
+	case where != nil:
+		return nil, nil, fmt.Errorf("no rows where %q", where)
+	}
+	return nil, nil, errors.New("no rows")
+}
+
+// csvValue decodes a cell which is a JSON number as one.
+func csvValue(s string) interface{} {
+	if s != "" && (s[0] == '-' || s[0] >= '0' && s[0] <= '9') && json.Valid([]byte(s)) {
+		return json.Number(s)
+	}
+	return s
+}
+
+func parseDelimiter(s string) (rune, error) {
+	if s == "tab" {
+		return '\t', nil
+	}
+	r, n := utf8.DecodeRuneInString(s)
+	if n == 0 || n != len(s) || r == utf8.RuneError || r == '"' || r == '\r' || r == '\n' {
+		return 0, fmt.Errorf("invalid delimiter %q: must be a single character or tab", s)
+	}
+	return r, nil
+}
+
+func delimiterString(r rune) string {
+	switch r {
+	case 0:
+		return ""
+	case '\t':
+		return "tab"
+	}
+	return string(r)
+}
+
+// delimiterForPath defaults TSV files to tabs.
+func delimiterForPath(pat string) rune {
+	if strings.EqualFold(filepath.Ext(pat), ".tsv") {
+		return '\t'
+	}
+	return ','
+}
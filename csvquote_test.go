@@ -0,0 +1,92 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_readCSV(t *testing.T) {
+	const src = "name,score,note\n" +
+		"a,10,\"x, \"\"y\"\"\"\n" +
+		"b,-2.5e1,\n" +
+		"c,007,\"two\nlines\"\n"
+	for _, c := range []struct {
+		name     string
+		src      string
+		delim    rune
+		noHeader bool
+		where    string
+		rows     string
+		out      string
+		err      string
+	}{
+		{
+			name: "typed cells",
+			out:  `[{"name":"a","score":10,"note":"x, \"y\""},{"name":"b","score":-2.5e1,"note":""},{"name":"c","score":"007","note":"two\nlines"}]`,
+		},
+		{
+			name:  "where and rows",
+			where: "@.score < 100",
+			rows:  "1:",
+			out:   `[{"name":"b","score":-2.5e1,"note":""}]`,
+		},
+		{
+			name:     "no header",
+			src:      "1\t2\n3\t4\n",
+			delim:    '\t',
+			noHeader: true,
+			rows:     ":1",
+			out:      `[{"1":1,"2":2}]`,
+		},
+		{name: "bom", src: "\xef\xbb\xbfa\nx\n", out: `[{"a":"x"}]`},
+		{name: "ragged", src: "a,b\n1,2\n3\n", err: "line 3: row has 1 fields, but the header has 2"},
+		{name: "ragged without header", src: "1,2\n\"3\n\",4,5\n", noHeader: true, err: "line 2: row has 3 fields, but the first row has 2"},
+		{name: "bad quote", src: "a,b\n1,x\"y\n", err: `parse error on line 2, column 4: bare " in non-quoted-field`},
+		{name: "duplicate column", src: "a,b,a\n", err: `line 1, column 5: duplicate column "a"`},
+		{name: "empty", src: "", err: "no rows"},
+		{name: "no match", where: "@.score > 100", err: `no rows where "@.score > 100"`},
+		{name: "out of range", rows: "5:", err: "no rows in 5:; 3 match"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			in, delim := c.src, c.delim
+			if in == "" && c.name != "empty" {
+				in = src
+			}
+			if delim == 0 {
+				delim = ','
+			}
+			var (
+				where *jsonFilter
+				rng   *recordRange
+				err   error
+			)
+			if c.where != "" {
+				if where, err = parseJSONFilter(c.where); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if c.rows != "" {
+				if rng, err = parseRecordRange(keyRows, c.rows); err != nil {
+					t.Fatal(err)
+				}
+			}
+			rows, _, err := readCSV(strings.NewReader(in), delim, !c.noHeader, where, rng)
+			if err != nil {
+				if err.Error() != c.err {
+					t.Fatalf("wanted error %q but got %q", c.err, err)
+				}
+				return
+			}
+			if c.err != "" {
+				t.Fatalf("wanted error %q but got none", c.err)
+			}
+			out, err := encodeJSON(rows, jsonFormat{compact: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out != c.out {
+				t.Errorf("wanted:\n%v\ngot:\n%v", c.out, out)
+			}
+		})
+	}
+}
@@ -1,5 +1,11 @@
 module github.com/jwilner/pullquote
 
-go 1.14
+go 1.25.0
 
-require golang.org/x/tools v0.0.0-20200822203824-307de81be3f4
+require (
+	golang.org/x/mod v0.37.0
+	golang.org/x/tools v0.47.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require golang.org/x/sync v0.21.0 // indirect
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"go/build"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+var (
+	errModuleNotAvailable = errors.New("module version not available locally")
+	// errNotInModule means a module zip doesn't hold the package, which may be in a module with a shorter path
+	errNotInModule = errors.New("package not in module")
+)
+
+// modCacheDir mirrors the go command's logic for locating the module cache without shelling out to `go env`.
+func modCacheDir() string {
+	if d := os.Getenv("GOMODCACHE"); d != "" {
+		return d
+	}
+	gopath := os.Getenv("GOPATH")
+	if gopath == "" {
+		gopath = build.Default.GOPATH
+	}
+	if list := filepath.SplitList(gopath); len(list) > 0 {
+		gopath = list[0]
+	}
+	return filepath.Join(gopath, "pkg", "mod")
+}
+
+// fileProxies returns the local directories named by any `file://` entries in GOPROXY; network proxies are ignored.
+func fileProxies() []string {
+	var dirs []string
+	for _, entry := range strings.FieldsFunc(os.Getenv("GOPROXY"), func(r rune) bool { return r == ',' || r == '|' }) {
+		if !strings.HasPrefix(entry, "file://") {
+			continue
+		}
+		u, err := url.Parse(entry)
+		if err != nil || u.Path == "" {
+			continue
+		}
+		dirs = append(dirs, filepath.FromSlash(u.Path))
+	}
+	return dirs
+}
+
+// splitPinned splits a pattern like `example.com/mod/pkg@v1.2.3` into its package path and version.
+func splitPinned(pat string) (pkgPath, version string, ok bool) {
+	i := strings.LastIndex(pat, "@")
+	if i == -1 {
+		return pat, "", false
+	}
+	return pat[:i], pat[i+1:], true
+}
+
+// resolvePinnedPackage finds the source directory for a package at a specific module version using only local state:
+// first the extracted module cache, then the module cache's download zips, then any `file://` GOPROXY directories.
+// When the source comes from a zip, it's extracted to a temporary directory which is removed by calling cleanup.
+func resolvePinnedPackage(ctx context.Context, pat string) (dir string, cleanup func(), err error) {
+	cleanup = func() {}
+
+	pkgPath, version, _ := splitPinned(pat)
+	if err := module.CheckImportPath(pkgPath); err != nil {
+		return "", cleanup, fmt.Errorf("invalid package path %q: %w", pkgPath, err)
+	}
+	if !semver.IsValid(version) || semver.Canonical(version) != version && !module.IsPseudoVersion(version) {
+		return "", cleanup, fmt.Errorf("version %q for %v must be a full semantic version like v1.2.3", version, pkgPath)
+	}
+
+	cache := modCacheDir()
+	proxies := fileProxies()
+
+	// the module path is some prefix of the package path -- prefer the longest, as the go command does
+	elems := strings.Split(pkgPath, "/")
+	for i := len(elems); i > 0; i-- {
+		modPath, rel := path.Join(elems[:i]...), path.Join(elems[i:]...)
+
+		escPath, err := module.EscapePath(modPath)
+		if err != nil {
+			continue
+		}
+		escVersion, err := module.EscapeVersion(version)
+		if err != nil {
+			return "", cleanup, fmt.Errorf("invalid version %q: %w", version, err)
+		}
+
+		if d := filepath.Join(cache, escPath+"@"+escVersion, filepath.FromSlash(rel)); isDir(d) {
+			if debug {
+				ctxLogf(ctx, `msg="resolved pinned package from module cache" dir=%q`, d)
+			}
+			return d, cleanup, nil
+		}
+
+		zips := []string{filepath.Join(cache, "cache", "download", escPath, "@v", escVersion+".zip")}
+		for _, p := range proxies {
+			zips = append(zips, filepath.Join(p, escPath, "@v", escVersion+".zip"))
+		}
+		for _, z := range zips {
+			if _, err := os.Stat(z); err != nil {
+				continue
+			}
+			tmpDir, err := ioutil.TempDir("", "pullquote-mod")
+			if err != nil {
+				return "", cleanup, fmt.Errorf("unable to open temp directory: %w", err)
+			}
+			cleanup = func() {
+				_ = os.RemoveAll(tmpDir)
+			}
+			if err := extractPackage(z, modPath+"@"+version, rel, tmpDir); err != nil {
+				cleanup()
+				cleanup = func() {}
+				if errors.Is(err, errNotInModule) {
+					continue
+				}
+				return "", cleanup, fmt.Errorf("extracting %v from %v: %w", pkgPath, z, err)
+			}
+			if debug {
+				ctxLogf(ctx, `msg="resolved pinned package from zip" zip=%q`, z)
+			}
+			return tmpDir, cleanup, nil
+		}
+	}
+
+	searched := append([]string{cache}, proxies...)
+	return "", cleanup, fmt.Errorf(
+		"no module providing %v at %v in %v (use `go mod download` to fetch it): %w",
+		pkgPath,
+		version,
+		strings.Join(searched, ", "),
+		errModuleNotAvailable,
+	)
+}
+
+// extractPackage copies the go files for a single package directory out of a module zip.
+func extractPackage(zipFn, prefix, rel, dst string) error {
+	zr, err := zip.OpenReader(zipFn)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = zr.Close()
+	}()
+
+	want := path.Join(prefix, rel)
+
+	var extracted int
+	for _, f := range zr.File {
+		if path.Dir(f.Name) != want || !strings.HasSuffix(f.Name, ".go") {
+			continue
+		}
+		if err := func() error {
+			in, err := f.Open()
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = in.Close()
+			}()
+
+			out, err := os.Create(filepath.Join(dst, path.Base(f.Name)))
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = out.Close()
+			}()
+
+			_, err = io.Copy(out, in)
+			return err
+		}(); err != nil {
+			return err
+		}
+		extracted++
+	}
+	if extracted == 0 {
+		return fmt.Errorf("no go files in %v: %w", want, errNotInModule)
+	}
+	return nil
+}
+
+func isDir(fn string) bool {
+	stat, err := os.Stat(fn)
+	return err == nil && stat.IsDir()
+}
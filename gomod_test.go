@@ -0,0 +1,130 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_resolvePinnedPackage(t *testing.T) {
+	const src = `package pkg
+
+// Sym is old
+func Sym() {}
+`
+	for _, c := range []struct {
+		name    string
+		setup   func(t *testing.T, root string)
+		pat     string
+		wantErr error
+	}{
+		{
+			"module cache",
+			func(t *testing.T, root string) {
+				t.Setenv("GOMODCACHE", filepath.Join(root, "mod"))
+				writeFile(t, filepath.Join(root, "mod/example.com/!my!mod@v1.2.3/pkg/pkg.go"), src)
+			},
+			"example.com/MyMod/pkg@v1.2.3",
+			nil,
+		},
+		{
+			"download cache",
+			func(t *testing.T, root string) {
+				t.Setenv("GOMODCACHE", filepath.Join(root, "mod"))
+				writeZip(t, filepath.Join(root, "mod/cache/download/example.com/mod/@v/v1.2.3.zip"), map[string]string{
+					"example.com/mod@v1.2.3/pkg/pkg.go": src,
+				})
+			},
+			"example.com/mod/pkg@v1.2.3",
+			nil,
+		},
+		{
+			"file proxy",
+			func(t *testing.T, root string) {
+				t.Setenv("GOMODCACHE", filepath.Join(root, "mod"))
+				t.Setenv("GOPROXY", "https://proxy.golang.org,file://"+filepath.ToSlash(filepath.Join(root, "proxy")))
+				writeZip(t, filepath.Join(root, "proxy/example.com/mod/@v/v1.2.3.zip"), map[string]string{
+					"example.com/mod@v1.2.3/go.mod":     "module example.com/mod\n",
+					"example.com/mod@v1.2.3/pkg/pkg.go": src,
+				})
+			},
+			"example.com/mod/pkg@v1.2.3",
+			nil,
+		},
+		{
+			"nested module",
+			func(t *testing.T, root string) {
+				t.Setenv("GOMODCACHE", filepath.Join(root, "mod"))
+				writeZip(t, filepath.Join(root, "mod/cache/download/example.com/mod/sub/@v/v1.2.3.zip"), map[string]string{
+					"example.com/mod/sub@v1.2.3/go.mod": "module example.com/mod/sub\n",
+					"example.com/mod/sub@v1.2.3/sub.go": "package sub\n",
+				})
+				writeZip(t, filepath.Join(root, "mod/cache/download/example.com/mod/@v/v1.2.3.zip"), map[string]string{
+					"example.com/mod@v1.2.3/sub/pkg/pkg.go": src,
+				})
+			},
+			"example.com/mod/sub/pkg@v1.2.3",
+			nil,
+		},
+		{
+			"missing version",
+			func(t *testing.T, root string) {
+				t.Setenv("GOMODCACHE", filepath.Join(root, "mod"))
+				writeFile(t, filepath.Join(root, "mod/example.com/mod@v1.2.3/pkg/pkg.go"), src)
+			},
+			"example.com/mod/pkg@v1.2.4",
+			errModuleNotAvailable,
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			root := t.TempDir()
+			t.Setenv("GOPROXY", "off")
+			c.setup(t, root)
+
+			dir, cleanup, err := resolvePinnedPackage(context.Background(), c.pat)
+			defer cleanup()
+			if !errors.Is(err, c.wantErr) {
+				t.Fatalf("wanted %v but got %v", c.wantErr, err)
+			}
+			if err != nil {
+				return
+			}
+			b, err := os.ReadFile(filepath.Join(dir, "pkg.go"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != src {
+				t.Errorf("wanted %q but got %q", src, b)
+			}
+		})
+	}
+}
+
+func writeZip(t *testing.T, fn string, files map[string]string) {
+	if err := os.MkdirAll(filepath.Dir(fn), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	zw := zip.NewWriter(f)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -4,13 +4,17 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"go/ast"
 	"go/parser"
 	"go/token"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 
 	"golang.org/x/tools/go/packages"
@@ -30,30 +34,59 @@ func parseFile(ctx context.Context, fSet *token.FileSet, pat string) ([]*ast.Fil
 	return files, nil
 }
 
-func parsePackage(ctx context.Context, fSet *token.FileSet, pat string) ([]*ast.File, error) {
-	pkgs, err := packages.Load(&packages.Config{
-		Mode: packages.NeedSyntax |
-			packages.NeedTypes |
-			packages.NeedImports |
-			packages.NeedFiles |
-			packages.NeedName,
+const loadMode = packages.NeedSyntax |
+	packages.NeedTypes |
+	packages.NeedImports |
+	packages.NeedFiles |
+	packages.NeedName
+
+func loadPackages(ctx context.Context, fSet *token.FileSet, mode packages.LoadMode, pat string) ([]*packages.Package, error) {
+	if strings.HasSuffix(pat, ".go") { // load the package containing the file
+		abs, err := filepath.Abs(pat)
+		if err != nil {
+			return nil, err
+		}
+		pat = "file=" + abs
+	}
+	return packages.Load(&packages.Config{
+		Mode:    mode,
 		Context: ctx,
 		Fset:    fSet,
 		Tests:   true,
 	}, pat)
+}
+
+func parsePackage(ctx context.Context, fSet *token.FileSet, pat string) ([]*ast.File, error) {
+	pkgs, err := loadPackages(ctx, fSet, loadMode, pat)
 	if err != nil {
 		return nil, err
 	}
-	var syntax []*ast.File
-	for _, pkg := range pkgs {
-		syntax = append(syntax, pkg.Syntax...)
-	}
+	syntax := packageFiles(fSet, pkgs, pat)
 	if debug {
 		logLoadedFiles(addLogCtx(ctx, `load_mechanism="packages" gopath=%q`, pat), fSet, syntax)
 	}
 	return syntax, nil
 }
 
+// packageFiles collects the syntax of the loaded packages; if pat names a single file, only that file is returned.
+func packageFiles(fSet *token.FileSet, pkgs []*packages.Package, pat string) []*ast.File {
+	var (
+		files []*ast.File
+		abs   string
+	)
+	if strings.HasSuffix(pat, ".go") {
+		abs, _ = filepath.Abs(pat)
+	}
+	for _, pkg := range pkgs {
+		for _, f := range pkg.Syntax {
+			if abs == "" || fSet.File(f.Pos()).Name() == abs {
+				files = append(files, f)
+			}
+		}
+	}
+	return files
+}
+
 func commonPrefix(terms []string) string {
 	var min, max string
 	for _, t := range terms {
@@ -126,23 +159,100 @@ func expandGoQuotes(ctx context.Context, pqs []*pullQuote) ([]*expanded, error)
 	for _, pq := range pqs {
 		fSet := token.NewFileSet()
 
-		parts := strings.SplitN(pq.objPath, "#", 2)
-		pat, sym := parts[0], parts[1]
+		pat, sym, hasSym := splitObjPath(pq.objPath)
 
-		var (
-			files []*ast.File
-			err   error
-		)
-		if strings.HasSuffix(pat, ".go") {
-			files, err = parseFile(ctx, fSet, pat)
-		} else if files, err = parsePackage(ctx, fSet, pat); err == nil && len(files) == 0 {
-			files, err = parseDir(ctx, fSet, pat)
-		}
-		if err != nil {
-			return nil, err
-		}
+		s, err := func() (*expanded, error) {
+			switch pq.fmt { // these load their own type information
+			case fmtMethodSet:
+				return sprintMethodSet(ctx, fSet, pat, sym, pq.layout)
+			case fmtPlayground:
+				return sprintPlayground(ctx, fSet, pat, sym, pq.flags&typeCheck != 0)
+			}
 
-		s, err := sprintNodeWithName(fSet, files, sym, pq.flags, pq.fmt == fmtExample)
+			var (
+				files []*ast.File
+				pkgs  []*packages.Package
+				err   error
+			)
+			switch _, _, pinned := splitPinned(pat); {
+			case pq.flags&withImports != 0:
+				if pinned {
+					return nil, errors.New("withimports requires type information, which isn't available for pinned versions")
+				}
+				if pkgs, err = loadPackages(ctx, fSet, loadMode|packages.NeedTypesInfo, pat); err == nil {
+					files = packageFiles(fSet, pkgs, pat)
+				}
+			case strings.HasSuffix(pat, ".go"):
+				files, err = parseFile(ctx, fSet, pat)
+			case pinned:
+				dir, cleanup, rErr := resolvePinnedPackage(ctx, pat)
+				defer cleanup() // rendering reads from the files, so we can't clean up until we're done
+				if rErr != nil {
+					return nil, rErr
+				}
+				files, err = parseDir(ctx, fSet, dir)
+			default:
+				if files, err = parsePackage(ctx, fSet, pat); err == nil && len(files) == 0 {
+					files, err = parseDir(ctx, fSet, pat)
+				}
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			var exp *expanded
+			if hasSym {
+				exp, err = sprintNodeWithName(fSet, files, sym, pq.flags, pq.fmt == fmtExample)
+			} else {
+				exp, err = sprintFiles(fSet, files, pq.flags, strings.HasSuffix(pat, ".go"))
+			}
+			if err != nil {
+				return nil, err
+			}
+			startLine, endLine := fSet.Position(exp.Pos).Line, fSet.Position(exp.End).Line
+			if pq.sub.isSet() {
+				b, skipped, err := pq.sub.apply([]byte(exp.String))
+				if err != nil {
+					return nil, fmt.Errorf("selecting within %q: %w", sym, err)
+				}
+				startLine += skipped
+				endLine = startLine + bytes.Count(b, []byte("\n"))
+				if pq.flags&noRealignTabs == 0 {
+					b = dedentTabs(b)
+				}
+				exp = &expanded{String: string(b), Pos: exp.Pos, End: exp.End}
+			}
+			if pq.link != "" {
+				if exp.Pos == token.NoPos {
+					return nil, errors.New("link requires a symbol")
+				}
+				link, err := sourceLink(pq, pat, sym, fSet.Position(exp.Pos).Filename, startLine, endLine)
+				if err != nil {
+					return nil, fmt.Errorf("linking %q: %w", sym, err)
+				}
+				exp.Link = link
+			}
+			if pq.flags&withImports != 0 {
+				if exp.Pos == token.NoPos {
+					return nil, errors.New("withimports requires a symbol")
+				}
+				sp := span{exp.Pos, exp.End}
+				if pq.sub.isSet() { // just the selected lines
+					sp = lineSpan(fSet.File(exp.Pos), startLine, endLine, sp)
+				}
+				imports, err := importsFor(fSet, pkgs, sp)
+				if err != nil {
+					return nil, err
+				}
+				if imports != "" {
+					exp.String = imports + "\n\n" + exp.String
+					if len(exp.Parts) > 0 {
+						exp.Parts[0] = imports + "\n\n" + exp.Parts[0]
+					}
+				}
+			}
+			return exp, nil
+		}()
 		if err != nil {
 			return nil, fmt.Errorf("error within %v: %w", pat, err)
 		}
@@ -159,12 +269,28 @@ func sprintNodeWithName(
 	flags uint,
 	example bool,
 ) (*expanded, error) {
+	if strings.Contains(name, "/") {
+		found, pos, end, err := sprintScopedNode(fSet, files, strings.Split(name, "/"), flags)
+		if err != nil {
+			return nil, err
+		}
+		if flags&noRealignTabs == 0 {
+			found = realignTabs(found)
+		}
+		return &expanded{String: string(found), Pos: pos, End: end}, nil
+	}
+
 	for _, f := range files {
 		var (
-			found []byte
-			parts [][]byte
-			err   error
+			found    []byte
+			parts    [][]byte
+			err      error
+			pos, end token.Pos
 		)
+		render := func(doc *ast.CommentGroup, node ast.Node) ([]byte, error) {
+			pos, end = nodeSpan(doc, node)
+			return renderNode(fSet, doc, node)
+		}
 		ast.Inspect(f, func(node ast.Node) bool {
 			if found != nil || err != nil {
 				return false
@@ -177,7 +303,7 @@ func sprintNodeWithName(
 				for _, lhs := range x.Lhs {
 					if ident, ok := lhs.(*ast.Ident); ok {
 						if ident.Name == name {
-							found, err = renderNode(fSet, nil, x)
+							found, err = render(nil, x)
 							return false
 						}
 					}
@@ -190,10 +316,10 @@ func sprintNodeWithName(
 						for _, n := range s.Names {
 							if n.Name == name {
 								if flags&includeGroup != 0 || x.Lparen == token.NoPos {
-									found, err = renderNode(fSet, x.Doc, x)
+									found, err = render(x.Doc, x)
 									return false
 								}
-								found, err = renderNode(fSet, s.Doc, s)
+								found, err = render(s.Doc, s)
 								return false
 							}
 						}
@@ -203,10 +329,10 @@ func sprintNodeWithName(
 						s := s.(*ast.TypeSpec)
 						if s.Name.Name == name {
 							if flags&includeGroup != 0 || x.Lparen == 0 {
-								found, err = renderNode(fSet, x.Doc, x)
+								found, err = render(x.Doc, x)
 								return false
 							}
-							found, err = renderNode(fSet, s.Doc, s)
+							found, err = render(s.Doc, s)
 							return false
 						}
 					}
@@ -215,7 +341,7 @@ func sprintNodeWithName(
 				if x.Name.Name != name {
 					break
 				}
-				found, err = renderNode(fSet, x.Doc, x)
+				found, err = render(x.Doc, x)
 				if err != nil {
 					return false
 				}
@@ -235,7 +361,7 @@ func sprintNodeWithName(
 		if flags&noRealignTabs == 0 {
 			found = realignTabs(found)
 		}
-		exp := &expanded{String: string(found)}
+		exp := &expanded{String: string(found), Pos: pos, End: end}
 		for _, p := range parts {
 			exp.Parts = append(exp.Parts, string(p))
 		}
@@ -244,6 +370,297 @@ func sprintNodeWithName(
 	return nil, fmt.Errorf("couldn't find %q", name)
 }
 
+// sprintScopedNode renders a declaration local to a function, addressed by a path like `TestServer/handler` or
+// `main/cfg/2`. The first segment names a function or method (as `Type.Method`); any intermediate segments name func
+// literals -- either assigned to a variable or passed as a `t.Run` subtest -- and the last names a local variable,
+// constant, type, label, or subtest, optionally followed by its 1-indexed occurrence within the scope.
+func sprintScopedNode(
+	fSet *token.FileSet,
+	files []*ast.File,
+	path []string,
+	flags uint,
+) (found []byte, pos, end token.Pos, err error) {
+	occurrence := 1
+	if l := len(path) - 1; l >= 2 {
+		if n, aErr := strconv.Atoi(path[l]); aErr == nil {
+			if n < 1 {
+				return nil, 0, 0, fmt.Errorf("occurrences are numbered from 1, got %d", n)
+			}
+			occurrence, path = n, path[:l]
+		}
+	}
+
+	var scope ast.Node
+	for _, f := range files {
+		for _, d := range f.Decls {
+			if fd, ok := d.(*ast.FuncDecl); ok && fd.Body != nil && funcDeclName(fd) == path[0] {
+				scope = fd.Body
+				break
+			}
+		}
+		if scope != nil {
+			break
+		}
+	}
+	if scope == nil {
+		return nil, 0, 0, fmt.Errorf("couldn't find func %q", path[0])
+	}
+
+	for i, seg := range path[1 : len(path)-1] {
+		var next ast.Node
+		ast.Inspect(scope, func(node ast.Node) bool {
+			if next != nil {
+				return false
+			}
+			if lit := scopedFuncLit(node, seg); lit != nil {
+				next = lit.Body
+				return false
+			}
+			return true
+		})
+		if next == nil {
+			return nil, 0, 0, fmt.Errorf("couldn't find func literal or subtest %q within %v", seg, strings.Join(path[:i+1], "/"))
+		}
+		scope = next
+	}
+
+	name, within := path[len(path)-1], strings.Join(path[:len(path)-1], "/")
+
+	type match struct {
+		doc  *ast.CommentGroup
+		node ast.Node
+	}
+	var matches []match
+	ast.Inspect(scope, func(node ast.Node) bool {
+		switch x := node.(type) {
+		case *ast.AssignStmt:
+			if x.Tok != token.DEFINE {
+				break
+			}
+			for _, lhs := range x.Lhs {
+				if ident, ok := lhs.(*ast.Ident); ok && ident.Name == name {
+					matches = append(matches, match{nil, x})
+					break
+				}
+			}
+		case *ast.GenDecl:
+			for _, s := range x.Specs {
+				var names []*ast.Ident
+				switch s := s.(type) {
+				case *ast.ValueSpec:
+					names = s.Names
+				case *ast.TypeSpec:
+					names = []*ast.Ident{s.Name}
+				}
+				for _, n := range names {
+					if n.Name != name {
+						continue
+					}
+					if flags&includeGroup != 0 || x.Lparen == token.NoPos {
+						matches = append(matches, match{x.Doc, x})
+					} else {
+						matches = append(matches, match{specDoc(s), s})
+					}
+				}
+			}
+		case *ast.LabeledStmt:
+			if x.Label.Name == name {
+				matches = append(matches, match{nil, x})
+			}
+		case *ast.CallExpr:
+			if matchesSubtest(subtestName(x), name) {
+				matches = append(matches, match{nil, x})
+			}
+		}
+		return true
+	})
+
+	switch {
+	case len(matches) == 0:
+		return nil, 0, 0, fmt.Errorf("couldn't find %q within %v", name, within)
+	case len(matches) < occurrence:
+		return nil, 0, 0, fmt.Errorf("wanted occurrence %d of %q within %v but only found %d", occurrence, name, within, len(matches))
+	}
+	m := matches[occurrence-1]
+	pos, end = nodeSpan(m.doc, m.node)
+	found, err = renderNode(fSet, m.doc, m.node)
+	return found, pos, end, err
+}
+
+// funcDeclName returns the name by which a function declaration is addressed -- `Type.Method` for methods.
+func funcDeclName(fd *ast.FuncDecl) string {
+	if fd.Recv == nil || len(fd.Recv.List) == 0 {
+		return fd.Name.Name
+	}
+	t := fd.Recv.List[0].Type
+	for {
+		switch x := t.(type) {
+		case *ast.StarExpr:
+			t = x.X
+			continue
+		case *ast.IndexExpr: // generic receivers
+			t = x.X
+			continue
+		case *ast.IndexListExpr:
+			t = x.X
+			continue
+		case *ast.Ident:
+			return x.Name + "." + fd.Name.Name
+		}
+		return fd.Name.Name
+	}
+}
+
+// scopedFuncLit returns the func literal introduced by node under the given name, if any.
+func scopedFuncLit(node ast.Node, name string) *ast.FuncLit {
+	switch x := node.(type) {
+	case *ast.AssignStmt:
+		for i, lhs := range x.Lhs {
+			if ident, ok := lhs.(*ast.Ident); ok && ident.Name == name && i < len(x.Rhs) {
+				lit, _ := x.Rhs[i].(*ast.FuncLit)
+				return lit
+			}
+		}
+	case *ast.ValueSpec:
+		for i, n := range x.Names {
+			if n.Name == name && i < len(x.Values) {
+				lit, _ := x.Values[i].(*ast.FuncLit)
+				return lit
+			}
+		}
+	case *ast.CallExpr:
+		if matchesSubtest(subtestName(x), name) {
+			lit, _ := x.Args[1].(*ast.FuncLit)
+			return lit
+		}
+	}
+	return nil
+}
+
+// subtestName returns the name of a call like `t.Run("name", func(t *testing.T) { ... })`, or "" if it isn't one.
+func subtestName(call *ast.CallExpr) string {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != "Run" || len(call.Args) != 2 {
+		return ""
+	}
+	lit, ok := call.Args[0].(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return ""
+	}
+	name, err := strconv.Unquote(lit.Value)
+	if err != nil {
+		return ""
+	}
+	return name
+}
+
+// matchesSubtest compares names either as written or as rewritten by `go test`, with spaces replaced by underscores.
+func matchesSubtest(subtest, name string) bool {
+	return subtest != "" && (subtest == name || strings.Replace(subtest, " ", "_", -1) == name)
+}
+
+func specDoc(s ast.Spec) *ast.CommentGroup {
+	switch s := s.(type) {
+	case *ast.ValueSpec:
+		return s.Doc
+	case *ast.TypeSpec:
+		return s.Doc
+	}
+	return nil
+}
+
+// sprintFiles renders whole files in filename order, optionally stripping their package clauses and imports or
+// keeping only their top-level declarations. Test files are skipped unless explicitly quoted.
+func sprintFiles(fSet *token.FileSet, files []*ast.File, flags uint, includeTests bool) (*expanded, error) {
+	byName := make(map[string]*ast.File)
+	names := make([]string, 0, len(files))
+	for _, f := range files {
+		fn := fSet.File(f.Pos()).Name()
+		if _, ok := byName[fn]; ok || !strings.HasSuffix(fn, ".go") || !includeTests && strings.HasSuffix(fn, "_test.go") {
+			continue // packages.Load hands back test variants (and generated test mains) alongside the package itself
+		}
+		byName[fn] = f
+		names = append(names, fn)
+	}
+	if len(names) == 0 {
+		return nil, errors.New("no go files found")
+	}
+	sort.Strings(names)
+
+	rendered := make([]string, 0, len(names))
+	for _, fn := range names {
+		b, err := renderFile(fSet, byName[fn], flags)
+		if err != nil {
+			return nil, err
+		}
+		rendered = append(rendered, string(b))
+	}
+	return &expanded{String: strings.Join(rendered, "\n\n")}, nil
+}
+
+var regexpExcessNewlines = regexp.MustCompile(`\n{3,}`)
+
+func renderFile(fSet *token.FileSet, f *ast.File, flags uint) ([]byte, error) {
+	tf := fSet.File(f.Pos())
+	src, err := ioutil.ReadFile(tf.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	declStart := func(d ast.Decl) token.Pos {
+		switch d := d.(type) {
+		case *ast.GenDecl:
+			if d.Doc != nil {
+				return d.Doc.Pos()
+			}
+		case *ast.FuncDecl:
+			if d.Doc != nil {
+				return d.Doc.Pos()
+			}
+		}
+		return d.Pos()
+	}
+	isImport := func(d ast.Decl) bool {
+		g, ok := d.(*ast.GenDecl)
+		return ok && g.Tok == token.IMPORT
+	}
+
+	if flags&declsOnly != 0 {
+		var decls [][]byte
+		for _, d := range f.Decls {
+			if isImport(d) {
+				continue
+			}
+			decls = append(decls, src[tf.Offset(declStart(d)):tf.Offset(d.End())])
+		}
+		return bytes.Join(decls, []byte("\n\n")), nil
+	}
+
+	var cuts [][2]int
+	if flags&omitPackage != 0 {
+		cuts = append(cuts, [2]int{0, tf.Offset(f.Name.End())})
+	}
+	if flags&omitImports != 0 {
+		for _, d := range f.Decls {
+			if isImport(d) {
+				cuts = append(cuts, [2]int{tf.Offset(declStart(d)), tf.Offset(d.End())})
+			}
+		}
+	}
+
+	var (
+		buf  bytes.Buffer
+		prev int
+	)
+	for _, c := range cuts {
+		buf.Write(src[prev:c[0]])
+		prev = c[1]
+	}
+	buf.Write(src[prev:])
+
+	return bytes.Trim(regexpExcessNewlines.ReplaceAll(buf.Bytes(), []byte("\n\n")), "\n"), nil
+}
+
 var (
 	regexpOutputComment = regexp.MustCompile(`^\s*//\s*Output:\s*$`)
 	regexpCommentPrefix = regexp.MustCompile(`^\s*//\s?(.*)$`)
@@ -364,13 +781,155 @@ func realignTabs(found []byte) []byte {
 	return found[:cur]
 }
 
-func renderNode(fSet *token.FileSet, doc *ast.CommentGroup, node ast.Node) ([]byte, error) {
-	sPos := node.Pos()
+// lineRange selects a sub-range of a rendered node's lines. Line numbers are 1-indexed and counted from the first line
+// of the snippet (including any doc comment) so they're unaffected by edits elsewhere in the file; zero leaves that
+// end unbounded. If patterns are provided, they further narrow the range to the first line matching from through the
+// next line matching to.
+type lineRange struct {
+	first, last int
+	from, to    *regexp.Regexp
+}
+
+func (r lineRange) isSet() bool {
+	return r.first != 0 || r.last != 0 || r.from != nil || r.to != nil
+}
+
+// linesString returns the `lines` option value that would produce the range, or "" if unset.
+func (r lineRange) linesString() string {
+	switch {
+	case r.first == 0 && r.last == 0:
+		return ""
+	case r.first == r.last:
+		return strconv.Itoa(r.first)
+	case r.last == 0:
+		return fmt.Sprintf("%d-", r.first)
+	case r.first == 0:
+		return fmt.Sprintf("-%d", r.last)
+	default:
+		return fmt.Sprintf("%d-%d", r.first, r.last)
+	}
+}
+
+// parseLines parses a line range like `3-10`, `3-`, `-10`, or `3`.
+func parseLines(v string) (first, last int, err error) {
+	parts := strings.SplitN(v, "-", 2)
+	if parts[0] != "" {
+		if first, err = strconv.Atoi(parts[0]); err != nil {
+			return 0, 0, err
+		}
+	}
+	switch {
+	case len(parts) == 1:
+		last = first
+	case parts[1] != "":
+		if last, err = strconv.Atoi(parts[1]); err != nil {
+			return 0, 0, err
+		}
+	}
+	switch {
+	case parts[0] == "" && (len(parts) == 1 || parts[1] == ""):
+		return 0, 0, errors.New("must specify at least one line")
+	case first < 0 || last < 0 || parts[0] != "" && first == 0:
+		return 0, 0, errors.New("lines are numbered from 1")
+	case last != 0 && last < first:
+		return 0, 0, errors.New("range ends before it starts")
+	}
+	return first, last, nil
+}
+
+// apply returns the selected lines along with the number of lines skipped before them.
+func (r lineRange) apply(b []byte) ([]byte, int, error) {
+	lines := bytes.SplitAfter(b, []byte("\n"))
+
+	start, end := 0, len(lines)
+	if r.first > 0 {
+		if r.first > len(lines) {
+			return nil, 0, fmt.Errorf("line %d is past the end of the snippet (%d lines)", r.first, len(lines))
+		}
+		start = r.first - 1
+	}
+	if r.last > 0 && r.last < end {
+		end = r.last
+	}
+
+	if r.from != nil {
+		i := start
+		for i < end && !r.from.Match(lines[i]) {
+			i++
+		}
+		if i == end {
+			return nil, 0, fmt.Errorf("from %q never matched", r.from)
+		}
+		start = i
+	}
+	if r.to != nil {
+		i := start
+		for i < end && !r.to.Match(lines[i]) {
+			i++
+		}
+		if i == end {
+			return nil, 0, fmt.Errorf("to %q never matched", r.to)
+		}
+		end = i + 1
+	}
+
+	return bytes.TrimRight(bytes.Join(lines[start:end], nil), "\r\n"), start, nil
+}
+
+// dedentTabs removes the leading tabs common to all non-blank lines; unlike realignTabs, it makes no assumptions
+// about the first line being a declaration.
+func dedentTabs(b []byte) []byte {
+	return dedentByte(b, '\t')
+}
+
+// dedentSpaces is dedentTabs for space-indented languages.
+func dedentSpaces(b []byte) []byte {
+	return dedentByte(b, ' ')
+}
+
+func dedentByte(b []byte, c byte) []byte {
+	lines := bytes.SplitAfter(b, []byte("\n"))
+
+	common := -1
+	for _, l := range lines {
+		if len(bytes.TrimSpace(l)) == 0 {
+			continue
+		}
+		n := 0
+		for n < len(l) && l[n] == c {
+			n++
+		}
+		if common == -1 || n < common {
+			common = n
+		}
+	}
+	if common <= 0 {
+		return b
+	}
+
+	out := make([]byte, 0, len(b))
+	for _, l := range lines {
+		n := 0
+		for n < common && n < len(l) && l[n] == c {
+			n++
+		}
+		out = append(out, l[n:]...)
+	}
+	return out
+}
+
+// nodeSpan returns the extent of a node including its doc comment.
+func nodeSpan(doc *ast.CommentGroup, node ast.Node) (token.Pos, token.Pos) {
 	if doc != nil {
-		sPos = doc.Pos()
+		return doc.Pos(), node.End()
 	}
+	return node.Pos(), node.End()
+}
+
+func renderNode(fSet *token.FileSet, doc *ast.CommentGroup, node ast.Node) ([]byte, error) {
+	sPos, ePos := nodeSpan(doc, node)
 
-	pos, end := fSet.PositionFor(sPos, false), fSet.PositionFor(node.End(), false)
+	pos, end := fSet.PositionFor(sPos, false), fSet.PositionFor(ePos, false)
 	if !pos.IsValid() || !end.IsValid() {
 		panic("invalid node for fSet passed")
 	}
@@ -123,3 +123,80 @@ func ExampleFooBar() {
 		})
 	}
 }
+
+func Test_parseLines(t *testing.T) {
+	for _, c := range []struct {
+		in          string
+		first, last int
+		wantErr     bool
+	}{
+		{"3-10", 3, 10, false},
+		{"3-", 3, 0, false},
+		{"-10", 0, 10, false},
+		{"4", 4, 4, false},
+		{"-", 0, 0, true},
+		{"0-3", 0, 0, true},
+		{"10-3", 0, 0, true},
+		{"a-3", 0, 0, true},
+	} {
+		t.Run(c.in, func(t *testing.T) {
+			first, last, err := parseLines(c.in)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("wantErr %v but %v", c.wantErr, err)
+			}
+			if first != c.first || last != c.last {
+				t.Errorf("wanted %d-%d but got %d-%d", c.first, c.last, first, last)
+			}
+		})
+	}
+}
+
+func Test_lineRange_apply(t *testing.T) {
+	const in = `// fooBar does things
+func fooBar() {
+	a := 1
+	// start here
+	b := 2
+	c := 3
+	return
+}`
+	for _, c := range []struct {
+		name    string
+		rng     lineRange
+		out     string
+		wantErr bool
+	}{
+		{"lines", lineRange{first: 3, last: 5}, "\ta := 1\n\t// start here\n\tb := 2", false},
+		{"open end", lineRange{first: 7}, "\treturn\n}", false},
+		{"from", lineRange{from: reg(`start here`)}, "\t// start here\n\tb := 2\n\tc := 3\n\treturn\n}", false},
+		{"from to", lineRange{from: reg(`start here`), to: reg(`c :=`)}, "\t// start here\n\tb := 2\n\tc := 3", false},
+		{"to bounded by lines", lineRange{last: 4, to: reg(`c :=`)}, "", true},
+		{"past end", lineRange{first: 20}, "", true},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			out, _, err := c.rng.apply([]byte(in))
+			if (err != nil) != c.wantErr {
+				t.Fatalf("wantErr %v but %v", c.wantErr, err)
+			}
+			if string(out) != c.out {
+				t.Errorf("wanted %q but got %q", c.out, out)
+			}
+		})
+	}
+}
+
+func Test_dedentTabs(t *testing.T) {
+	for _, tt := range []struct {
+		name, in, out string
+	}{
+		{"empty", ``, ``},
+		{"common", "\t\ta := 1\n\n\t\tif a {\n\t\t\treturn\n\t\t}", "a := 1\n\nif a {\n\treturn\n}"},
+		{"none common", "a := 1\n\tb := 2", "a := 1\n\tb := 2"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(dedentTabs([]byte(tt.in))); got != tt.out {
+				t.Errorf("dedentTabs() = %q, want %q", got, tt.out)
+			}
+		})
+	}
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/doc"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// lookupTypeName finds the package-level type named sym among the loaded packages.
+func lookupTypeName(pkgs []*packages.Package, sym string) (*packages.Package, *types.TypeName, error) {
+	for _, pkg := range pkgs {
+		if pkg.Types == nil {
+			continue
+		}
+		switch obj := pkg.Types.Scope().Lookup(sym).(type) {
+		case *types.TypeName:
+			return pkg, obj, nil
+		case nil:
+		default:
+			return nil, nil, fmt.Errorf("%q is a %T, not a type", sym, obj)
+		}
+	}
+	for _, pkg := range pkgs {
+		for _, e := range pkg.Errors {
+			return nil, nil, fmt.Errorf("couldn't find type %q; loading %v: %w", sym, pkg.PkgPath, e)
+		}
+	}
+	return nil, nil, fmt.Errorf("couldn't find type %q", sym)
+}
+
+type methodSetEntry struct {
+	recv, sig, origin, synopsis string
+}
+
+// sprintMethodSet lists the methods of the named type T and then those only available on *T, including promoted ones.
+func sprintMethodSet(ctx context.Context, fSet *token.FileSet, pat, sym, layout string) (*expanded, error) {
+	if _, _, pinned := splitPinned(pat); pinned {
+		return nil, errors.New("fmt=methodset requires type information, which isn't available for pinned versions")
+	}
+	pkgs, err := loadPackages(ctx, fSet, loadMode, pat)
+	if err != nil {
+		return nil, err
+	}
+	pkg, tn, err := lookupTypeName(pkgs, sym)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		qual    = types.RelativeTo(pkg.Types)
+		docs    = docFinder{fSet: fSet, files: make(map[string]*ast.File)}
+		entries []methodSetEntry
+		seen    = make(map[string]bool)
+	)
+	for _, t := range []types.Type{tn.Type(), types.NewPointer(tn.Type())} {
+		if _, ok := t.Underlying().(*types.Interface); ok && t != tn.Type() {
+			break // pointers to interfaces have no methods
+		}
+		recv := types.TypeString(t, qual)
+
+		mSet := types.NewMethodSet(t)
+		for i := 0; i < mSet.Len(); i++ {
+			sel := mSet.At(i)
+			fn := sel.Obj().(*types.Func)
+			if !fn.Exported() || seen[fn.Name()] {
+				continue // only the API
+			}
+			seen[fn.Name()] = true
+
+			var origin string
+			if len(sel.Index()) > 1 { // promoted through embedding
+				if r := fn.Type().(*types.Signature).Recv(); r != nil {
+					origin = types.TypeString(r.Type(), qual)
+				}
+			}
+			entries = append(entries, methodSetEntry{
+				recv:     recv,
+				sig:      fmt.Sprintf("func (%v) %v%v", recv, fn.Name(), strings.TrimPrefix(types.TypeString(sel.Type(), qual), "func")),
+				origin:   origin,
+				synopsis: docs.synopsis(fn),
+			})
+		}
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("%q has no methods", sym)
+	}
+
+	var b strings.Builder
+	switch layout {
+	case layoutList:
+		for _, e := range entries {
+			_, _ = fmt.Fprintf(&b, "- `%v`", e.sig)
+			if e.synopsis != "" {
+				_, _ = fmt.Fprintf(&b, " — %v", e.synopsis)
+			}
+			if e.origin != "" {
+				_, _ = fmt.Fprintf(&b, " _(promoted from `%v`)_", e.origin)
+			}
+			b.WriteByte('\n')
+		}
+	case layoutTable:
+		b.WriteString("| Receiver | Method | Promoted from | Description |\n| --- | --- | --- | --- |\n")
+		for _, e := range entries {
+			origin := ""
+			if e.origin != "" {
+				origin = "`" + e.origin + "`"
+			}
+			_, _ = fmt.Fprintf(
+				&b,
+				"| `%v` | `%v` | %v | %v |\n",
+				e.recv,
+				e.sig,
+				origin,
+				strings.Replace(e.synopsis, "|", `\|`, -1),
+			)
+		}
+	default:
+		var lastRecv string
+		for _, e := range entries {
+			if e.recv != lastRecv {
+				if lastRecv != "" {
+					b.WriteByte('\n')
+				}
+				_, _ = fmt.Fprintf(&b, "// %v\n", e.recv)
+				lastRecv = e.recv
+			}
+			b.WriteString(e.sig)
+			var notes []string
+			if e.origin != "" {
+				notes = append(notes, "promoted from "+e.origin)
+			}
+			if e.synopsis != "" {
+				notes = append(notes, e.synopsis)
+			}
+			if len(notes) > 0 {
+				b.WriteString(" // " + strings.Join(notes, "; "))
+			}
+			b.WriteByte('\n')
+		}
+	}
+
+	return &expanded{String: strings.TrimRight(b.String(), "\n")}, nil
+}
+
+// docFinder locates doc comments for type-checked objects. Objects from dependencies are loaded from export data
+// without syntax and with only line-level positions, so we go back to their source files.
+type docFinder struct {
+	fSet  *token.FileSet
+	files map[string]*ast.File
+}
+
+func (d *docFinder) synopsis(obj types.Object) string {
+	pos := d.fSet.Position(obj.Pos())
+	if !pos.IsValid() {
+		return ""
+	}
+	if rest := strings.TrimPrefix(pos.Filename, "$GOROOT"); rest != pos.Filename { // export data trims GOROOT
+		pos.Filename = filepath.Join(build.Default.GOROOT, rest)
+	}
+
+	f, ok := d.files[pos.Filename]
+	if !ok {
+		f, _ = parser.ParseFile(d.fSet, pos.Filename, nil, parseMode) // best-effort; docs are optional
+		d.files[pos.Filename] = f
+	}
+	if f == nil {
+		return ""
+	}
+
+	atPos := func(id *ast.Ident) bool {
+		p := d.fSet.Position(id.Pos())
+		return id.Name == obj.Name() && p.Line == pos.Line
+	}
+
+	var cg *ast.CommentGroup
+	ast.Inspect(f, func(n ast.Node) bool {
+		if cg != nil {
+			return false
+		}
+		switch x := n.(type) {
+		case *ast.FuncDecl:
+			if atPos(x.Name) {
+				cg = x.Doc
+			}
+			return false
+		case *ast.Field: // interface methods
+			for _, id := range x.Names {
+				if atPos(id) {
+					cg = x.Doc
+					if cg == nil {
+						cg = x.Comment
+					}
+				}
+			}
+		}
+		return true
+	})
+	if cg == nil {
+		return ""
+	}
+	return doc.Synopsis(cg.Text())
+}
+
+// span delimits a range of source within a token.FileSet.
+type span struct {
+	pos, end token.Pos
+}
+
+// lineSpan narrows within to the lines from start through end of f.
+func lineSpan(f *token.File, start, end int, within span) span {
+	if f == nil || start < 1 || start > end || start > f.LineCount() {
+		return within
+	}
+	sp := span{f.LineStart(start), token.Pos(f.Base() + f.Size())}
+	if end < f.LineCount() {
+		sp.end = f.LineStart(end + 1)
+	}
+	if sp.pos < within.pos {
+		sp.pos = within.pos
+	}
+	if sp.end > within.end {
+		sp.end = within.end
+	}
+	return sp
+}
+
+// fileOf finds the package and parsed file containing pos, so that snippets from _test.go files are resolved against
+// their test package.
+func fileOf(fSet *token.FileSet, pkgs []*packages.Package, pos token.Pos) (*packages.Package, *ast.File) {
+	tf := fSet.File(pos)
+	for _, p := range pkgs {
+		for _, f := range p.Syntax {
+			if fSet.File(f.Pos()) == tf {
+				return p, f
+			}
+		}
+	}
+	return nil, nil
+}
+
+// importsFor renders an import block covering the packages referenced within the spans, using the names under
+// which the enclosing files import them.
+func importsFor(fSet *token.FileSet, pkgs []*packages.Package, spans ...span) (string, error) {
+	used := make(map[string]*ast.ImportSpec)
+	for _, sp := range spans {
+		pkg, file := fileOf(fSet, pkgs, sp.pos)
+		if file == nil || pkg.TypesInfo == nil {
+			return "", errors.New("no type information available for snippet")
+		}
+
+		// the file's imports keyed by path
+		specs := make(map[string]*ast.ImportSpec, len(file.Imports))
+		for _, spec := range file.Imports {
+			if path, err := strconv.Unquote(spec.Path.Value); err == nil {
+				specs[path] = spec
+			}
+		}
+
+		for id, obj := range pkg.TypesInfo.Uses {
+			if id.Pos() < sp.pos || id.Pos() >= sp.end || obj == nil {
+				continue
+			}
+			var path string
+			switch o := obj.(type) {
+			case *types.PkgName:
+				path = o.Imported().Path()
+			default:
+				// dot imports refer directly to objects in another package's scope
+				if o.Pkg() == nil || o.Pkg() == pkg.Types || o.Parent() != o.Pkg().Scope() {
+					continue
+				}
+				if spec := specs[o.Pkg().Path()]; spec == nil || spec.Name == nil || spec.Name.Name != "." {
+					continue
+				}
+				path = o.Pkg().Path()
+			}
+			if _, ok := used[path]; !ok && specs[path] != nil {
+				used[path] = specs[path]
+			}
+		}
+	}
+	if len(used) == 0 {
+		return "", nil
+	}
+
+	var std, other []string
+	for path, spec := range used {
+		line := strconv.Quote(path)
+		if spec.Name != nil {
+			line = spec.Name.Name + " " + line
+		}
+		if strings.Contains(strings.SplitN(path, "/", 2)[0], ".") {
+			other = append(other, line)
+		} else {
+			std = append(std, line)
+		}
+	}
+	sort.Slice(std, func(i, j int) bool { return importPath(std[i]) < importPath(std[j]) })
+	sort.Slice(other, func(i, j int) bool { return importPath(other[i]) < importPath(other[j]) })
+
+	var b strings.Builder
+	b.WriteString("import (\n")
+	for _, group := range [][]string{std, other} {
+		if len(group) == 0 {
+			continue
+		}
+		if b.Len() > len("import (\n") {
+			b.WriteByte('\n')
+		}
+		for _, l := range group {
+			b.WriteString("\t" + l + "\n")
+		}
+	}
+	b.WriteString(")")
+	return b.String(), nil
+}
+
+// importPath extracts the path from a rendered import spec so that aliases don't affect ordering.
+func importPath(line string) string {
+	return line[strings.Index(line, `"`):]
+}
@@ -0,0 +1,434 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+const (
+	// dialectJSON is strict JSON, which may be reformatted
+	dialectJSON = "json"
+	// dialectJSONC is JSON with comments and trailing commas, as in tsconfig.json or VS Code settings
+	dialectJSONC = "jsonc"
+	// dialectJSON5 further allows single-quoted strings, unquoted keys, and looser numbers
+	dialectJSON5 = "json5"
+	// dialectNDJSON is a stream of JSON values, like NDJSON logs, from which a leading index in the fragment selects
+	dialectNDJSON = "ndjson"
+)
+
+// dialectForPath picks a dialect by file extension, defaulting to strict JSON.
+func dialectForPath(pat string) string {
+	switch strings.ToLower(filepath.Ext(pat)) {
+	case ".jsonc":
+		return dialectJSONC
+	case ".json5":
+		return dialectJSON5
+	case ".ndjson", ".jsonl":
+		return dialectNDJSON
+	}
+	return dialectJSON
+}
+
+// jsoncNode is a value parsed from JSONC or JSON5, remembering where it was written.
+type jsoncNode struct {
+	start, end int
+	// kind is '{', '[', '"', 'n' for numbers, 'b' for booleans, or 'z' for null
+	kind byte
+	// keys holds an object's keys, in the order of children
+	keys     []string
+	children []*jsoncNode
+}
+
+func (n *jsoncNode) describe() string {
+	switch n.kind {
+	case '"':
+		return "a string"
+	case 'n':
+		return "a number"
+	case 'b':
+		return "a boolean"
+	}
+	return "null"
+}
+
+// parseJSONC finds the value at jsonPath and returns its original text, dedented, so that comments survive.
+func parseJSONC(src []byte, jsonPath, dialect string) (string, error) {
+	parts, err := parseJSONPointer(jsonPath)
+	if err != nil {
+		return "", err
+	}
+	p := jsoncParser{src: src, json5: dialect == dialectJSON5}
+	node, err := p.parseDocument()
+	if err != nil {
+		return "", err
+	}
+	if len(parts) == 0 { // the whole document keeps any comments around it
+		return strings.TrimSpace(string(src)), nil
+	}
+
+	for depth, part := range parts {
+		prefix := formatJSONPointer(parts[:depth])
+		switch node.kind {
+		case '{':
+			found := false
+			for i, k := range node.keys {
+				if k == part { // later duplicates win, as with encoding/json
+					node, found = node.children[i], true
+				}
+			}
+			if !found {
+				return "", fmt.Errorf("no key %q at %q; available keys: %v", part, prefix, summarizeKeys(node.keys))
+			}
+		case '[':
+			idx, err := parseArrayIndex(part)
+			if err != nil {
+				return "", fmt.Errorf("at %q: %w", prefix, err)
+			}
+			if idx >= len(node.children) {
+				return "", fmt.Errorf("no index %d at %q; %v", idx, prefix, summarizeIndices(len(node.children)))
+			}
+			node = node.children[idx]
+		default:
+			return "", fmt.Errorf("can't resolve %q: %q is %v, not an object or array", part, prefix, node.describe())
+		}
+	}
+
+	return jsoncNodeText(src, node), nil
+}
+
+// jsoncNodeText returns the text of node, with its continuation lines dedented by the indentation of the line on
+// which it starts.
+func jsoncNodeText(src []byte, node *jsoncNode) string {
+	lineStart := strings.LastIndexByte(string(src[:node.start]), '\n') + 1
+	line := string(src[lineStart:node.start])
+	lead := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+
+	lines := strings.Split(strings.Replace(string(src[node.start:node.end]), "\r\n", "\n", -1), "\n")
+	for i := 1; i < len(lines); i++ {
+		lines[i] = strings.TrimPrefix(lines[i], lead)
+	}
+	return strings.Join(lines, "\n")
+}
+
+type jsoncParser struct {
+	src   []byte
+	pos   int
+	json5 bool
+}
+
+func (p *jsoncParser) parseDocument() (*jsoncNode, error) {
+	node, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.skip(); err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.src) {
+		return nil, p.errorf("unexpected %q after the document", p.src[p.pos])
+	}
+	return node, nil
+}
+
+func (p *jsoncParser) errorf(format string, args ...interface{}) error {
+	line := 1 + strings.Count(string(p.src[:p.pos]), "\n")
+	col := p.pos - strings.LastIndexByte(string(p.src[:p.pos]), '\n')
+	return fmt.Errorf("line %d, column %d: %v", line, col, fmt.Sprintf(format, args...))
+}
+
+// skip advances past whitespace and comments.
+func (p *jsoncParser) skip() error {
+	for p.pos < len(p.src) {
+		switch c := p.src[p.pos]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			p.pos++
+		case p.json5 && (c == '\v' || c == '\f'):
+			p.pos++
+		case c == '/' && p.pos+1 < len(p.src) && p.src[p.pos+1] == '/':
+			if i := strings.IndexByte(string(p.src[p.pos:]), '\n'); i >= 0 {
+				p.pos += i + 1
+			} else {
+				p.pos = len(p.src)
+			}
+		case c == '/' && p.pos+1 < len(p.src) && p.src[p.pos+1] == '*':
+			i := strings.Index(string(p.src[p.pos+2:]), "*/")
+			if i < 0 {
+				return p.errorf("unterminated comment")
+			}
+			p.pos += i + 4
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+func (p *jsoncParser) parseValue() (*jsoncNode, error) {
+	if err := p.skip(); err != nil {
+		return nil, err
+	}
+	if p.pos >= len(p.src) {
+		return nil, p.errorf("unexpected end of input")
+	}
+	start := p.pos
+	switch c := p.src[p.pos]; {
+	case c == '{':
+		return p.parseObject()
+	case c == '[':
+		return p.parseArray()
+	case c == '"' || (c == '\'' && p.json5):
+		if _, err := p.parseString(); err != nil {
+			return nil, err
+		}
+		return &jsoncNode{start: start, end: p.pos, kind: '"'}, nil
+	}
+
+	word := p.scanWord()
+	switch {
+	case word == "true" || word == "false":
+		return &jsoncNode{start: start, end: p.pos, kind: 'b'}, nil
+	case word == "null":
+		return &jsoncNode{start: start, end: p.pos, kind: 'z'}, nil
+	case p.validNumber(word):
+		return &jsoncNode{start: start, end: p.pos, kind: 'n'}, nil
+	case word == "":
+		return nil, p.errorf("unexpected %q", p.src[p.pos])
+	}
+	p.pos = start
+	return nil, p.errorf("invalid value %q", word)
+}
+
+func (p *jsoncParser) parseObject() (*jsoncNode, error) {
+	node := &jsoncNode{start: p.pos, kind: '{'}
+	p.pos++ // '{'
+	for {
+		if err := p.skip(); err != nil {
+			return nil, err
+		}
+		if p.pos < len(p.src) && p.src[p.pos] == '}' {
+			break
+		}
+
+		key, err := p.parseKey()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.skip(); err != nil {
+			return nil, err
+		}
+		if p.pos >= len(p.src) || p.src[p.pos] != ':' {
+			return nil, p.errorf("expected ':' after key %q", key)
+		}
+		p.pos++
+		val, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		node.keys, node.children = append(node.keys, key), append(node.children, val)
+
+		if more, err := p.parseComma('}'); err != nil {
+			return nil, err
+		} else if !more {
+			break
+		}
+	}
+	p.pos++ // '}'
+	node.end = p.pos
+	return node, nil
+}
+
+func (p *jsoncParser) parseArray() (*jsoncNode, error) {
+	node := &jsoncNode{start: p.pos, kind: '['}
+	p.pos++ // '['
+	for {
+		if err := p.skip(); err != nil {
+			return nil, err
+		}
+		if p.pos < len(p.src) && p.src[p.pos] == ']' {
+			break
+		}
+		val, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		node.children = append(node.children, val)
+
+		if more, err := p.parseComma(']'); err != nil {
+			return nil, err
+		} else if !more {
+			break
+		}
+	}
+	p.pos++ // ']'
+	node.end = p.pos
+	return node, nil
+}
+
+// parseComma consumes the comma after a member, reporting whether another may follow; both dialects allow a trailing
+// comma before the closing delimiter.
+func (p *jsoncParser) parseComma(closing byte) (bool, error) {
+	if err := p.skip(); err != nil {
+		return false, err
+	}
+	switch {
+	case p.pos >= len(p.src):
+		return false, p.errorf("unexpected end of input; expected ',' or %q", closing)
+	case p.src[p.pos] == ',':
+		p.pos++
+		return true, nil
+	case p.src[p.pos] == closing:
+		return false, nil
+	}
+	return false, p.errorf("unexpected %q; expected ',' or %q", p.src[p.pos], closing)
+}
+
+func (p *jsoncParser) parseKey() (string, error) {
+	if p.pos >= len(p.src) {
+		return "", p.errorf("unexpected end of input; expected a key")
+	}
+	if c := p.src[p.pos]; c == '"' || (c == '\'' && p.json5) {
+		return p.parseString()
+	}
+	if p.json5 {
+		if word := p.scanWord(); word != "" && strings.IndexAny(word[:1], "0123456789+-.") < 0 {
+			return word, nil
+		}
+	}
+	return "", p.errorf("unexpected %q; expected a key", p.src[p.pos])
+}
+
+// scanWord consumes a run of identifier or number characters.
+func (p *jsoncParser) scanWord() string {
+	start := p.pos
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if c == '_' || c == '$' || c == '.' || c == '+' || c == '-' || c >= 0x80 ||
+			('0' <= c && c <= '9') || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') {
+			p.pos++
+			continue
+		}
+		break
+	}
+	return string(p.src[start:p.pos])
+}
+
+func (p *jsoncParser) validNumber(word string) bool {
+	if !p.json5 {
+		return json.Valid([]byte(word))
+	}
+	unsigned := strings.TrimLeft(word, "+-")
+	if len(word)-len(unsigned) > 1 {
+		return false
+	}
+	switch lower := strings.ToLower(unsigned); {
+	case unsigned == "Infinity" || unsigned == "NaN":
+		return true
+	case strings.HasPrefix(lower, "0x"):
+		_, err := strconv.ParseUint(unsigned[2:], 16, 64)
+		return err == nil
+	case strings.ContainsAny(lower, "inx"):
+		return false
+	}
+	_, err := strconv.ParseFloat(unsigned, 64)
+	return err == nil
+}
+
+// parseString consumes a quoted string, returning its value.
+func (p *jsoncParser) parseString() (string, error) {
+	quote := p.src[p.pos]
+	start := p.pos
+	p.pos++
+
+	var b strings.Builder
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		switch {
+		case c == quote:
+			p.pos++
+			return b.String(), nil
+		case c == '\n' || c == '\r':
+			return "", p.errorf("unterminated string")
+		case c != '\\':
+			b.WriteByte(c)
+			p.pos++
+			continue
+		}
+
+		p.pos++ // '\\'
+		if p.pos >= len(p.src) {
+			break
+		}
+		esc := p.src[p.pos]
+		p.pos++
+		switch esc {
+		case '"', '\\', '/':
+			b.WriteByte(esc)
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'u':
+			r, err := p.parseHex(4)
+			if err != nil {
+				return "", err
+			}
+			if utf16.IsSurrogate(r) && strings.HasPrefix(string(p.src[p.pos:]), "\\u") {
+				p.pos += 2
+				lo, err := p.parseHex(4)
+				if err != nil {
+					return "", err
+				}
+				r = utf16.DecodeRune(r, lo)
+			}
+			b.WriteRune(r)
+		default:
+			if !p.json5 {
+				p.pos -= 2
+				return "", p.errorf("invalid escape %q", p.src[p.pos:p.pos+2])
+			}
+			switch esc {
+			case '\n': // line continuation
+			case '\r':
+				if p.pos < len(p.src) && p.src[p.pos] == '\n' {
+					p.pos++
+				}
+			case 'v':
+				b.WriteByte('\v')
+			case '0':
+				b.WriteByte(0)
+			case 'x':
+				r, err := p.parseHex(2)
+				if err != nil {
+					return "", err
+				}
+				b.WriteRune(r)
+			default:
+				b.WriteByte(esc)
+			}
+		}
+	}
+	p.pos = start
+	return "", p.errorf("unterminated string")
+}
+
+func (p *jsoncParser) parseHex(n int) (rune, error) {
+	if p.pos+n > len(p.src) {
+		return 0, p.errorf("invalid escape")
+	}
+	v, err := strconv.ParseUint(string(p.src[p.pos:p.pos+n]), 16, 32)
+	if err != nil {
+		return 0, p.errorf("invalid escape %q", p.src[p.pos-2:p.pos+n])
+	}
+	p.pos += n
+	return rune(v), nil
+}
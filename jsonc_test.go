@@ -0,0 +1,53 @@
+package main
+
+import "testing"
+
+func Test_parseJSONC(t *testing.T) {
+	const src = `{
+  // comment
+  "a": {
+    "b": [1, 2,], /* trailing */
+  },
+  "s": "xé",
+}`
+	const src5 = `{
+  unquoted: 'single',
+  nums: [+1, .5, 5., 0xFF, -Infinity, NaN],
+  nested: {
+    deep: true, // kept
+  },
+}`
+	for _, c := range []struct {
+		name, src, path, dialect, out, err string
+	}{
+		{"whole", "  " + src + "\n", "", dialectJSONC, src, ""},
+		{"object", src, "/a", dialectJSONC, "{\n  \"b\": [1, 2,], /* trailing */\n}", ""},
+		{"element", src, "/a/b/1", dialectJSONC, "2", ""},
+		{"string", src, "/s", dialectJSONC, `"xé"`, ""},
+		{"missing key", src, "/b", dialectJSONC, "", `no key "b" at ""; available keys: "a", "s"`},
+		{"missing index", src, "/a/b/2", dialectJSONC, "", `no index 2 at "/a/b"; available indices: 0-1`},
+		{"scalar", src, "/s/x", dialectJSONC, "", `can't resolve "x": "/s" is a string, not an object or array`},
+		{"json5", src5, "/nested", dialectJSON5, "{\n  deep: true, // kept\n}", ""},
+		{"json5 number", src5, "/nums/3", dialectJSON5, "0xFF", ""},
+		{"json5 in jsonc", src5, "/nested", dialectJSONC, "", `line 2, column 3: unexpected 'u'; expected a key`},
+		{"bad number", `[01]`, "", dialectJSONC, "", `line 1, column 2: invalid value "01"`},
+		{"unterminated comment", `{} /* x`, "", dialectJSONC, "", `line 1, column 4: unterminated comment`},
+		{"missing comma", `[1 2]`, "", dialectJSONC, "", `line 1, column 4: unexpected '2'; expected ',' or ']'`},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			out, err := parseJSONC([]byte(c.src), c.path, c.dialect)
+			if err != nil {
+				if err.Error() != c.err {
+					t.Fatalf("wanted error %q but got %q", c.err, err)
+				}
+				return
+			}
+			if c.err != "" {
+				t.Fatalf("wanted error %q but got none", c.err)
+			}
+			if out != c.out {
+				t.Errorf("wanted:\n%v\ngot:\n%v", c.out, out)
+			}
+		})
+	}
+}
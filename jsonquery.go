@@ -0,0 +1,773 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// jsonQuery is a compiled query in a subset of JSONPath, with a couple of jq-isms: `[]` is a wildcard, the leading `$`
+// is optional, and a path can be piped into an object construction, as in `$.plugins[?(@.enabled)] | {name, v: .meta.version}`.
+type jsonQuery struct {
+	src       string
+	path      []querySegment
+	construct []queryField
+	// definite queries, which use only names and indices, select at most one value
+	definite bool
+}
+
+func (q *jsonQuery) String() string {
+	return q.src
+}
+
+type querySegment struct {
+	recursive bool // `..`
+	sel       querySelector
+}
+
+type querySelector interface {
+	// apply appends the values selected from v to out.
+	apply(v, root interface{}, out []interface{}) []interface{}
+}
+
+type queryField struct {
+	key string
+	val queryOperand
+}
+
+// eval runs the query against root, returning the selected values in document order.
+func (q *jsonQuery) eval(root interface{}) []interface{} {
+	res := evalQueryPath(q.path, root, root)
+	if q.construct == nil {
+		return res
+	}
+	for i, v := range res {
+		obj := newJSONObject()
+		for _, f := range q.construct {
+			obj.set(f.key, f.val.collect(v, root))
+		}
+		res[i] = obj
+	}
+	return res
+}
+
+func evalQueryPath(segs []querySegment, cur, root interface{}) []interface{} {
+	nodes := []interface{}{cur}
+	for _, seg := range segs {
+		var next []interface{}
+		for _, n := range nodes {
+			if !seg.recursive {
+				next = seg.sel.apply(n, root, next)
+				continue
+			}
+			for _, d := range descendants(n, nil) {
+				next = seg.sel.apply(d, root, next)
+			}
+		}
+		nodes = next
+	}
+	return nodes
+}
+
+// descendants appends v and everything nested within it, in document order.
+func descendants(v interface{}, out []interface{}) []interface{} {
+	out = append(out, v)
+	for _, c := range children(v) {
+		out = descendants(c, out)
+	}
+	return out
+}
+
+func children(v interface{}) []interface{} {
+	switch v := v.(type) {
+	case *jsonObject:
+		vals := make([]interface{}, 0, len(v.keys))
+		for _, k := range v.keys {
+			vals = append(vals, v.vals[k])
+		}
+		return vals
+	case []interface{}:
+		return v
+	}
+	return nil
+}
+
+type nameSelector []string
+
+func (s nameSelector) apply(v, _ interface{}, out []interface{}) []interface{} {
+	if obj, ok := v.(*jsonObject); ok {
+		for _, n := range s {
+			if c, ok := obj.get(n); ok {
+				out = append(out, c)
+			}
+		}
+	}
+	return out
+}
+
+type indexSelector []int
+
+func (s indexSelector) apply(v, _ interface{}, out []interface{}) []interface{} {
+	if arr, ok := v.([]interface{}); ok {
+		for _, i := range s {
+			if i < 0 {
+				i += len(arr)
+			}
+			if i >= 0 && i < len(arr) {
+				out = append(out, arr[i])
+			}
+		}
+	}
+	return out
+}
+
+type wildcardSelector struct{}
+
+func (wildcardSelector) apply(v, _ interface{}, out []interface{}) []interface{} {
+	return append(out, children(v)...)
+}
+
+type sliceSelector struct {
+	start, end *int
+	step       int
+}
+
+func (s sliceSelector) apply(v, _ interface{}, out []interface{}) []interface{} {
+	arr, ok := v.([]interface{})
+	if !ok {
+		return out
+	}
+	n := len(arr)
+	bound := func(i *int, def, min, max int) int {
+		if i == nil {
+			return def
+		}
+		b := *i
+		if b < 0 {
+			b += n
+		}
+		if b < min {
+			return min
+		}
+		if b > max {
+			return max
+		}
+		return b
+	}
+	if s.step > 0 {
+		for i := bound(s.start, 0, 0, n); i < bound(s.end, n, 0, n); i += s.step {
+			out = append(out, arr[i])
+		}
+		return out
+	}
+	for i := bound(s.start, n-1, -1, n-1); i > bound(s.end, -1, -1, n-1); i += s.step {
+		out = append(out, arr[i])
+	}
+	return out
+}
+
+type filterSelector struct {
+	expr queryOperand
+}
+
+func (s filterSelector) apply(v, root interface{}, out []interface{}) []interface{} {
+	for _, c := range children(v) {
+		if truthy(s.expr.eval(c, root)) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// queryOperand is anything which can appear within a filter or an object construction.
+type queryOperand interface {
+	// eval returns the operand's value relative to cur, and whether it has one.
+	eval(cur, root interface{}) (interface{}, bool)
+	// collect returns every value the operand selects: nil for none, the value for one, and an array otherwise.
+	collect(cur, root interface{}) interface{}
+}
+
+type pathOperand struct {
+	fromRoot bool
+	path     []querySegment
+}
+
+func (o pathOperand) results(cur, root interface{}) []interface{} {
+	if o.fromRoot {
+		cur = root
+	}
+	return evalQueryPath(o.path, cur, root)
+}
+
+func (o pathOperand) eval(cur, root interface{}) (interface{}, bool) {
+	if res := o.results(cur, root); len(res) > 0 {
+		return res[0], true
+	}
+	return nil, false
+}
+
+func (o pathOperand) collect(cur, root interface{}) interface{} {
+	switch res := o.results(cur, root); len(res) {
+	case 0:
+		return nil
+	case 1:
+		return res[0]
+	default:
+		return res
+	}
+}
+
+type literalOperand struct {
+	val interface{}
+}
+
+func (o literalOperand) eval(_, _ interface{}) (interface{}, bool) {
+	return o.val, true
+}
+
+func (o literalOperand) collect(_, _ interface{}) interface{} {
+	return o.val
+}
+
+// boolOperand covers comparisons and logical operators, which always have a value.
+type boolOperand func(cur, root interface{}) bool
+
+func (o boolOperand) eval(cur, root interface{}) (interface{}, bool) {
+	return o(cur, root), true
+}
+
+func (o boolOperand) collect(cur, root interface{}) interface{} {
+	return o(cur, root)
+}
+
+// truthy follows jq: everything but false, null, and missing values is true.
+func truthy(v interface{}, ok bool) bool {
+	return ok && v != nil && v != false
+}
+
+func compareJSON(op string, a, b interface{}) bool {
+	if af, ok := jsonNumber(a); ok {
+		if bf, ok := jsonNumber(b); ok {
+			switch op {
+			case "==":
+				return af == bf
+			case "!=":
+				return af != bf
+			case "<":
+				return af < bf
+			case "<=":
+				return af <= bf
+			case ">":
+				return af > bf
+			case ">=":
+				return af >= bf
+			}
+		}
+	}
+	if as, ok := a.(string); ok {
+		if bs, ok := b.(string); ok {
+			switch op {
+			case "==":
+				return as == bs
+			case "!=":
+				return as != bs
+			case "<":
+				return as < bs
+			case "<=":
+				return as <= bs
+			case ">":
+				return as > bs
+			case ">=":
+				return as >= bs
+			}
+		}
+	}
+	switch op {
+	case "==":
+		return equalJSON(a, b)
+	case "!=":
+		return !equalJSON(a, b)
+	}
+	return false
+}
+
+func jsonNumber(v interface{}) (float64, bool) {
+	if n, ok := v.(json.Number); ok {
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func equalJSON(a, b interface{}) bool {
+	switch a := a.(type) {
+	case *jsonObject:
+		bo, ok := b.(*jsonObject)
+		if !ok || len(a.keys) != len(bo.keys) {
+			return false
+		}
+		for _, k := range a.keys {
+			if bv, ok := bo.get(k); !ok || !equalJSON(a.vals[k], bv) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		ba, ok := b.([]interface{})
+		if !ok || len(a) != len(ba) {
+			return false
+		}
+		for i := range a {
+			if !equalJSON(a[i], ba[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	if af, ok := jsonNumber(a); ok {
+		bf, ok := jsonNumber(b)
+		return ok && af == bf
+	}
+	return a == b
+}
+
+// parseJSONQuery compiles a query like `$.plugins[*].name`, `$..servers[0:3]`, or
+// `$.items[?(@.id == 'foo')] | {id, port: .spec.port}`.
+func parseJSONQuery(s string) (*jsonQuery, error) {
+	p := &queryParser{s: s}
+	q := &jsonQuery{src: s}
+
+	p.skipSpace()
+	p.accept("$")
+
+	var err error
+	if q.path, q.definite, err = p.parsePath(); err != nil {
+		return nil, err
+	}
+	if p.consume("|") {
+		if q.construct, err = p.parseConstruct(); err != nil {
+			return nil, err
+		}
+	}
+	if p.skipSpace(); p.pos != len(p.s) {
+		return nil, p.errorf("unexpected %q", p.s[p.pos:])
+	}
+	return q, nil
+}
+
+// jsonFilter is a compiled filter expression, as used within `[?(...)]`, for matching whole values.
+type jsonFilter struct {
+	src  string
+	expr queryOperand
+}
+
+func (f *jsonFilter) String() string {
+	return f.src
+}
+
+func (f *jsonFilter) match(v interface{}) bool {
+	return truthy(f.expr.eval(v, v))
+}
+
+// parseJSONFilter compiles a filter expression like `@.level == 'error' && @.status >= 500`.
+func parseJSONFilter(s string) (*jsonFilter, error) {
+	p := &queryParser{s: s, what: "filter"}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.skipSpace(); p.pos != len(p.s) {
+		return nil, p.errorf("unexpected %q", p.s[p.pos:])
+	}
+	return &jsonFilter{src: s, expr: expr}, nil
+}
+
+type queryParser struct {
+	s   string
+	pos int
+	// what is being parsed, for errors; defaults to query
+	what string
+}
+
+func (p *queryParser) errorf(format string, args ...interface{}) error {
+	what := p.what
+	if what == "" {
+		what = "query"
+	}
+	return fmt.Errorf("invalid %v %q at offset %d: %v", what, p.s, p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *queryParser) skipSpace() {
+	for p.pos < len(p.s) && (p.s[p.pos] == ' ' || p.s[p.pos] == '\t' || p.s[p.pos] == '\n') {
+		p.pos++
+	}
+}
+
+func (p *queryParser) peek() byte {
+	if p.pos < len(p.s) {
+		return p.s[p.pos]
+	}
+	return 0
+}
+
+// accept consumes tok if it's next, without skipping space.
+func (p *queryParser) accept(tok string) bool {
+	if strings.HasPrefix(p.s[p.pos:], tok) {
+		p.pos += len(tok)
+		return true
+	}
+	return false
+}
+
+// consume consumes tok if it's next after any space.
+func (p *queryParser) consume(tok string) bool {
+	p.skipSpace()
+	return p.accept(tok)
+}
+
+func (p *queryParser) expect(tok string) error {
+	if !p.consume(tok) {
+		return p.errorf("expected %q", tok)
+	}
+	return nil
+}
+
+func isIdentByte(c byte, first bool) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (!first && (c == '-' || (c >= '0' && c <= '9')))
+}
+
+func (p *queryParser) ident() string {
+	start := p.pos
+	for p.pos < len(p.s) && isIdentByte(p.s[p.pos], p.pos == start) {
+		p.pos++
+	}
+	return p.s[start:p.pos]
+}
+
+func (p *queryParser) parsePath() ([]querySegment, bool, error) {
+	var (
+		segs     []querySegment
+		definite = true
+	)
+	for {
+		var seg querySegment
+		switch {
+		case p.accept(".."):
+			seg.recursive = true
+			definite = false
+			switch {
+			case p.accept("*"):
+				seg.sel = wildcardSelector{}
+			case p.peek() == '[':
+				p.pos++
+				sel, def, err := p.parseBracket()
+				if err != nil {
+					return nil, false, err
+				}
+				seg.sel, definite = sel, definite && def
+			default:
+				name := p.ident()
+				if name == "" {
+					return nil, false, p.errorf("expected a name after '..'")
+				}
+				seg.sel = nameSelector{name}
+			}
+		case p.accept("."):
+			switch {
+			case p.accept("*"):
+				seg.sel = wildcardSelector{}
+				definite = false
+			case p.accept("["): // jq's .[0]
+				sel, def, err := p.parseBracket()
+				if err != nil {
+					return nil, false, err
+				}
+				seg.sel, definite = sel, definite && def
+			default:
+				name := p.ident()
+				if name == "" {
+					if len(segs) == 0 { // jq's identity
+						return segs, definite, nil
+					}
+					return nil, false, p.errorf("expected a name after '.'")
+				}
+				seg.sel = nameSelector{name}
+			}
+		case p.accept("["):
+			sel, def, err := p.parseBracket()
+			if err != nil {
+				return nil, false, err
+			}
+			seg.sel, definite = sel, definite && def
+		default:
+			return segs, definite, nil
+		}
+		segs = append(segs, seg)
+	}
+}
+
+// parseBracket parses the rest of a bracketed selector, returning whether it selects at most one value.
+func (p *queryParser) parseBracket() (querySelector, bool, error) {
+	p.skipSpace()
+	switch c := p.peek(); {
+	case c == ']' || c == '*':
+		p.accept("*")
+		return wildcardSelector{}, false, p.expect("]")
+	case c == '?':
+		p.pos++
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, false, err
+		}
+		return filterSelector{expr}, false, p.expect("]")
+	case c == '\'' || c == '"':
+		var names nameSelector
+		for {
+			p.skipSpace()
+			name, err := p.parseString()
+			if err != nil {
+				return nil, false, err
+			}
+			names = append(names, name)
+			if !p.consume(",") {
+				break
+			}
+		}
+		return names, len(names) == 1, p.expect("]")
+	}
+
+	first, hasFirst, err := p.parseInt()
+	if err != nil {
+		return nil, false, err
+	}
+	if p.consume(":") {
+		sel := sliceSelector{step: 1}
+		if hasFirst {
+			sel.start = &first
+		}
+		if end, ok, err := p.parseInt(); err != nil {
+			return nil, false, err
+		} else if ok {
+			sel.end = &end
+		}
+		if p.consume(":") {
+			if step, ok, err := p.parseInt(); err != nil {
+				return nil, false, err
+			} else if ok {
+				if step == 0 {
+					return nil, false, p.errorf("slice step cannot be zero")
+				}
+				sel.step = step
+			}
+		}
+		return sel, false, p.expect("]")
+	}
+	if !hasFirst {
+		return nil, false, p.errorf("expected a name, index, slice, wildcard, or filter")
+	}
+	indices := indexSelector{first}
+	for p.consume(",") {
+		i, ok, err := p.parseInt()
+		if err != nil {
+			return nil, false, err
+		}
+		if !ok {
+			return nil, false, p.errorf("expected an index")
+		}
+		indices = append(indices, i)
+	}
+	return indices, len(indices) == 1, p.expect("]")
+}
+
+func (p *queryParser) parseInt() (int, bool, error) {
+	p.skipSpace()
+	start := p.pos
+	p.accept("-")
+	for p.pos < len(p.s) && p.s[p.pos] >= '0' && p.s[p.pos] <= '9' {
+		p.pos++
+	}
+	if p.pos == start {
+		return 0, false, nil
+	}
+	i, err := strconv.Atoi(p.s[start:p.pos])
+	if err != nil {
+		p.pos = start
+		return 0, false, p.errorf("invalid integer: %v", err)
+	}
+	return i, true, nil
+}
+
+func (p *queryParser) parseString() (string, error) {
+	quote := p.peek()
+	if quote != '\'' && quote != '"' {
+		return "", p.errorf("expected a string")
+	}
+	var b strings.Builder
+	for i := p.pos + 1; i < len(p.s); i++ {
+		switch c := p.s[i]; {
+		case c == '\\' && i+1 < len(p.s):
+			i++
+			b.WriteByte(p.s[i])
+		case c == quote:
+			p.pos = i + 1
+			return b.String(), nil
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+func (p *queryParser) parseOr() (queryOperand, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.consume("||") {
+		l, r := left, queryOperand(nil)
+		if r, err = p.parseAnd(); err != nil {
+			return nil, err
+		}
+		left = boolOperand(func(cur, root interface{}) bool {
+			return truthy(l.eval(cur, root)) || truthy(r.eval(cur, root))
+		})
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseAnd() (queryOperand, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.consume("&&") {
+		l, r := left, queryOperand(nil)
+		if r, err = p.parseUnary(); err != nil {
+			return nil, err
+		}
+		left = boolOperand(func(cur, root interface{}) bool {
+			return truthy(l.eval(cur, root)) && truthy(r.eval(cur, root))
+		})
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseUnary() (queryOperand, error) {
+	if p.consume("!") {
+		o, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return boolOperand(func(cur, root interface{}) bool {
+			return !truthy(o.eval(cur, root))
+		}), nil
+	}
+	return p.parseComparison()
+}
+
+func (p *queryParser) parseComparison() (queryOperand, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if !p.consume(op) {
+			continue
+		}
+		right, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return boolOperand(func(cur, root interface{}) bool {
+			l, lOK := left.eval(cur, root)
+			r, rOK := right.eval(cur, root)
+			if !lOK || !rOK { // missing values compare unequal to everything
+				return op == "!="
+			}
+			return compareJSON(op, l, r)
+		}), nil
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseOperand() (queryOperand, error) {
+	p.skipSpace()
+	switch c := p.peek(); {
+	case c == '(':
+		p.pos++
+		o, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return o, p.expect(")")
+	case c == '@' || c == '$':
+		p.pos++
+		path, _, err := p.parsePath()
+		return pathOperand{fromRoot: c == '$', path: path}, err
+	case c == '.' || c == '[':
+		path, _, err := p.parsePath()
+		return pathOperand{path: path}, err
+	case c == '\'' || c == '"':
+		s, err := p.parseString()
+		return literalOperand{s}, err
+	case c == '-' || (c >= '0' && c <= '9'):
+		start := p.pos
+		p.pos++
+		for p.pos < len(p.s) && strings.IndexByte("0123456789.eE+-", p.s[p.pos]) >= 0 {
+			p.pos++
+		}
+		n := json.Number(p.s[start:p.pos])
+		if _, err := n.Float64(); err != nil {
+			p.pos = start
+			return nil, p.errorf("invalid number %q", string(n))
+		}
+		return literalOperand{n}, nil
+	}
+	switch start, word := p.pos, p.ident(); word {
+	case "true":
+		return literalOperand{true}, nil
+	case "false":
+		return literalOperand{false}, nil
+	case "null":
+		return literalOperand{nil}, nil
+	default:
+		p.pos = start
+		return nil, p.errorf("expected a path, literal, or '('")
+	}
+}
+
+func (p *queryParser) parseConstruct() ([]queryField, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	var fields []queryField
+	for {
+		p.skipSpace()
+		var (
+			key string
+			err error
+		)
+		if c := p.peek(); c == '\'' || c == '"' {
+			key, err = p.parseString()
+		} else if key = p.ident(); key == "" {
+			err = p.errorf("expected a key")
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		f := queryField{key: key, val: pathOperand{path: []querySegment{{sel: nameSelector{key}}}}}
+		if p.consume(":") {
+			if f.val, err = p.parseOperand(); err != nil {
+				return nil, err
+			}
+		}
+		fields = append(fields, f)
+
+		if !p.consume(",") {
+			break
+		}
+	}
+	return fields, p.expect("}")
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func Test_jsonQuery_eval(t *testing.T) {
+	const doc = `{
+	"servers": [
+		{"name": "a", "port": 80, "tags": ["web"]},
+		{"name": "b", "port": 8080, "tags": []},
+		{"name": "c", "port": 443, "tags": ["web", "tls"], "backup": {"name": "c2", "port": 444}}
+	],
+	"name": "root"
+}`
+	dec := json.NewDecoder(strings.NewReader(doc))
+	dec.UseNumber()
+	root, err := decodeJSON(dec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		query, out string
+		definite   bool
+	}{
+		{`$.name`, `["root"]`, true},
+		{`.servers[1].name`, `["b"]`, true},
+		{`$['servers'][-1]['name']`, `["c"]`, true},
+		{`$.servers[*].port`, `[80,8080,443]`, false},
+		{`$.servers[].port`, `[80,8080,443]`, false},
+		{`$.servers[0,2].name`, `["a","c"]`, false},
+		{`$.servers[1:].name`, `["b","c"]`, false},
+		{`$.servers[:-1].name`, `["a","b"]`, false},
+		{`$.servers[::-1].name`, `["c","b","a"]`, false},
+		{`$.servers[::2].name`, `["a","c"]`, false},
+		{`$..port`, `[80,8080,443,444]`, false},
+		{`$.servers[?(@.port > 100)].name`, `["b","c"]`, false},
+		{`$.servers[?(@.port == 80 || @.name == 'c')].name`, `["a","c"]`, false},
+		{`$.servers[?(@.backup)].name`, `["c"]`, false},
+		{`$.servers[?(!@.backup && @.port != 80)].name`, `["b"]`, false},
+		{`$.servers[?(@.tags[0] == 'web')].name`, `["a","c"]`, false},
+		{`$.servers[?(@.name == $.servers[1].name)].port`, `[8080]`, false},
+		{`$.servers[*] | {name, p: .port, tags}`, `[{"name":"a","p":80,"tags":["web"]},{"name":"b","p":8080,"tags":[]},{"name":"c","p":443,"tags":["web","tls"]}]`, false},
+		{`$.servers[*] | {name, ports: $..port}`, `[{"name":"a","ports":[80,8080,443,444]},{"name":"b","ports":[80,8080,443,444]},{"name":"c","ports":[80,8080,443,444]}]`, false},
+		{`$.servers[*] | {"first tag": .tags[0]}`, `[{"first tag":"web"},{"first tag":null},{"first tag":"web"}]`, false},
+		{`$.missing[*]`, `[]`, false},
+	} {
+		t.Run(c.query, func(t *testing.T) {
+			q, err := parseJSONQuery(c.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if q.definite != c.definite {
+				t.Errorf("wanted definite %v but got %v", c.definite, q.definite)
+			}
+			out, err := encodeJSON(q.eval(root), jsonFormat{compact: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out != c.out {
+				t.Errorf("wanted %v but got %v", c.out, out)
+			}
+		})
+	}
+}
+
+func Test_parseJSONQuery_errors(t *testing.T) {
+	for _, c := range []struct {
+		query, err string
+	}{
+		{`$.servers[`, `invalid query "$.servers[" at offset 10: expected a name, index, slice, wildcard, or filter`},
+		{`$.servers[::0]`, `invalid query "$.servers[::0]" at offset 13: slice step cannot be zero`},
+		{`$.servers[?(@.port >)]`, `invalid query "$.servers[?(@.port >)]" at offset 20: expected a path, literal, or '('`},
+		{`$.servers['a]`, `invalid query "$.servers['a]" at offset 10: unterminated string`},
+		{`$.servers | name`, `invalid query "$.servers | name" at offset 12: expected "{"`},
+		{`$.servers extra`, `invalid query "$.servers extra" at offset 10: unexpected "extra"`},
+	} {
+		t.Run(c.query, func(t *testing.T) {
+			_, err := parseJSONQuery(c.query)
+			if err == nil || err.Error() != c.err {
+				t.Errorf("wanted error %q but got %v", c.err, err)
+			}
+		})
+	}
+}
+
+func Test_encodeJSON(t *testing.T) {
+	const in = `{"b": [1, {"c": "<x>"}], "a": {}, "d": []}`
+	dec := json.NewDecoder(strings.NewReader(in))
+	dec.UseNumber()
+	v, err := decodeJSON(dec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := encodeJSON(v, jsonFormat{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `{
+  "b": [
+    1,
+    {
+      "c": "<x>"
+    }
+  ],
+  "a": {},
+  "d": []
+}`
+	if out != want {
+		t.Errorf("wanted:\n%v\ngot:\n%v", want, out)
+	}
+}
+
+func Test_jsonFormat_render(t *testing.T) {
+	const in = `{"b": {"y": [1, 2], "x": "\u00e9"}, "a": []}`
+	for _, c := range []struct {
+		name   string
+		format jsonFormat
+		out    string
+	}{
+		{"raw", jsonFormat{raw: true}, in},
+		{"compact", jsonFormat{compact: true}, `{"b":{"y":[1,2],"x":"\u00e9"},"a":[]}`},
+		{"indent", jsonFormat{indent: "\t"}, "{\n\t\"b\": {\n\t\t\"y\": [\n\t\t\t1,\n\t\t\t2\n\t\t],\n\t\t\"x\": \"\\u00e9\"\n\t},\n\t\"a\": []\n}"},
+		{"sortkeys compact", jsonFormat{compact: true, sortKeys: true}, `{"a":[],"b":{"x":"é","y":[1,2]}}`},
+		{"depth", jsonFormat{compact: true, depth: 1}, `{"b":{...},"a":[]}`},
+		{"depth 2", jsonFormat{compact: true, depth: 2}, `{"b":{"y":[...],"x":"é"},"a":[]}`},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			out, err := c.format.render(json.RawMessage(in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out != c.out {
+				t.Errorf("wanted:\n%v\ngot:\n%v", c.out, out)
+			}
+		})
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"net/url"
 	"os"
 	"strconv"
 	"strings"
@@ -15,102 +17,331 @@ import (
 func expandJSONQuotes(_ context.Context, pqs []*pullQuote) ([]*expanded, error) {
 	exp := make([]*expanded, 0, len(pqs))
 	for _, pq := range pqs {
-		parts := strings.SplitN(pq.objPath, "#", 2)
-		pat, sym := parts[0], parts[1]
+		pat, sym, _ := splitObjPath(pq.objPath) // no fragment quotes the whole document
 
-		s, err := func() (string, error) {
+		if pq.dialect == dialectJSONC || pq.dialect == dialectJSON5 {
+			src, err := ioutil.ReadFile(pat)
+			if err != nil {
+				return nil, err
+			}
+			s, err := parseJSONC(src, sym, pq.dialect)
+			if err != nil {
+				return nil, fmt.Errorf("error within %v: %w", pat, err)
+			}
+			exp = append(exp, &expanded{String: s})
+			continue
+		}
+
+		e, err := func() (*expanded, error) {
 			f, err := os.Open(pat)
 			if err != nil {
-				return "", err
+				return nil, err
 			}
-			return parse(f, sym, pq.flags&noRealignTabs != 0)
+			defer func() {
+				_ = f.Close()
+			}()
+			idx, ptr := 0, sym
+			if pq.dialect == dialectNDJSON { // a leading index selects a record from the stream
+				if idx, ptr, err = splitDocIndex(sym); err != nil {
+					return nil, err
+				}
+			}
+			var r io.Reader = f
+			switch {
+			case pq.records != nil && pq.fmt == fmtTable:
+				recs, err := selectRecords(f, ptr, pq.records, pq.where)
+				if err != nil {
+					return nil, err
+				}
+				rows := make([]interface{}, 0, len(recs))
+				for _, raw := range recs {
+					v, err := decodeRawJSON(raw)
+					if err != nil {
+						return nil, err
+					}
+					rows = append(rows, v)
+				}
+				s, err := sprintTable(rows, pq.tableFmt)
+				return &expanded{String: s}, err
+			case pq.records != nil:
+				s, err := sprintRecords(f, ptr, pq.records, pq.where)
+				return &expanded{String: s}, err
+			case idx > 0 || pq.where != nil:
+				raw, err := nthRecord(f, idx, pq.where)
+				if err != nil {
+					return nil, err
+				}
+				r = bytes.NewReader(raw)
+			}
+
+			if pq.fmt == fmtTable {
+				s, err := tableJSON(r, ptr, pq)
+				return &expanded{String: s}, err
+			}
+			if pq.fmt == fmtSchema {
+				s, err := sprintSchemaTable(r, ptr)
+				return &expanded{String: s}, err
+			}
+			if pq.query == nil {
+				s, err := parse(r, ptr, pq.jsonFmt)
+				return &expanded{String: s}, err
+			}
+			return queryJSON(r, ptr, pq.query, pq.jsonFmt, pq.flags&splitResults != 0)
 		}()
 		if err != nil {
 			return nil, err
 		}
-		exp = append(exp, &expanded{String: s})
+		exp = append(exp, e)
 	}
 	return exp, nil
 }
 
-func parse(r io.Reader, jsonPath string, noRealign bool) (string, error) {
-	parts := strings.Split(jsonPath, "/")
-	if len(parts) != 0 && parts[0] == "" {
-		parts = parts[1:]
+// queryJSON runs q against the value at jsonPath. Definite queries render their single result; otherwise, results
+// are rendered as an array or, if split, separately.
+func queryJSON(r io.Reader, jsonPath string, q *jsonQuery, format jsonFormat, split bool) (*expanded, error) {
+	raw, err := selectJSON(r, jsonPath)
+	if err != nil {
+		return nil, err
+	}
+	root, err := decodeRawJSON(raw)
+	if err != nil {
+		return nil, err
 	}
 
-	for dec := json.NewDecoder(r); ; {
-		if len(parts) == 0 {
-			var val json.RawMessage
-			if err := dec.Decode(&val); err != nil {
-				return "", fmt.Errorf("dec.Decode: %w", err)
+	res := q.eval(root)
+	switch {
+	case split:
+		if len(res) == 0 {
+			return nil, fmt.Errorf("query %q matched nothing", q)
+		}
+		e := &expanded{Blocks: make([]string, 0, len(res))}
+		for _, v := range res {
+			s, err := encodeJSON(v, format)
+			if err != nil {
+				return nil, err
 			}
-			if noRealign {
-				return string(val), nil
+			e.Blocks = append(e.Blocks, s)
+		}
+		e.String = strings.Join(e.Blocks, "\n")
+		return e, nil
+	case q.definite:
+		if len(res) == 0 {
+			return nil, fmt.Errorf("query %q matched nothing", q)
+		}
+		s, err := encodeJSON(res[0], format)
+		return &expanded{String: s}, err
+	default:
+		s, err := encodeJSON(res, format)
+		return &expanded{String: s}, err
+	}
+}
+
+// tableJSON renders the value at jsonPath, or the results of the quote's query, as a table.
+func tableJSON(r io.Reader, jsonPath string, pq *pullQuote) (string, error) {
+	raw, err := selectJSON(r, jsonPath)
+	if err != nil {
+		return "", err
+	}
+	v, err := decodeRawJSON(raw)
+	if err != nil {
+		return "", err
+	}
+	if pq.query != nil {
+		res := pq.query.eval(v)
+		if v = res; pq.query.definite {
+			if len(res) == 0 {
+				return "", fmt.Errorf("query %q matched nothing", pq.query)
 			}
-			var buf bytes.Buffer
-			if err := json.Indent(&buf, val, "", "  "); err != nil {
-				return "", err
+			v = res[0]
+		}
+	}
+	return sprintTable(v, pq.tableFmt)
+}
+
+func decodeRawJSON(raw json.RawMessage) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	return decodeJSON(dec)
+}
+
+// parse decodes only as much of r as is necessary to reach the value at jsonPath, an RFC 6901 JSON pointer in its URI
+// fragment form.
+func parse(r io.Reader, jsonPath string, format jsonFormat) (string, error) {
+	val, err := selectJSON(r, jsonPath)
+	if err != nil {
+		return "", err
+	}
+	return format.render(val)
+}
+
+// selectJSON streams through r to the raw value at jsonPath.
+func selectJSON(r io.Reader, jsonPath string) (json.RawMessage, error) {
+	parts, err := parseJSONPointer(jsonPath)
+	if err != nil {
+		return nil, err
+	}
+
+	for dec, depth := json.NewDecoder(r), 0; ; depth++ {
+		if depth == len(parts) {
+			var val json.RawMessage
+			if err := dec.Decode(&val); err != nil {
+				return nil, fmt.Errorf("dec.Decode: %w", err)
 			}
-			return buf.String(), nil
+			return val, nil
 		}
 
 		tok, err := dec.Token()
 		if err != nil {
-			return "", fmt.Errorf("dec.Token: %w", err)
+			return nil, fmt.Errorf("dec.Token: %w", err)
 		}
 
+		prefix, part := formatJSONPointer(parts[:depth]), parts[depth]
 		switch tok {
 		case json.Delim('{'):
-			err := func() error {
-				for dec.More() {
-					var (
-						key  json.Token
-						keyS string
-						ok   bool
-					)
-					if key, err = dec.Token(); err != nil {
-						return fmt.Errorf("parse key: %w", err)
-					}
-					if keyS, ok = key.(string); !ok {
-						return fmt.Errorf("expected string, got %v", key)
-					}
-					if parts[0] == keyS {
-						parts = parts[1:]
-						return nil
-					}
+			var keys []string
+			for found := false; !found; {
+				if !dec.More() {
+					return nil, fmt.Errorf("no key %q at %q; available keys: %v", part, prefix, summarizeKeys(keys))
+				}
+				key, err := dec.Token()
+				if err != nil {
+					return nil, fmt.Errorf("parse key at %q: %w", prefix, err)
+				}
+				keyS, ok := key.(string)
+				if !ok {
+					return nil, fmt.Errorf("expected string at %q, got %v", prefix, key)
+				}
+				if found = keyS == part; !found {
+					keys = append(keys, keyS)
 					// discard value
 					var j json.RawMessage
 					if err = dec.Decode(&j); err != nil {
-						return fmt.Errorf("parse value: %w", err)
+						return nil, fmt.Errorf("parse value at %q: %w", formatJSONPointer(append(parts[:depth:depth], keyS)), err)
 					}
 				}
-				return errors.New("no such value")
-			}()
-			if err != nil {
-				return "", fmt.Errorf("couldn't parse obj: %w", err)
 			}
 		case json.Delim('['):
-			idx, err := strconv.Atoi(parts[0])
+			idx, err := parseArrayIndex(part)
 			if err != nil {
-				return "", err
+				return nil, fmt.Errorf("at %q: %w", prefix, err)
 			}
-
-			err = func() error {
-				for i := 0; i <= idx && dec.More(); i++ {
-					if i == idx {
-						parts = parts[1:]
-						return nil
-					}
-					// discard value
-					var j json.RawMessage
-					_ = dec.Decode(&j)
+			for i := 0; i < idx; i++ {
+				if !dec.More() {
+					return nil, fmt.Errorf("no index %d at %q; %v", idx, prefix, summarizeIndices(i))
+				}
+				// discard value
+				var j json.RawMessage
+				if err = dec.Decode(&j); err != nil {
+					return nil, fmt.Errorf("parse value at %q: %w", formatJSONPointer(append(parts[:depth:depth], strconv.Itoa(i))), err)
 				}
-				return errors.New("no such value")
-			}()
-			if err != nil {
-				return "", fmt.Errorf("couldn't parse array: %w", err)
 			}
+			if !dec.More() {
+				return nil, fmt.Errorf("no index %d at %q; %v", idx, prefix, summarizeIndices(idx))
+			}
+		default:
+			return nil, fmt.Errorf("can't resolve %q: %q is %v, not an object or array", part, prefix, describeJSONToken(tok))
 		}
 	}
 }
+
+var jsonPointerUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
+
+// parseJSONPointer unescapes a JSON pointer in its URI fragment form, like `/paths/~1users~1%7Bid%7D`, into its
+// reference tokens. A pointer without its leading '/', like `foo/bar`, was accepted before pointers were parsed per RFC
+// 6901, so it's still read as `/foo/bar`.
+func parseJSONPointer(s string) ([]string, error) {
+	ptr, err := url.PathUnescape(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON pointer %q: %w", s, err)
+	}
+	if ptr == "" { // the whole document
+		return nil, nil
+	}
+	if ptr[0] == '/' {
+		ptr = ptr[1:]
+	}
+
+	parts := strings.Split(ptr, "/")
+	for i, p := range parts {
+		for j := 0; j < len(p); j++ {
+			if p[j] != '~' {
+				continue
+			}
+			if j+1 == len(p) || (p[j+1] != '0' && p[j+1] != '1') {
+				return nil, fmt.Errorf("invalid JSON pointer %q: '~' must be followed by '0' or '1'", s)
+			}
+			j++
+		}
+		parts[i] = jsonPointerUnescaper.Replace(p)
+	}
+	return parts, nil
+}
+
+var jsonPointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// formatJSONPointer is the inverse of parseJSONPointer, less the percent-encoding.
+func formatJSONPointer(parts []string) string {
+	var b strings.Builder
+	for _, p := range parts {
+		b.WriteString("/" + jsonPointerEscaper.Replace(p))
+	}
+	return b.String()
+}
+
+// parseArrayIndex follows RFC 6901's rules: no signs, and no leading zeroes. `-`, which refers to the element after
+// the last, never exists for our purposes.
+func parseArrayIndex(s string) (int, error) {
+	if s == "-" {
+		return 0, errors.New(`"-" refers to the nonexistent element after the last`)
+	}
+	if s == "" || (s[0] == '0' && len(s) > 1) || strings.TrimLeft(s, "0123456789") != "" {
+		return 0, fmt.Errorf("invalid array index %q", s)
+	}
+	idx, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid array index %q: %w", s, err)
+	}
+	return idx, nil
+}
+
+const maxListedKeys = 20
+
+func summarizeKeys(keys []string) string {
+	if len(keys) == 0 {
+		return "none"
+	}
+	quoted := make([]string, 0, len(keys))
+	for i, k := range keys {
+		if i == maxListedKeys {
+			quoted = append(quoted, fmt.Sprintf("and %d more", len(keys)-i))
+			break
+		}
+		quoted = append(quoted, strconv.Quote(k))
+	}
+	return strings.Join(quoted, ", ")
+}
+
+func summarizeIndices(n int) string {
+	switch n {
+	case 0:
+		return "array is empty"
+	case 1:
+		return "available index: 0"
+	}
+	return fmt.Sprintf("available indices: 0-%d", n-1)
+}
+
+func describeJSONToken(tok json.Token) string {
+	switch v := tok.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "a string"
+	case bool:
+		return "a boolean"
+	case json.Number, float64:
+		return "a number"
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
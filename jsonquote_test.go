@@ -0,0 +1,85 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_parseJSONPointer(t *testing.T) {
+	for _, c := range []struct {
+		in      string
+		out     []string
+		wantErr bool
+	}{
+		{"", nil, false},
+		{"/", []string{""}, false},
+		{"/foo/0", []string{"foo", "0"}, false},
+		{"/paths/~1users~1{id}", []string{"paths", "/users/{id}"}, false},
+		{"/paths/~1users~1%7Bid%7D", []string{"paths", "/users/{id}"}, false},
+		{"/a~0b/~01", []string{"a~b", "~1"}, false},
+		{"/%25", []string{"%"}, false},
+		{"foo", []string{"foo"}, false},
+		{"foo/0", []string{"foo", "0"}, false},
+		{"/a~2", nil, true},
+		{"/a~", nil, true},
+		{"/%zz", nil, true},
+	} {
+		t.Run(c.in, func(t *testing.T) {
+			out, err := parseJSONPointer(c.in)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("wantErr %v but %v", c.wantErr, err)
+			}
+			if !reflect.DeepEqual(out, c.out) {
+				t.Errorf("wanted %q but got %q", c.out, out)
+			}
+		})
+	}
+}
+
+func Test_parse(t *testing.T) {
+	const doc = `{
+	"paths": {
+		"/users/{id}": {"get": {"summary": "Get a user"}},
+		"/users": {"get": {"summary": "List users"}}
+	},
+	"tags": ["a", "b", "c"],
+	"empty": [],
+	"version": 3
+}`
+	for _, c := range []struct {
+		name, ptr, out, err string
+	}{
+		{"escaped key", "/paths/~1users~1{id}/get/summary", `"Get a user"`, ""},
+		{"percent-encoded", "/paths/~1users~1%7Bid%7D/get", `{"summary": "Get a user"}`, ""},
+		{"index", "/tags/2", `"c"`, ""},
+		{
+			"missing key",
+			"/paths/~1teams/get",
+			"",
+			`no key "/teams" at "/paths"; available keys: "/users/{id}", "/users"`,
+		},
+		{"out of range", "/tags/3", "", `no index 3 at "/tags"; available indices: 0-2`},
+		{"empty array", "/empty/0", "", `no index 0 at "/empty"; array is empty`},
+		{"leading zero", "/tags/01", "", `at "/tags": invalid array index "01"`},
+		{"past the end", "/tags/-", "", `at "/tags": "-" refers to the nonexistent element after the last`},
+		{"non integer", "/tags/first", "", `at "/tags": invalid array index "first"`},
+		{"scalar", "/version/major", "", `can't resolve "major": "/version" is a number, not an object or array`},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			out, err := parse(strings.NewReader(doc), c.ptr, jsonFormat{raw: true})
+			if err != nil {
+				if err.Error() != c.err {
+					t.Fatalf("wanted error %q but got %q", c.err, err)
+				}
+				return
+			}
+			if c.err != "" {
+				t.Fatalf("wanted error %q but got none", c.err)
+			}
+			if out != c.out {
+				t.Errorf("wanted %q but got %q", c.out, out)
+			}
+		})
+	}
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type schemaRow struct {
+	name, typ, required, def, values, description string
+}
+
+// sprintSchemaTable renders the properties of the JSON Schema at jsonPath as a Markdown table. `$ref`s within the
+// document are followed, and the properties of nested objects are listed with dotted names.
+func sprintSchemaTable(r io.Reader, jsonPath string) (string, error) {
+	parts, err := parseJSONPointer(jsonPath)
+	if err != nil {
+		return "", err
+	}
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	root, err := decodeJSON(dec)
+	if err != nil {
+		return "", fmt.Errorf("decoding schema: %w", err)
+	}
+	schema, err := lookupJSONPointer(root, parts)
+	if err != nil {
+		return "", err
+	}
+
+	sw := schemaWalker{root: root, active: map[string]bool{"#" + jsonPath: true}}
+	rows, err := sw.rows(schema, "")
+	if err != nil {
+		return "", err
+	}
+	if len(rows) == 0 {
+		return "", fmt.Errorf("schema at %q has no properties", jsonPath)
+	}
+
+	var b strings.Builder
+	b.WriteString("| Property | Type | Required | Default | Values | Description |\n")
+	b.WriteString("| --- | --- | --- | --- | --- | --- |\n")
+	for _, row := range rows {
+		_, _ = fmt.Fprintf(
+			&b,
+			"| `%v` | %v | %v | %v | %v | %v |\n",
+			row.name,
+			escapeTableCell(row.typ),
+			row.required,
+			escapeTableCell(row.def),
+			escapeTableCell(row.values),
+			escapeTableCell(row.description),
+		)
+	}
+	return strings.TrimRight(b.String(), "\n"), nil
+}
+
+// lookupJSONPointer resolves parts within a decoded document.
+func lookupJSONPointer(v interface{}, parts []string) (interface{}, error) {
+	for depth, part := range parts {
+		prefix := formatJSONPointer(parts[:depth])
+		switch x := v.(type) {
+		case *jsonObject:
+			c, ok := x.get(part)
+			if !ok {
+				return nil, fmt.Errorf("no key %q at %q; available keys: %v", part, prefix, summarizeKeys(x.keys))
+			}
+			v = c
+		case []interface{}:
+			idx, err := parseArrayIndex(part)
+			if err != nil {
+				return nil, fmt.Errorf("at %q: %w", prefix, err)
+			}
+			if idx >= len(x) {
+				return nil, fmt.Errorf("no index %d at %q; %v", idx, prefix, summarizeIndices(len(x)))
+			}
+			v = x[idx]
+		default:
+			return nil, fmt.Errorf("can't resolve %q: %q is not an object or array", part, prefix)
+		}
+	}
+	return v, nil
+}
+
+type schemaWalker struct {
+	root interface{}
+	// active holds the refs being expanded, so that recursive schemas terminate
+	active map[string]bool
+}
+
+// deref follows any `$ref`s from s, returning the schema they point to and the last ref followed.
+func (sw *schemaWalker) deref(s *jsonObject) (*jsonObject, string, error) {
+	var last string
+	for seen := make(map[string]bool); ; {
+		v, ok := s.get("$ref")
+		if !ok {
+			return s, last, nil
+		}
+		ref, _ := v.(string)
+		if !strings.HasPrefix(ref, "#") {
+			return nil, "", fmt.Errorf("unsupported $ref %q: only refs within the file are followed", ref)
+		}
+		if ref != "#" && !strings.HasPrefix(ref, "#/") {
+			return nil, "", fmt.Errorf("unsupported $ref %q: only JSON pointer refs are followed", ref)
+		}
+		if seen[ref] {
+			return nil, "", fmt.Errorf("$ref cycle at %q", ref)
+		}
+		seen[ref] = true
+
+		parts, err := parseJSONPointer(ref[1:])
+		if err != nil {
+			return nil, "", err
+		}
+		target, err := lookupJSONPointer(sw.root, parts)
+		if err != nil {
+			return nil, "", fmt.Errorf("resolving $ref %q: %w", ref, err)
+		}
+		if s, ok = target.(*jsonObject); !ok {
+			return nil, "", fmt.Errorf("$ref %q doesn't point to a schema", ref)
+		}
+		last = ref
+	}
+}
+
+// properties collects the properties and required names of s, including those of any `allOf` subschemas. A subschema
+// already collected, like one which refers back to s, is skipped.
+func (sw *schemaWalker) properties(s *jsonObject) (*jsonObject, map[string]bool, error) {
+	props, required := newJSONObject(), make(map[string]bool)
+	visited := make(map[*jsonObject]bool)
+	var collect func(s *jsonObject) error
+	collect = func(s *jsonObject) error {
+		s, _, err := sw.deref(s)
+		if err != nil {
+			return err
+		}
+		if visited[s] {
+			return nil
+		}
+		visited[s] = true
+		if p, ok := s.get("properties"); ok {
+			if p, ok := p.(*jsonObject); ok {
+				for _, k := range p.keys {
+					props.set(k, p.vals[k])
+				}
+			}
+		}
+		if req, ok := s.get("required"); ok {
+			if req, ok := req.([]interface{}); ok {
+				for _, r := range req {
+					if r, ok := r.(string); ok {
+						required[r] = true
+					}
+				}
+			}
+		}
+		if all, ok := s.get("allOf"); ok {
+			if all, ok := all.([]interface{}); ok {
+				for _, sub := range all {
+					if sub, ok := sub.(*jsonObject); ok {
+						if err := collect(sub); err != nil {
+							return err
+						}
+					}
+				}
+			}
+		}
+		return nil
+	}
+	return props, required, collect(s)
+}
+
+func (sw *schemaWalker) rows(v interface{}, prefix string) ([]schemaRow, error) {
+	s, ok := v.(*jsonObject)
+	if !ok {
+		return nil, errors.New("schema must be an object")
+	}
+	props, required, err := sw.properties(s)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []schemaRow
+	for _, name := range props.keys {
+		prop, ok := props.vals[name].(*jsonObject)
+		if !ok {
+			continue // `true` and `false` schemas have nothing to say
+		}
+		target, ref, err := sw.deref(prop)
+		if err != nil {
+			return nil, fmt.Errorf("property %q: %w", prefix+name, err)
+		}
+
+		row := schemaRow{
+			name:        prefix + name,
+			typ:         sw.typeName(prop),
+			required:    "no",
+			def:         schemaField(prop, target, "default", encodeSchemaValue),
+			values:      schemaField(prop, target, "enum", encodeSchemaEnum),
+			description: schemaField(prop, target, "description", schemaText),
+		}
+		if row.description == "" {
+			row.description = schemaField(prop, target, "title", schemaText)
+		}
+		if required[name] {
+			row.required = "yes"
+		}
+		rows = append(rows, row)
+
+		if ref != "" {
+			if sw.active[ref] { // recursive; the type name is enough
+				continue
+			}
+			sw.active[ref] = true
+		}
+		nested, err := sw.nested(target, prefix+name)
+		if ref != "" {
+			delete(sw.active, ref)
+		}
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, nested...)
+	}
+	return rows, nil
+}
+
+// nested lists the properties of an object, or of the objects in an array, below name.
+func (sw *schemaWalker) nested(s *jsonObject, name string) ([]schemaRow, error) {
+	if _, ok := s.get("properties"); ok {
+		return sw.rows(s, name+".")
+	}
+	if _, ok := s.get("allOf"); ok {
+		return sw.rows(s, name+".")
+	}
+	items, ok := s.get("items")
+	if !ok {
+		return nil, nil
+	}
+	itemSchema, ok := items.(*jsonObject)
+	if !ok {
+		return nil, nil
+	}
+	target, ref, err := sw.deref(itemSchema)
+	if err != nil {
+		return nil, fmt.Errorf("items of %q: %w", name, err)
+	}
+	if ref != "" {
+		if sw.active[ref] {
+			return nil, nil
+		}
+		sw.active[ref] = true
+		defer delete(sw.active, ref)
+	}
+	if _, ok := target.get("properties"); !ok {
+		return nil, nil
+	}
+	return sw.rows(target, name+"[].")
+}
+
+// typeName describes a schema's type, naming referenced definitions rather than expanding them.
+func (sw *schemaWalker) typeName(s *jsonObject) string {
+	if ref, ok := s.get("$ref"); ok {
+		if ref, ok := ref.(string); ok && ref != "#" {
+			return ref[strings.LastIndex(ref, "/")+1:]
+		}
+		return "object" // the root schema has no name of its own
+	}
+	var types []string
+	switch t := s.vals["type"].(type) {
+	case string:
+		types = []string{t}
+	case []interface{}:
+		for _, e := range t {
+			if e, ok := e.(string); ok {
+				types = append(types, e)
+			}
+		}
+	}
+	for i, t := range types {
+		if t != "array" {
+			continue
+		}
+		if items, ok := s.vals["items"].(*jsonObject); ok {
+			if it := sw.typeName(items); it != "" {
+				types[i] = "array of " + it
+			}
+		}
+	}
+	if len(types) == 0 {
+		for _, key := range []string{"oneOf", "anyOf"} {
+			alts, _ := s.vals[key].([]interface{})
+			for _, a := range alts {
+				if a, ok := a.(*jsonObject); ok {
+					if t := sw.typeName(a); t != "" {
+						types = append(types, t)
+					}
+				}
+			}
+		}
+	}
+	if len(types) == 0 {
+		if _, ok := s.get("properties"); ok {
+			types = []string{"object"}
+		}
+	}
+	return strings.Join(types, " | ")
+}
+
+// schemaField renders key from the property itself or else from the schema it refers to.
+func schemaField(prop, target *jsonObject, key string, render func(interface{}) string) string {
+	if v, ok := prop.get(key); ok {
+		return render(v)
+	}
+	if v, ok := target.get(key); ok {
+		return render(v)
+	}
+	return ""
+}
+
+func encodeSchemaValue(v interface{}) string {
+	s, err := encodeJSON(v, jsonFormat{compact: true})
+	if err != nil {
+		return ""
+	}
+	return "`" + s + "`"
+}
+
+func encodeSchemaEnum(v interface{}) string {
+	vals, ok := v.([]interface{})
+	if !ok {
+		return ""
+	}
+	rendered := make([]string, 0, len(vals))
+	for _, e := range vals {
+		rendered = append(rendered, encodeSchemaValue(e))
+	}
+	return strings.Join(rendered, ", ")
+}
+
+func schemaText(v interface{}) string {
+	s, _ := v.(string)
+	return strings.Join(strings.Fields(s), " ")
+}
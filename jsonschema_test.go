@@ -0,0 +1,74 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_sprintSchemaTable(t *testing.T) {
+	const src = `{
+  "type": "object",
+  "required": ["id"],
+  "allOf": [{"$ref": "#/definitions/Named"}],
+  "properties": {
+    "id": {"type": "integer", "description": "Primary key."},
+    "kind": {"enum": ["a", "b|c"], "default": "a"},
+    "parent": {"$ref": "#"},
+    "tags": {"type": "array", "items": {"type": "string"}},
+    "owner": {"oneOf": [{"$ref": "#/definitions/Named"}, {"type": "null"}]}
+  },
+  "definitions": {
+    "Named": {
+      "required": ["name"],
+      "properties": {"name": {"type": "string", "title": "Display\nname"}}
+    },
+    "Remote": {"properties": {"x": {"$ref": "other.json#/x"}}},
+    "Anchored": {"properties": {"z": {"$ref": "#Named"}}},
+    "Loop": {"allOf": [{"$ref": "#/definitions/Loop"}], "properties": {"y": {"type": "string"}}}
+  }
+}`
+	const header = "| Property | Type | Required | Default | Values | Description |\n| --- | --- | --- | --- | --- | --- |\n"
+	for _, c := range []struct {
+		name, path, out, err string
+	}{
+		{
+			"root",
+			"",
+			header +
+				"| `id` | integer | yes |  |  | Primary key. |\n" +
+				"| `kind` |  | no | `\"a\"` | `\"a\"`, `\"b\\|c\"` |  |\n" +
+				"| `parent` | object | no |  |  |  |\n" +
+				"| `tags` | array of string | no |  |  |  |\n" +
+				"| `owner` | Named \\| null | no |  |  |  |\n" +
+				"| `name` | string | yes |  |  | Display name |",
+			"",
+		},
+		{
+			"definition",
+			"/definitions/Named",
+			header + "| `name` | string | yes |  |  | Display name |",
+			"",
+		},
+		{"allOf cycle", "/definitions/Loop", header + "| `y` | string | no |  |  |  |", ""},
+		{"external ref", "/definitions/Remote", "", `property "x": unsupported $ref "other.json#/x": only refs within the file are followed`},
+		{"anchor ref", "/definitions/Anchored", "", `property "z": unsupported $ref "#Named": only JSON pointer refs are followed`},
+		{"missing", "/definitions/Nope", "", `no key "Nope" at "/definitions"; available keys: "Named", "Remote", "Anchored", "Loop"`},
+		{"no properties", "/properties/id", "", `schema at "/properties/id" has no properties`},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			out, err := sprintSchemaTable(strings.NewReader(src), c.path)
+			if err != nil {
+				if err.Error() != c.err {
+					t.Fatalf("wanted error %q but got %q", c.err, err)
+				}
+				return
+			}
+			if c.err != "" {
+				t.Fatalf("wanted error %q but got none", c.err)
+			}
+			if out != c.out {
+				t.Errorf("wanted:\n%v\ngot:\n%v", c.out, out)
+			}
+		})
+	}
+}
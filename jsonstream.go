@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// recordRange selects records from a stream of JSON values like a slice: `:5` is the first five, `10:` all but them.
+type recordRange struct {
+	start int
+	// end is exclusive; -1 runs to the end of the stream
+	end int
+}
+
+func (r *recordRange) String() string {
+	var b strings.Builder
+	if r.start > 0 {
+		b.WriteString(strconv.Itoa(r.start))
+	}
+	b.WriteByte(':')
+	if r.end >= 0 {
+		b.WriteString(strconv.Itoa(r.end))
+	}
+	return b.String()
+}
+
+// parseRecordRange parses the value of key, which is records or, for csvquote, rows.
+func parseRecordRange(key, s string) (*recordRange, error) {
+	bounds := strings.SplitN(s, ":", 2)
+	if len(bounds) != 2 {
+		return nil, fmt.Errorf("invalid %v %q: must be a range like 0:5", key, s)
+	}
+	r := &recordRange{end: -1}
+	for i, dst := range []*int{&r.start, &r.end} {
+		if bounds[i] == "" {
+			continue
+		}
+		n, err := strconv.Atoi(bounds[i])
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid %v %q: bounds must be non-negative integers", key, s)
+		}
+		*dst = n
+	}
+	if r.end >= 0 && r.end <= r.start {
+		return nil, fmt.Errorf("invalid %v %q: end must be after start", key, s)
+	}
+	return r, nil
+}
+
+// scanRecords calls fn with each record in a stream of JSON values -- e.g. NDJSON -- which matches where, if set, along
+// with its index among the matches. It stops as soon as fn returns false, so the rest of the stream is never read. It
+// returns the number of matches seen.
+func scanRecords(r io.Reader, where *jsonFilter, fn func(i int, raw json.RawMessage) (bool, error)) (int, error) {
+	dec := json.NewDecoder(r)
+	for n, matched := 0, 0; ; n++ {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			if errors.Is(err, io.EOF) {
+				return matched, nil
+			}
+			return matched, fmt.Errorf("decoding record %d: %w", n, err)
+		}
+		if where != nil {
+			rdec := json.NewDecoder(bytes.NewReader(raw))
+			rdec.UseNumber()
+			v, err := decodeJSON(rdec)
+			if err != nil {
+				return matched, fmt.Errorf("decoding record %d: %w", n, err)
+			}
+			if !where.match(v) {
+				continue
+			}
+		}
+		more, err := fn(matched, raw)
+		if matched++; err != nil || !more {
+			return matched, err
+		}
+	}
+}
+
+// nthRecord returns the record at idx among those matching where.
+func nthRecord(r io.Reader, idx int, where *jsonFilter) (json.RawMessage, error) {
+	var found json.RawMessage
+	n, err := scanRecords(r, where, func(i int, raw json.RawMessage) (bool, error) {
+		if i == idx {
+			found = raw
+		}
+		return i < idx, nil
+	})
+	switch {
+	case err != nil:
+		return nil, err
+	case found != nil:
+		return found, nil
+	case where != nil:
+		return nil, fmt.Errorf("no record %d where %q; only %d match", idx, where, n)
+	}
+	return nil, fmt.Errorf("no record %d; the stream has %d", idx, n)
+}
+
+// selectRecords returns the records in rng, each narrowed to the value at jsonPath.
+func selectRecords(r io.Reader, jsonPath string, rng *recordRange, where *jsonFilter) ([]json.RawMessage, error) {
+	var recs []json.RawMessage
+	_, err := scanRecords(r, where, func(i int, raw json.RawMessage) (bool, error) {
+		if i < rng.start {
+			return true, nil
+		}
+		val, err := selectJSON(bytes.NewReader(raw), jsonPath)
+		if err != nil {
+			return false, fmt.Errorf("record %d: %w", i, err)
+		}
+		recs = append(recs, val)
+		return rng.end < 0 || i+1 < rng.end, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(recs) == 0 {
+		return nil, fmt.Errorf("no records in %v", rng)
+	}
+	return recs, nil
+}
+
+// sprintRecords renders the records in rng, each narrowed to the value at jsonPath, as NDJSON.
+func sprintRecords(r io.Reader, jsonPath string, rng *recordRange, where *jsonFilter) (string, error) {
+	recs, err := selectRecords(r, jsonPath, rng, where)
+	if err != nil {
+		return "", err
+	}
+	var b bytes.Buffer
+	for i, val := range recs {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		if err := json.Compact(&b, val); err != nil {
+			return "", err
+		}
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_parseRecordRange(t *testing.T) {
+	for _, c := range []struct {
+		in, out, err string
+	}{
+		{":5", ":5", ""},
+		{"2:", "2:", ""},
+		{"2:4", "2:4", ""},
+		{":", ":", ""},
+		{"5", "", `invalid records "5": must be a range like 0:5`},
+		{"-1:", "", `invalid records "-1:": bounds must be non-negative integers`},
+		{"3:3", "", `invalid records "3:3": end must be after start`},
+	} {
+		t.Run(c.in, func(t *testing.T) {
+			r, err := parseRecordRange(keyRecords, c.in)
+			if err != nil {
+				if err.Error() != c.err {
+					t.Fatalf("wanted error %q but got %q", c.err, err)
+				}
+				return
+			}
+			if c.err != "" {
+				t.Fatalf("wanted error %q but got none", c.err)
+			}
+			if r.String() != c.out {
+				t.Errorf("wanted %q but got %q", c.out, r)
+			}
+		})
+	}
+}
+
+func Test_jsonStream(t *testing.T) {
+	// the trailing garbage is never read unless the stream is exhausted
+	const src = `{"n": 0, "ok": true, "v": {"a": 1}}
+{"n": 1, "ok": false, "v": {"a": 2}}
+{"n": 2, "ok": true, "v": {"a": 3}}
+not json`
+
+	mustFilter := func(s string) *jsonFilter {
+		f, err := parseJSONFilter(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return f
+	}
+
+	for _, c := range []struct {
+		name     string
+		src      string
+		path     string
+		idx      int
+		records  *recordRange
+		where    *jsonFilter
+		out, err string
+	}{
+		{name: "first", out: `{"n": 0, "ok": true, "v": {"a": 1}}`},
+		{name: "index", idx: 2, out: `{"n": 2, "ok": true, "v": {"a": 3}}`},
+		{name: "where", idx: 1, where: mustFilter("@.ok"), out: `{"n": 2, "ok": true, "v": {"a": 3}}`},
+		{name: "range", records: &recordRange{end: 2}, out: "{\"n\":0,\"ok\":true,\"v\":{\"a\":1}}\n{\"n\":1,\"ok\":false,\"v\":{\"a\":2}}"},
+		{name: "range with path", path: "/v/a", records: &recordRange{start: 1, end: 3}, out: "2\n3"},
+		{name: "range with where", path: "/n", records: &recordRange{end: 5}, where: mustFilter("!@.ok"), err: `decoding record 3: invalid character 'o' in literal null (expecting 'u')`},
+		{name: "missing path", path: "/x", records: &recordRange{end: 1}, err: `record 0: no key "x" at ""; available keys: "n", "ok", "v"`},
+		{name: "no match", src: `{"n": 0} {"n": 1}`, where: mustFilter("@.n > 5"), err: `no record 0 where "@.n > 5"; only 0 match`},
+		{name: "past the end", src: `{"n": 0} {"n": 1}`, idx: 2, err: `no record 2; the stream has 2`},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			if c.src == "" {
+				c.src = src
+			}
+			var (
+				out string
+				err error
+			)
+			if c.records != nil {
+				out, err = sprintRecords(strings.NewReader(c.src), c.path, c.records, c.where)
+			} else {
+				var raw []byte
+				if raw, err = nthRecord(strings.NewReader(c.src), c.idx, c.where); err == nil {
+					out = string(raw)
+				}
+			}
+			if err != nil {
+				if err.Error() != c.err {
+					t.Fatalf("wanted error %q but got %q", c.err, err)
+				}
+				return
+			}
+			if c.err != "" {
+				t.Fatalf("wanted error %q but got none", c.err)
+			}
+			if out != c.out {
+				t.Errorf("wanted:\n%v\ngot:\n%v", c.out, out)
+			}
+		})
+	}
+}
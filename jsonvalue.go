@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// jsonObject is a decoded JSON object which, unlike a map, remembers the order of its keys.
+type jsonObject struct {
+	keys []string
+	vals map[string]interface{}
+}
+
+func newJSONObject() *jsonObject {
+	return &jsonObject{vals: make(map[string]interface{})}
+}
+
+func (o *jsonObject) get(k string) (interface{}, bool) {
+	v, ok := o.vals[k]
+	return v, ok
+}
+
+// set adds or replaces the value at k; replaced keys keep their original position.
+func (o *jsonObject) set(k string, v interface{}) {
+	if _, ok := o.vals[k]; !ok {
+		o.keys = append(o.keys, k)
+	}
+	o.vals[k] = v
+}
+
+// decodeJSON decodes the next value from dec into *jsonObjects, []interface{}s, and scalars. dec should be set to
+// UseNumber so that numbers are rendered as written.
+func decodeJSON(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		obj := newJSONObject()
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			k, ok := key.(string)
+			if !ok {
+				return nil, fmt.Errorf("expected string, got %v", key)
+			}
+			v, err := decodeJSON(dec)
+			if err != nil {
+				return nil, err
+			}
+			obj.set(k, v)
+		}
+		if _, err := dec.Token(); err != nil { // '}'
+			return nil, err
+		}
+		return obj, nil
+	case json.Delim('['):
+		arr := make([]interface{}, 0)
+		for dec.More() {
+			v, err := decodeJSON(dec)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, v)
+		}
+		if _, err := dec.Token(); err != nil { // ']'
+			return nil, err
+		}
+		return arr, nil
+	}
+	return tok, nil
+}
+
+// jsonFormat controls how selected JSON is rendered.
+type jsonFormat struct {
+	// raw renders the original bytes
+	raw bool
+	// indent defaults to two spaces
+	indent   string
+	compact  bool
+	sortKeys bool
+	// depth collapses anything nested deeper to `{...}` or `[...]`; 0 for no limit
+	depth int
+}
+
+func (f jsonFormat) indentString() string {
+	switch {
+	case f.raw, f.compact:
+		return ""
+	case f.indent == "":
+		return "  "
+	}
+	return f.indent
+}
+
+// render formats a raw value, only decoding it if it must be restructured.
+func (f jsonFormat) render(val json.RawMessage) (string, error) {
+	if f.raw {
+		return string(val), nil
+	}
+	if f.sortKeys || f.depth > 0 {
+		dec := json.NewDecoder(bytes.NewReader(val))
+		dec.UseNumber()
+		v, err := decodeJSON(dec)
+		if err != nil {
+			return "", err
+		}
+		return encodeJSON(v, f)
+	}
+	var buf bytes.Buffer
+	if f.compact {
+		if err := json.Compact(&buf, val); err != nil {
+			return "", err
+		}
+		return buf.String(), nil
+	}
+	if err := json.Indent(&buf, val, "", f.indentString()); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// encodeJSON renders v, as produced by decodeJSON, the way json.Indent would.
+func encodeJSON(v interface{}, f jsonFormat) (string, error) {
+	var b bytes.Buffer
+	if err := writeJSON(&b, v, f, 0); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+func writeJSON(b *bytes.Buffer, v interface{}, f jsonFormat, level int) error {
+	indent := f.indentString()
+	newline := func(level int) {
+		if indent == "" {
+			return
+		}
+		b.WriteByte('\n')
+		for i := 0; i < level; i++ {
+			b.WriteString(indent)
+		}
+	}
+	colon := ":"
+	if indent != "" {
+		colon = ": "
+	}
+
+	switch v := v.(type) {
+	case *jsonObject:
+		if len(v.keys) == 0 {
+			b.WriteString("{}")
+			return nil
+		}
+		if f.depth > 0 && level >= f.depth {
+			b.WriteString("{...}")
+			return nil
+		}
+		keys := v.keys
+		if f.sortKeys {
+			keys = append([]string(nil), keys...)
+			sort.Strings(keys)
+		}
+		b.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			newline(level + 1)
+			writeJSONString(b, k)
+			b.WriteString(colon)
+			if err := writeJSON(b, v.vals[k], f, level+1); err != nil {
+				return err
+			}
+		}
+		newline(level)
+		b.WriteByte('}')
+	case []interface{}:
+		if len(v) == 0 {
+			b.WriteString("[]")
+			return nil
+		}
+		if f.depth > 0 && level >= f.depth {
+			b.WriteString("[...]")
+			return nil
+		}
+		b.WriteByte('[')
+		for i, e := range v {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			newline(level + 1)
+			if err := writeJSON(b, e, f, level+1); err != nil {
+				return err
+			}
+		}
+		newline(level)
+		b.WriteByte(']')
+	case string:
+		writeJSONString(b, v)
+	default:
+		m, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		b.Write(m)
+	}
+	return nil
+}
+
+func writeJSONString(b *bytes.Buffer, s string) {
+	enc := json.NewEncoder(b)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(s)       // strings always encode
+	b.Truncate(b.Len() - 1) // trailing newline
+}
@@ -0,0 +1,373 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+const (
+	// linkPkgSite links to the symbol's documentation on pkg.go.dev
+	linkPkgSite = "pkgsite"
+	// linkBlob links to the symbol's lines in the repository, via a template
+	linkBlob = "blob"
+
+	// envLinkTemplate configures the default repository link template for all directives
+	envLinkTemplate = "PULLQUOTE_LINK_TEMPLATE"
+)
+
+var errNoGitRepo = errors.New("not within a git repository")
+
+// sourceLink renders a markdown link back to the quoted lines of fn. Everything is derived from local files: the
+// module path from go.mod and the commit and tags from the git directory -- or, for a module in the module cache, the
+// module path and version from its directory's name.
+func sourceLink(pq *pullQuote, pat, sym, fn string, startLine, endLine int) (string, error) {
+	lines := fmt.Sprintf("#L%d-L%d", startLine, endLine)
+	if startLine == endLine {
+		lines = fmt.Sprintf("#L%d", startLine)
+	}
+
+	switch pq.link {
+	case linkPkgSite:
+		var importPath, version string
+		if pkgPath, v, pinned := splitPinned(pat); pinned {
+			importPath, version = pkgPath, v
+		} else if modRoot, modPath, v, ok := modCacheModule(filepath.Dir(fn)); ok {
+			rel, err := filepath.Rel(modRoot, filepath.Dir(fn))
+			if err != nil {
+				return "", err
+			}
+			importPath, version = path.Join(modPath, filepath.ToSlash(rel)), v
+			if pq.linkVersion != "" {
+				version = pq.linkVersion
+			}
+		} else {
+			modRoot, modPath, err := findModule(filepath.Dir(fn))
+			if err != nil {
+				return "", err
+			}
+			rel, err := filepath.Rel(modRoot, filepath.Dir(fn))
+			if err != nil {
+				return "", err
+			}
+			importPath = path.Join(modPath, filepath.ToSlash(rel))
+
+			if version = pq.linkVersion; version == "" {
+				gitDir, commit, err := gitHead(filepath.Dir(fn))
+				if err != nil {
+					return "", fmt.Errorf("determining version for link: %w", err)
+				}
+				if version = gitTagAt(gitDir, commit); version == "" {
+					return "", fmt.Errorf("link=pkgsite requires linkversion when HEAD (%v) isn't tagged with a version", commit)
+				}
+			}
+		}
+		anchor := strings.Split(sym, "/")[0] // pkg.go.dev anchors top-level symbols only
+		label := path.Join(importPath, filepath.Base(fn)) + lines
+		return fmt.Sprintf("[`%v`](https://pkg.go.dev/%v@%v#%v)", label, importPath, version, anchor), nil
+
+	case linkBlob:
+		if _, _, pinned := splitPinned(pat); pinned {
+			return "", errors.New("link=blob isn't supported for pinned versions")
+		}
+		gitDir, commit, err := gitHead(filepath.Dir(fn))
+		if err != nil {
+			return "", err
+		}
+		root := filepath.Dir(gitDir)
+		rel, err := filepath.Rel(root, fn)
+		if err != nil {
+			return "", err
+		}
+		rel = filepath.ToSlash(rel)
+
+		tmpl := pq.linkTemplate
+		if tmpl == "" {
+			tmpl = os.Getenv(envLinkTemplate)
+		}
+		if tmpl == "" {
+			tmpl = remoteLinkTemplate(gitDir)
+		}
+		if tmpl == "" {
+			return "", fmt.Errorf("link=blob requires linktemplate (or %v) when origin isn't a recognized host", envLinkTemplate)
+		}
+
+		url := strings.NewReplacer(
+			"{commit}", commit,
+			"{path}", rel,
+			"{start}", strconv.Itoa(startLine),
+			"{end}", strconv.Itoa(endLine),
+		).Replace(tmpl)
+		return fmt.Sprintf("[`%v%v`](%v)", rel, lines, url), nil
+	}
+	return "", fmt.Errorf("unknown link type %q", pq.link)
+}
+
+// modCacheModule walks up from dir to a module's root within the module cache, like `golang.org/x/mod@v0.4.2`,
+// returning the root along with the module path and version encoded in its name.
+func modCacheModule(dir string) (modRoot, modPath, version string, ok bool) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", "", "", false
+	}
+	cache, err := filepath.Abs(modCacheDir())
+	if err != nil {
+		return "", "", "", false
+	}
+	rel, err := filepath.Rel(cache, abs)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return "", "", "", false
+	}
+	elems := strings.Split(filepath.ToSlash(rel), "/")
+	for i, e := range elems {
+		at := strings.LastIndex(e, "@")
+		if at == -1 {
+			continue
+		}
+		if modPath, err = module.UnescapePath(path.Join(append(elems[:i:i], e[:at])...)); err != nil {
+			return "", "", "", false
+		}
+		if version, err = module.UnescapeVersion(e[at+1:]); err != nil {
+			return "", "", "", false
+		}
+		return filepath.Join(cache, filepath.FromSlash(path.Join(elems[:i+1]...))), modPath, version, true
+	}
+	return "", "", "", false
+}
+
+// findModule walks up from dir to the nearest go.mod, returning its directory and module path.
+func findModule(dir string) (string, string, error) {
+	for d := dir; ; d = filepath.Dir(d) {
+		b, err := ioutil.ReadFile(filepath.Join(d, "go.mod"))
+		if err == nil {
+			modPath := modfile.ModulePath(b)
+			if modPath == "" {
+				return "", "", fmt.Errorf("no module path in %v", filepath.Join(d, "go.mod"))
+			}
+			return d, modPath, nil
+		}
+		if !os.IsNotExist(err) {
+			return "", "", err
+		}
+		if filepath.Dir(d) == d {
+			return "", "", fmt.Errorf("no go.mod found above %v", dir)
+		}
+	}
+}
+
+// gitHead walks up from dir to the enclosing git directory and resolves HEAD to a commit without invoking git.
+func gitHead(dir string) (gitDir, commit string, err error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", "", err
+	}
+	for d := abs; ; d = filepath.Dir(d) {
+		candidate := filepath.Join(d, ".git")
+		if stat, err := os.Stat(candidate); err == nil {
+			gitDir = candidate
+			if !stat.IsDir() { // worktrees and submodules point elsewhere
+				b, err := ioutil.ReadFile(candidate)
+				if err != nil {
+					return "", "", err
+				}
+				gitDir = strings.TrimSpace(strings.TrimPrefix(string(b), "gitdir:"))
+				if !filepath.IsAbs(gitDir) {
+					gitDir = filepath.Join(d, gitDir)
+				}
+			}
+			break
+		}
+		if filepath.Dir(d) == d {
+			return "", "", errNoGitRepo
+		}
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(gitDir, "HEAD"))
+	if err != nil {
+		return "", "", err
+	}
+	head := strings.TrimSpace(string(b))
+	if !strings.HasPrefix(head, "ref: ") { // detached
+		return gitDir, head, nil
+	}
+	commit, err = resolveRef(gitDir, strings.TrimPrefix(head, "ref: "))
+	return gitDir, commit, err
+}
+
+func resolveRef(gitDir, ref string) (string, error) {
+	if b, err := ioutil.ReadFile(filepath.Join(gitDir, filepath.FromSlash(ref))); err == nil {
+		return strings.TrimSpace(string(b)), nil
+	}
+	// worktrees keep shared refs in the common dir
+	if b, err := ioutil.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		common := strings.TrimSpace(string(b))
+		if !filepath.IsAbs(common) {
+			common = filepath.Join(gitDir, common)
+		}
+		if b, err := ioutil.ReadFile(filepath.Join(common, filepath.FromSlash(ref))); err == nil {
+			return strings.TrimSpace(string(b)), nil
+		}
+		gitDir = common
+	}
+	for _, r := range packedRefs(gitDir) {
+		if r.name == ref {
+			return r.hash, nil
+		}
+	}
+	return "", fmt.Errorf("couldn't resolve %v", ref)
+}
+
+type packedRef struct {
+	name, hash, peeled string
+}
+
+func packedRefs(gitDir string) []packedRef {
+	f, err := os.Open(filepath.Join(gitDir, "packed-refs"))
+	if err != nil {
+		return nil
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	var refs []packedRef
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		l := s.Text()
+		switch {
+		case strings.HasPrefix(l, "#"):
+		case strings.HasPrefix(l, "^"): // peeled value of the previous annotated tag
+			if len(refs) > 0 {
+				refs[len(refs)-1].peeled = l[1:]
+			}
+		default:
+			if parts := strings.Fields(l); len(parts) == 2 {
+				refs = append(refs, packedRef{name: parts[1], hash: parts[0]})
+			}
+		}
+	}
+	return refs
+}
+
+// gitTagAt returns a semver tag pointing at commit, if any.
+func gitTagAt(gitDir, commit string) string {
+	var tags []string
+	for _, r := range packedRefs(gitDir) {
+		if strings.HasPrefix(r.name, "refs/tags/") && (r.hash == commit || r.peeled == commit) {
+			tags = append(tags, strings.TrimPrefix(r.name, "refs/tags/"))
+		}
+	}
+	tagDir := filepath.Join(gitDir, "refs", "tags")
+	_ = filepath.Walk(tagDir, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return nil
+		}
+		b, err := ioutil.ReadFile(p)
+		if err != nil {
+			return nil
+		}
+		if hash := strings.TrimSpace(string(b)); hash == commit || peelTag(gitDir, hash) == commit {
+			rel, _ := filepath.Rel(tagDir, p)
+			tags = append(tags, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	for _, t := range tags {
+		if semver.IsValid(t) {
+			return t
+		}
+	}
+	return ""
+}
+
+// peelTag reads a loose annotated tag object to find the commit it points at; packed objects aren't supported.
+func peelTag(gitDir, hash string) string {
+	if len(hash) < 3 {
+		return ""
+	}
+	f, err := os.Open(filepath.Join(gitDir, "objects", hash[:2], hash[2:]))
+	if err != nil {
+		return ""
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	zr, err := zlib.NewReader(f)
+	if err != nil {
+		return ""
+	}
+	b, err := ioutil.ReadAll(zr)
+	if err != nil || !bytes.HasPrefix(b, []byte("tag ")) {
+		return ""
+	}
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[i+1:]
+	}
+	if !bytes.HasPrefix(b, []byte("object ")) {
+		return ""
+	}
+	b = b[len("object "):]
+	if i := bytes.IndexByte(b, '\n'); i >= 0 {
+		return string(b[:i])
+	}
+	return ""
+}
+
+var (
+	regexpRemoteURL  = regexp.MustCompile(`^\s*url\s*=\s*(\S+)\s*$`)
+	regexpRemoteHost = regexp.MustCompile(`^(?:https?://|ssh://)?(?:[^@/]+@)?(github\.com|gitlab\.com|bitbucket\.org)[:/](.+?)(?:\.git)?/?$`)
+)
+
+// remoteLinkTemplate derives a blob link template from the origin remote for well-known hosts.
+func remoteLinkTemplate(gitDir string) string {
+	f, err := os.Open(filepath.Join(gitDir, "config"))
+	if err != nil {
+		return ""
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	var inOrigin bool
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		l := strings.TrimSpace(s.Text())
+		if strings.HasPrefix(l, "[") {
+			inOrigin = l == `[remote "origin"]`
+			continue
+		}
+		if !inOrigin {
+			continue
+		}
+		m := regexpRemoteURL.FindStringSubmatch(l)
+		if m == nil {
+			continue
+		}
+		hm := regexpRemoteHost.FindStringSubmatch(m[1])
+		if hm == nil {
+			return ""
+		}
+		switch hm[1] {
+		case "gitlab.com":
+			return "https://gitlab.com/" + hm[2] + "/-/blob/{commit}/{path}#L{start}-{end}"
+		case "bitbucket.org":
+			return "https://bitbucket.org/" + hm[2] + "/src/{commit}/{path}#lines-{start}:{end}"
+		default:
+			return "https://github.com/" + hm[2] + "/blob/{commit}/{path}#L{start}-L{end}"
+		}
+	}
+	return ""
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func Test_gitHead(t *testing.T) {
+	const commit = "0123456789abcdef0123456789abcdef01234567"
+	for _, c := range []struct {
+		name    string
+		files   map[string]string
+		want    string
+		wantErr bool
+	}{
+		{
+			"loose ref",
+			map[string]string{
+				".git/HEAD":              "ref: refs/heads/main\n",
+				".git/refs/heads/main":   commit + "\n",
+				"pkg/sub/placeholder.go": "package sub\n",
+			},
+			commit,
+			false,
+		},
+		{
+			"packed ref",
+			map[string]string{
+				".git/HEAD":              "ref: refs/heads/main\n",
+				".git/packed-refs":       "# pack-refs with: peeled fully-peeled sorted\n" + commit + " refs/heads/main\n",
+				"pkg/sub/placeholder.go": "package sub\n",
+			},
+			commit,
+			false,
+		},
+		{
+			"detached",
+			map[string]string{
+				".git/HEAD":              commit + "\n",
+				"pkg/sub/placeholder.go": "package sub\n",
+			},
+			commit,
+			false,
+		},
+		{
+			"gitdir file",
+			map[string]string{
+				".git":                           "gitdir: elsewhere/.git\n",
+				"elsewhere/.git/HEAD":            "ref: refs/heads/main\n",
+				"elsewhere/.git/refs/heads/main": commit + "\n",
+				"pkg/sub/placeholder.go":         "package sub\n",
+			},
+			commit,
+			false,
+		},
+		{
+			"unresolvable",
+			map[string]string{
+				".git/HEAD":              "ref: refs/heads/main\n",
+				"pkg/sub/placeholder.go": "package sub\n",
+			},
+			"",
+			true,
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			root := t.TempDir()
+			for fn, contents := range c.files {
+				writeFile(t, filepath.Join(root, fn), contents)
+			}
+			_, got, err := gitHead(filepath.Join(root, "pkg/sub"))
+			if (err != nil) != c.wantErr {
+				t.Fatalf("wantErr %v but %v", c.wantErr, err)
+			}
+			if got != c.want {
+				t.Errorf("wanted %q but got %q", c.want, got)
+			}
+		})
+	}
+}
+
+func Test_gitTagAt(t *testing.T) {
+	const (
+		commit = "0123456789abcdef0123456789abcdef01234567"
+		tagObj = "89abcdef0123456789abcdef0123456789abcdef"
+	)
+	for _, c := range []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{"loose lightweight", map[string]string{"refs/tags/v1.2.3": commit + "\n"}, "v1.2.3"},
+		{"packed annotated", map[string]string{"packed-refs": tagObj + " refs/tags/v1.0.0\n^" + commit + "\n"}, "v1.0.0"},
+		{"non semver", map[string]string{"refs/tags/release": commit + "\n"}, ""},
+		{"non semver v prefix", map[string]string{"refs/tags/vendor-snapshot": commit + "\n"}, ""},
+		{"semver among others", map[string]string{"refs/tags/vendor-snapshot": commit + "\n", "refs/tags/v2.0.0-rc.1": commit + "\n"}, "v2.0.0-rc.1"},
+		{"other commit", map[string]string{"refs/tags/v1.2.3": tagObj + "\n"}, ""},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			gitDir := t.TempDir()
+			for fn, contents := range c.files {
+				writeFile(t, filepath.Join(gitDir, fn), contents)
+			}
+			if got := gitTagAt(gitDir, commit); got != c.want {
+				t.Errorf("wanted %q but got %q", c.want, got)
+			}
+		})
+	}
+}
+
+func Test_remoteLinkTemplate(t *testing.T) {
+	for _, c := range []struct {
+		name, url, want string
+	}{
+		{"github https", "https://github.com/jwilner/pullquote.git", "https://github.com/jwilner/pullquote/blob/{commit}/{path}#L{start}-L{end}"},
+		{"github ssh", "git@github.com:jwilner/pullquote.git", "https://github.com/jwilner/pullquote/blob/{commit}/{path}#L{start}-L{end}"},
+		{"gitlab", "https://gitlab.com/group/sub/project", "https://gitlab.com/group/sub/project/-/blob/{commit}/{path}#L{start}-{end}"},
+		{"unknown host", "https://git.example.com/project.git", ""},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			gitDir := t.TempDir()
+			writeFile(t, filepath.Join(gitDir, "config"), `[core]
+	bare = false
+[remote "upstream"]
+	url = https://github.com/someone/else.git
+[remote "origin"]
+	url = `+c.url+`
+	fetch = +refs/heads/*:refs/remotes/origin/*
+`)
+			if got := remoteLinkTemplate(gitDir); got != c.want {
+				t.Errorf("wanted %q but got %q", c.want, got)
+			}
+		})
+	}
+}
+
+func Test_sourceLink(t *testing.T) {
+	const commit = "0123456789abcdef0123456789abcdef01234567"
+	for _, c := range []struct {
+		name    string
+		files   map[string]string
+		fn      string
+		want    string
+		wantErr string
+	}{
+		{
+			"module cache",
+			map[string]string{
+				".git/HEAD":                                 "ref: refs/heads/main\n",
+				"mod/example.com/!my!mod@v1.2.3/go.mod":     "module example.com/MyMod\n",
+				"mod/example.com/!my!mod@v1.2.3/pkg/pkg.go": "package pkg\n",
+			},
+			"mod/example.com/!my!mod@v1.2.3/pkg/pkg.go",
+			"[`example.com/MyMod/pkg/pkg.go#L3`](https://pkg.go.dev/example.com/MyMod/pkg@v1.2.3#Sym)",
+			"",
+		},
+		{
+			"tagged",
+			map[string]string{
+				".git/HEAD":             "ref: refs/heads/main\n",
+				".git/refs/heads/main":  commit + "\n",
+				".git/refs/tags/v0.4.0": commit + "\n",
+				"src/go.mod":            "module example.com/local\n",
+				"src/pkg/pkg.go":        "package pkg\n",
+			},
+			"src/pkg/pkg.go",
+			"[`example.com/local/pkg/pkg.go#L3`](https://pkg.go.dev/example.com/local/pkg@v0.4.0#Sym)",
+			"",
+		},
+		{
+			"untagged",
+			map[string]string{
+				".git/HEAD":            "ref: refs/heads/main\n",
+				".git/refs/heads/main": commit + "\n",
+				"src/go.mod":           "module example.com/local\n",
+				"src/pkg/pkg.go":       "package pkg\n",
+			},
+			"src/pkg/pkg.go",
+			"",
+			"link=pkgsite requires linkversion when HEAD (" + commit + ") isn't tagged with a version",
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			root := t.TempDir()
+			t.Setenv("GOMODCACHE", filepath.Join(root, "mod"))
+			for fn, contents := range c.files {
+				writeFile(t, filepath.Join(root, fn), contents)
+			}
+			pq := &pullQuote{link: linkPkgSite}
+			got, err := sourceLink(pq, "./pkg", "Sym", filepath.Join(root, c.fn), 3, 3)
+			if err != nil {
+				if err.Error() != c.wantErr {
+					t.Fatalf("wanted error %q but got %q", c.wantErr, err)
+				}
+				return
+			}
+			if c.wantErr != "" {
+				t.Fatalf("wanted error %q but got none", c.wantErr)
+			}
+			if got != c.want {
+				t.Errorf("wanted %q but got %q", c.want, got)
+			}
+		})
+	}
+}
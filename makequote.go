@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+func expandMakeQuotes(_ context.Context, pqs []*pullQuote) ([]*expanded, error) {
+	exp := make([]*expanded, 0, len(pqs))
+	for _, pq := range pqs {
+		pat, name, _ := splitObjPath(pq.objPath) // no name quotes the whole file
+
+		s, err := func() (string, error) {
+			src, err := ioutil.ReadFile(pat)
+			if err != nil {
+				return "", err
+			}
+			b := bytes.TrimRight(src, "\r\n")
+			if name != "" {
+				var s string
+				if makeKindForPath(pat) == "makefile" {
+					s, err = parseMakeTarget(string(src), name)
+				} else {
+					s, err = parseShellFunc(string(src), name)
+				}
+				if err != nil {
+					return "", err
+				}
+				b = []byte(s)
+			}
+			if pq.sub.isSet() {
+				if b, _, err = pq.sub.apply(b); err != nil {
+					return "", fmt.Errorf("selecting within %q: %w", name, err)
+				}
+				b = dedentTabs(dedentSpaces(b))
+			}
+			return string(b), nil
+		}()
+		if err != nil {
+			return nil, fmt.Errorf("error within %v: %w", pat, err)
+		}
+		exp = append(exp, &expanded{String: s})
+	}
+	return exp, nil
+}
+
+// makeKindForPath returns "makefile" for Makefiles, the shell's name for shell scripts, or "" for anything else.
+func makeKindForPath(pat string) string {
+	switch base := filepath.Base(pat); {
+	case base == "Makefile" || base == "makefile" || base == "GNUmakefile":
+		return "makefile"
+	default:
+		switch ext := strings.ToLower(filepath.Ext(base)); ext {
+		case ".mk", ".mak":
+			return "makefile"
+		case ".sh", ".bash", ".zsh", ".ksh":
+			return ext[1:]
+		}
+	}
+	return ""
+}
+
+var (
+	// makeRule matches a rule's targets, up to its colon
+	makeRule = regexp.MustCompile(`^([^\s:=#][^:=#]*?)\s*::?`)
+	// makeVariable matches assignments like `GOFLAGS := -race` or `export CGO_ENABLED ::= 0`
+	makeVariable = regexp.MustCompile(`^\s*(?:export\s+|override\s+)?[^\s:#=]+\s*(?::{1,3}|[+?!])?=`)
+	// makeAssignment matches target-specific variables, e.g. `test: GOFLAGS += -race`
+	makeAssignment = regexp.MustCompile(`^[^=]*::?\s*(?:export\s+|override\s+)?[^\s=:]+\s*(?:[:+?!]|::)?=`)
+)
+
+// makeRuleLines is a rule from its comments through its recipe, in lines.
+type makeRuleLines struct {
+	targets    []string
+	start, end int
+	hasRecipe  bool
+}
+
+// parseMakeTarget finds the rule for a target and returns it from its leading comments through its recipe. If the
+// target has several rules, like one for prerequisites and another for the recipe, the first with a recipe wins.
+func parseMakeTarget(src, name string) (string, error) {
+	lines := strings.Split(strings.Replace(src, "\r\n", "\n", -1), "\n")
+
+	var (
+		rules  []makeRuleLines
+		define bool // within a define ... endef block, which may contain anything
+	)
+	for i := 0; i < len(lines); i++ {
+		l := lines[i]
+		first := i
+		for strings.HasSuffix(lines[i], "\\") && i+1 < len(lines) {
+			i++
+		}
+
+		switch trimmed := strings.TrimSpace(l); {
+		case define:
+			define = trimmed != "endef"
+			continue
+		case strings.HasPrefix(trimmed, "define ") || trimmed == "define":
+			define = true
+			continue
+		case strings.HasPrefix(l, "\t"):
+			continue
+		}
+		m := makeRule.FindStringSubmatch(l)
+		if m == nil || makeVariable.MatchString(l) || makeAssignment.MatchString(l) {
+			continue
+		}
+
+		r := makeRuleLines{targets: strings.Fields(m[1]), start: first, end: i + 1}
+		// the recipe: lines starting with a tab, along with any blank or comment lines between them
+		for j := i + 1; j < len(lines); j++ {
+			if strings.HasPrefix(lines[j], "\t") {
+				r.hasRecipe = true
+				for strings.HasSuffix(lines[j], "\\") && j+1 < len(lines) {
+					j++
+				}
+				r.end = j + 1
+				continue
+			}
+			if t := strings.TrimSpace(lines[j]); t != "" && !strings.HasPrefix(t, "#") {
+				break
+			}
+		}
+		if strings.Contains(l[len(m[0]):], ";") { // an inline recipe, like `all: ; @echo done`
+			r.hasRecipe = true
+		}
+		r.start = makeLeadingLines(lines, r.start, m[1])
+		rules = append(rules, r)
+		i = r.end - 1
+	}
+
+	var (
+		found *makeRuleLines
+		avail []string
+		seen  = make(map[string]bool)
+	)
+	for i := range rules {
+		r := &rules[i]
+		for _, t := range r.targets {
+			if !strings.HasPrefix(t, ".") && !seen[t] {
+				seen[t] = true
+				avail = append(avail, t)
+			}
+			if t == name && (found == nil || !found.hasRecipe && r.hasRecipe) {
+				found = r
+			}
+		}
+	}
+	if found == nil {
+		return "", fmt.Errorf("couldn't find target %q; available: %v", name, summarizeKeys(avail))
+	}
+	return strings.Join(lines[found.start:found.end], "\n"), nil
+}
+
+// makeLeadingLines extends start upward over any comments, and any variables specific to the same targets, directly
+// above it.
+func makeLeadingLines(lines []string, start int, targets string) int {
+	for {
+		start = shellLeadingComments(lines, start)
+		if start == 0 || !makeAssignment.MatchString(lines[start-1]) {
+			return start
+		}
+		if m := makeRule.FindStringSubmatch(lines[start-1]); m == nil || m[1] != targets {
+			return start
+		}
+		start--
+	}
+}
+
+var (
+	// shellFuncHeader matches `name() {`, `function name {`, and the like, with the body's opening brace or paren
+	// optionally on the next line
+	shellFuncHeader = regexp.MustCompile(`^(\s*)(?:function\s+([^\s(){}]+)\s*(?:\(\s*\))?|([^\s(){}=#]+)\s*\(\s*\))\s*([{(])?`)
+	// shellHeredoc matches the start of a here-document, capturing its delimiter
+	shellHeredoc = regexp.MustCompile(`(?:^|[^<])<<-?\s*(?:'([^']+)'|"([^"]+)"|\\?([A-Za-z_][A-Za-z0-9_]*))`)
+)
+
+// parseShellFunc finds a shell function and returns it from its leading comments through its closing brace, which is
+// expected at the same indentation as its header.
+func parseShellFunc(src, name string) (string, error) {
+	lines := strings.Split(strings.Replace(src, "\r\n", "\n", -1), "\n")
+
+	var avail []string
+	for i := 0; i < len(lines); i++ {
+		if i = skipHeredocs(lines, i); i >= len(lines) {
+			break
+		}
+		m := shellFuncHeader.FindStringSubmatch(lines[i])
+		if m == nil {
+			continue
+		}
+		fn := m[2] + m[3]
+		avail = append(avail, fn)
+		if fn != name {
+			continue
+		}
+
+		indent, open, start := m[1], m[4], i
+		if open == "" && i+1 < len(lines) { // the body opens on the next line
+			i++
+			if body := strings.TrimSpace(lines[i]); body != "" {
+				open = body[:1]
+			}
+		}
+		shut := map[string]string{"{": "}", "(": ")"}[open]
+		if shut == "" {
+			return "", fmt.Errorf("line %d: function %q has no body", start+1, name)
+		}
+
+		end := -1
+		if body := strings.TrimSpace(lines[i]); strings.HasSuffix(body, shut) { // a one-liner
+			end = i + 1
+		}
+		for j := i + 1; end < 0 && j < len(lines); j++ {
+			if j = skipHeredocs(lines, j); j < len(lines) && strings.HasPrefix(lines[j], indent+shut) {
+				end = j + 1
+			}
+		}
+		if end < 0 {
+			return "", fmt.Errorf("line %d: couldn't find the %q which closes function %q", start+1, shut, name)
+		}
+		return string(dedentTabs(dedentSpaces([]byte(strings.Join(lines[shellLeadingComments(lines, start):end], "\n"))))), nil
+	}
+	return "", fmt.Errorf("couldn't find function %q; available: %v", name, summarizeKeys(avail))
+}
+
+// skipHeredocs returns the index of the first line at or after i which isn't within a here-document begun on an
+// earlier line.
+func skipHeredocs(lines []string, i int) int {
+	for ; i < len(lines); i++ {
+		m := shellHeredoc.FindStringSubmatch(lines[i])
+		if m == nil || strings.HasPrefix(strings.TrimSpace(lines[i]), "#") {
+			return i
+		}
+		delim := m[1] + m[2] + m[3]
+		for i++; i < len(lines) && strings.TrimLeft(lines[i], "\t") != delim; i++ {
+		}
+	}
+	return i
+}
+
+// shellLeadingComments extends start upward over any comment lines directly above it, stopping at a shebang.
+func shellLeadingComments(lines []string, start int) int {
+	for start > 0 {
+		l := strings.TrimSpace(lines[start-1])
+		if !strings.HasPrefix(l, "#") || strings.HasPrefix(l, "#!") {
+			break
+		}
+		start--
+	}
+	return start
+}
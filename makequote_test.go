@@ -0,0 +1,96 @@
+package main
+
+import (
+	"testing"
+)
+
+func Test_parseMakeTarget(t *testing.T) {
+	const src = "VERSION ::= 1.0\n" +
+		"define HELP\n" +
+		"help: not a rule\n" +
+		"endef\n" +
+		"\n" +
+		".PHONY: all help\n" +
+		"all: build\n" +
+		"\n" +
+		"# all builds everything.\n" +
+		"all:\n" +
+		"\t@echo building\n" +
+		"\n" +
+		"\t# still the recipe\n" +
+		"\t@echo done\n" +
+		"\n" +
+		"# help's comment\n" +
+		"help: ; @echo $(HELP)\n" +
+		"%.o: %.c\n" +
+		"\tcc -c $<\n"
+	for _, c := range []struct {
+		name, target string
+		out, err     string
+	}{
+		{name: "prefers a recipe", target: "all", out: "# all builds everything.\nall:\n\t@echo building\n\n\t# still the recipe\n\t@echo done"},
+		{name: "inline recipe", target: "help", out: "# help's comment\nhelp: ; @echo $(HELP)"},
+		{name: "pattern", target: "%.o", out: "%.o: %.c\n\tcc -c $<"},
+		{name: "missing", target: "VERSION", err: `couldn't find target "VERSION"; available: "all", "help", "%.o"`},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			out, err := parseMakeTarget(src, c.target)
+			if err != nil {
+				if err.Error() != c.err {
+					t.Fatalf("wanted error %q but got %q", c.err, err)
+				}
+				return
+			}
+			if c.err != "" {
+				t.Fatalf("wanted error %q but got none", c.err)
+			}
+			if out != c.out {
+				t.Errorf("wanted:\n%v\ngot:\n%v", c.out, out)
+			}
+		})
+	}
+}
+
+func Test_parseShellFunc(t *testing.T) {
+	const src = "#!/bin/bash\n" +
+		"# usage prints help.\n" +
+		"usage() {\n" +
+		"  cat <<-EOF\n" +
+		"\t}\n" +
+		"\tEOF\n" +
+		"  x=\"$(cat <<<\"$y\")\"\n" +
+		"}\n" +
+		"function quiet { \"$@\" >/dev/null; }\n" +
+		"\tfunction sub()\n" +
+		"\t(\n" +
+		"\t\tcd /tmp\n" +
+		"\t)\n" +
+		"broken() {\n" +
+		"  echo\n"
+	for _, c := range []struct {
+		name, fn string
+		out, err string
+	}{
+		{name: "heredocs", fn: "usage", out: "# usage prints help.\nusage() {\n  cat <<-EOF\n\t}\n\tEOF\n  x=\"$(cat <<<\"$y\")\"\n}"},
+		{name: "one-liner", fn: "quiet", out: "function quiet { \"$@\" >/dev/null; }"},
+		{name: "subshell on the next line", fn: "sub", out: "function sub()\n(\n\tcd /tmp\n)"},
+		{name: "unclosed", fn: "broken", err: `line 14: couldn't find the "}" which closes function "broken"`},
+		{name: "missing", fn: "nope", err: `couldn't find function "nope"; available: "usage", "quiet", "sub", "broken"`},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			out, err := parseShellFunc(src, c.fn)
+			if err != nil {
+				if err.Error() != c.err {
+					t.Fatalf("wanted error %q but got %q", c.err, err)
+				}
+				return
+			}
+			if c.err != "" {
+				t.Fatalf("wanted error %q but got none", c.err)
+			}
+			if out != c.out {
+				t.Errorf("wanted:\n%v\ngot:\n%v", c.out, out)
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+func expandMDQuotes(_ context.Context, pqs []*pullQuote) ([]*expanded, error) {
+	exp := make([]*expanded, 0, len(pqs))
+	for _, pq := range pqs {
+		pat, anchor, _ := splitObjPath(pq.objPath) // no anchor quotes the whole file
+
+		s, err := func() (string, error) {
+			src, err := ioutil.ReadFile(pat)
+			if err != nil {
+				return "", err
+			}
+			lines := strings.Split(strings.Replace(string(src), "\r\n", "\n", -1), "\n")
+			if lines, err = mdSection(lines, anchor, pq.flags&omitHeading != 0, pq.headingShift); err != nil {
+				return "", err
+			}
+			b := []byte(strings.Join(lines, "\n"))
+			if pq.sub.isSet() {
+				if b, _, err = pq.sub.apply(b); err != nil {
+					return "", fmt.Errorf("selecting within %q: %w", anchor, err)
+				}
+			}
+			return stripPullQuoteComments(string(bytes.Trim(b, "\n"))), nil
+		}()
+		if err != nil {
+			return nil, fmt.Errorf("error within %v: %w", pat, err)
+		}
+		exp = append(exp, &expanded{String: s})
+	}
+	return exp, nil
+}
+
+// mdHeading is an ATX heading, like `## Install`, or a setext heading, underlined with `=` or `-`.
+type mdHeading struct {
+	level int
+	text  string
+	// line and end are the heading's first and last lines; a setext heading may span a paragraph and its underline
+	line, end int
+	anchor    string
+}
+
+var (
+	mdATXHeading    = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	mdSetextLine    = regexp.MustCompile(`^ {0,3}(=+|-+)[ \t]*$`)
+	mdFence         = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})")
+	mdLink          = regexp.MustCompile(`!?\[([^\]]*)\]\([^)]*\)`)
+	mdHTMLTag       = regexp.MustCompile(`</?[A-Za-z][^>]*>`)
+	mdListOrQuote   = regexp.MustCompile(`^ {0,3}(?:[-*+>]|\d+[.)])(?:[ \t]|$)`)
+	mdFenceInfoTick = regexp.MustCompile("`.*`")
+)
+
+// mdHeadings finds the headings in a Markdown document, skipping any in fenced code blocks, and gives each the anchor
+// GitHub would: a slug of its text, suffixed with `-1`, `-2`, and so on if an earlier heading has the same slug.
+func mdHeadings(lines []string) []mdHeading {
+	var (
+		headings []mdHeading
+		fence    string // the opening fence of the current code block
+		para     = -1   // the first line of the current paragraph, which a setext underline makes a heading
+		slugs    = make(map[string]int)
+	)
+	for i := 0; i < len(lines); i++ {
+		l := lines[i]
+		if fence != "" {
+			if m := mdFence.FindStringSubmatch(l); m != nil && m[1][0] == fence[0] && len(m[1]) >= len(fence) &&
+				strings.TrimSpace(l[len(m[0]):]) == "" {
+				fence = ""
+			}
+			continue
+		}
+		if m := mdFence.FindStringSubmatch(l); m != nil && !(m[1][0] == '`' && mdFenceInfoTick.MatchString(l[len(m[0]):])) {
+			fence, para = m[1], -1
+			continue
+		}
+
+		var h mdHeading
+		if m := mdATXHeading.FindStringSubmatch(l); m != nil {
+			h = mdHeading{level: len(m[1]), text: m[2], line: i, end: i}
+		} else if strings.TrimSpace(l) == "" || para < 0 && (strings.HasPrefix(l, "    ") || mdListOrQuote.MatchString(l)) {
+			para = -1
+			continue
+		} else if m := mdSetextLine.FindStringSubmatch(l); m != nil && para >= 0 {
+			h = mdHeading{level: 1, line: para, end: i}
+			for _, pl := range lines[para:i] {
+				h.text += " " + strings.TrimSpace(pl)
+			}
+			h.text = h.text[1:]
+			if m[1][0] == '-' {
+				h.level = 2
+			}
+		} else {
+			if para < 0 && !mdSetextLine.MatchString(l) {
+				para = i
+			}
+			continue
+		}
+		para = -1
+
+		h.anchor = mdSlug(h.text)
+		if n := slugs[h.anchor]; n > 0 {
+			slugs[h.anchor]++
+			h.anchor += "-" + strconv.Itoa(n)
+		} else {
+			slugs[h.anchor] = 1
+		}
+		headings = append(headings, h)
+	}
+	return headings
+}
+
+// mdSlug makes a heading's text into an anchor the way GitHub does: lowercased, stripped of punctuation other than
+// hyphens and underscores, with spaces made hyphens.
+func mdSlug(text string) string {
+	text = mdLink.ReplaceAllString(text, "$1")
+	text = mdHTMLTag.ReplaceAllString(text, "")
+	var b strings.Builder
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case r == ' ':
+			b.WriteRune('-')
+		case r == '-' || unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.IsMark(r) || unicode.Is(unicode.Pc, r):
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// mdSection returns the lines under the heading with an anchor, up to the next heading of the same or a higher level;
+// with an empty anchor, it returns the whole document. The heading is omitted with noHeading, and every heading is
+// shifted by shift levels.
+func mdSection(lines []string, anchor string, noHeading bool, shift int) ([]string, error) {
+	headings := mdHeadings(lines)
+	start, end, first := 0, len(lines), 0
+	if anchor != "" {
+		first = -1
+		avail := make([]string, 0, len(headings))
+		for i, h := range headings {
+			avail = append(avail, h.anchor)
+			if first < 0 && strings.EqualFold(h.anchor, anchor) {
+				first = i
+			}
+		}
+		if first < 0 {
+			return nil, fmt.Errorf("couldn't find a heading with anchor %q; available: %v", anchor, summarizeKeys(avail))
+		}
+		start = headings[first].line
+		for _, h := range headings[first+1:] {
+			if h.level <= headings[first].level {
+				end = h.line
+				break
+			}
+		}
+		headings = headings[first:]
+	}
+
+	out := make([]string, 0, end-start)
+	for i := start; i < end; i++ {
+		if len(headings) == 0 || headings[0].line != i {
+			out = append(out, lines[i])
+			continue
+		}
+		h := headings[0]
+		headings, i = headings[1:], headings[0].end
+		if anchor != "" && h.line == start && noHeading {
+			continue
+		}
+		if shift == 0 {
+			out = append(out, lines[h.line:h.end+1]...)
+			continue
+		}
+		level := h.level + shift
+		if level < 1 || level > 6 {
+			return nil, fmt.Errorf("shift=%d would make %q a level %d heading", shift, h.text, level)
+		}
+		out = append(out, strings.Repeat("#", level)+" "+h.text)
+	}
+	return out, nil
+}
+
+// stripPullQuoteComments removes the comments which begin and end pullquotes from quoted Markdown, leaving what they
+// expanded to, so that the host document doesn't inherit them.
+func stripPullQuoteComments(s string) string {
+	var (
+		b    strings.Builder
+		last int
+	)
+	comments := htmlCommentScanner(strings.NewReader(s))
+	for comments.Scan() {
+		c := comments.Bytes()
+		toks := tokenizingScanner(bytes.NewReader(c[len("<!--") : len(c)-len("-->")]))
+		toks.Scan()
+		if _, ok := quoteTags[strings.TrimPrefix(toks.Text(), "/")]; !ok {
+			continue
+		}
+		start, end := comments.start, comments.end
+		if ls := strings.LastIndexByte(s[:start], '\n') + 1; strings.TrimSpace(s[ls:start]) == "" {
+			if le := strings.IndexByte(s[end:], '\n'); le >= 0 && strings.TrimSpace(s[end:end+le]) == "" {
+				start, end = ls, end+le+1 // the comment's whole line
+			}
+		}
+		b.WriteString(s[last:start])
+		last = end
+	}
+	b.WriteString(s[last:])
+	return strings.TrimRight(b.String(), "\n")
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_mdSlug(t *testing.T) {
+	for _, c := range []struct{ text, want string }{
+		{"Quick start", "quick-start"},
+		{"The `pullquote` CLI: v1.2!", "the-pullquote-cli-v12"},
+		{"See [the docs](https://example.com) <em>now</em>", "see-the-docs-now"},
+		{"snake_case & kebab-case", "snake_case--kebab-case"},
+		{"Über Café", "über-café"},
+	} {
+		if got := mdSlug(c.text); got != c.want {
+			t.Errorf("mdSlug(%q): wanted %q but got %q", c.text, c.want, got)
+		}
+	}
+}
+
+func Test_mdSection(t *testing.T) {
+	const src = "# Title\n" +
+		"\n" +
+		"## A ##\n" +
+		"a\n" +
+		"````md\n" +
+		"# fenced\n" +
+		"```\n" +
+		"still fenced\n" +
+		"````\n" +
+		"### A.1\n" +
+		"Setext\n" +
+		"======\n" +
+		"one\n" +
+		"two\n" +
+		"---\n" +
+		"## A\n"
+	for _, c := range []struct {
+		name, anchor string
+		noHeading    bool
+		shift        int
+		out, err     string
+	}{
+		{name: "to the next heading at its level", anchor: "a", out: "## A ##\na\n````md\n# fenced\n```\nstill fenced\n````\n### A.1"},
+		{name: "noheading", anchor: "a1", noHeading: true, out: ""},
+		{name: "setext", anchor: "setext", shift: 1, out: "## Setext\n### one two\n### A"},
+		{name: "setext paragraphs", anchor: "one-two", out: "one\ntwo\n---"},
+		{name: "duplicates", anchor: "a-1", shift: -1, out: "# A"},
+		{name: "whole", shift: 5, err: `shift=5 would make "A" a level 7 heading`},
+		{name: "missing", anchor: "b", err: `couldn't find a heading with anchor "b"; available: "title", "a", "a1", "setext", "one-two", "a-1"`},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			out, err := mdSection(strings.Split(src, "\n"), c.anchor, c.noHeading, c.shift)
+			if err != nil {
+				if err.Error() != c.err {
+					t.Fatalf("wanted error %q but got %q", c.err, err)
+				}
+				return
+			}
+			if c.err != "" {
+				t.Fatalf("wanted error %q but got none", c.err)
+			}
+			if got := strings.TrimRight(strings.Join(out, "\n"), "\n"); got != c.out {
+				t.Errorf("wanted:\n%v\ngot:\n%v", c.out, got)
+			}
+		})
+	}
+}
+
+func Test_stripPullQuoteComments(t *testing.T) {
+	const in = "before\n" +
+		"<!-- goquote x.go#main -->\n" +
+		"```go\n" +
+		"<!-- pullquote inside a fence stays -->\n" +
+		"```\n" +
+		"<!-- /goquote --> trailing\n" +
+		"<!-- an ordinary comment -->\n" +
+		"after"
+	const want = "before\n" +
+		"```go\n" +
+		"<!-- pullquote inside a fence stays -->\n" +
+		"```\n" +
+		" trailing\n" +
+		"<!-- an ordinary comment -->\n" +
+		"after"
+	if got := stripPullQuoteComments(in); got != want {
+		t.Errorf("wanted:\n%v\ngot:\n%v", want, got)
+	}
+}
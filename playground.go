@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// sprintPlayground renders the named top-level declarations as a standalone `package main` program, pulling in the
+// imports and same-package declarations they depend on. Example functions are called from a generated main.
+func sprintPlayground(ctx context.Context, fSet *token.FileSet, pat, syms string, typeCheck bool) (*expanded, error) {
+	if _, _, pinned := splitPinned(pat); pinned {
+		return nil, errors.New("fmt=playground requires type information, which isn't available for pinned versions")
+	}
+	pkgs, err := loadPackages(ctx, fSet, loadMode|packages.NeedTypesInfo, pat)
+	if err != nil {
+		return nil, err
+	}
+
+	names := strings.Split(syms, ",")
+	pkg, roots, err := lookupRoots(pkgs, names)
+	if err != nil {
+		return nil, err
+	}
+
+	decls, err := playgroundDecls(pkg, roots)
+	if err != nil {
+		return nil, err
+	}
+
+	// render in source order
+	sort.Slice(decls, func(i, j int) bool {
+		pi, pj := fSet.Position(decls[i].Pos()), fSet.Position(decls[j].Pos())
+		if pi.Filename != pj.Filename {
+			return pi.Filename < pj.Filename
+		}
+		return pi.Offset < pj.Offset
+	})
+
+	var (
+		spans    = make([]span, 0, len(decls))
+		rendered = make([][]byte, 0, len(decls))
+		sources  = make(map[string][]byte)
+		hasMain  bool
+	)
+	for _, d := range decls {
+		pos, end := nodeSpan(declDoc(d), d)
+		spans = append(spans, span{pos, end})
+
+		tf := fSet.File(pos)
+		src, ok := sources[tf.Name()]
+		if !ok {
+			if src, err = ioutil.ReadFile(tf.Name()); err != nil {
+				return nil, err
+			}
+			sources[tf.Name()] = src
+		}
+		rendered = append(rendered, src[tf.Offset(pos):tf.Offset(end)])
+
+		if fd, ok := d.(*ast.FuncDecl); ok && fd.Recv == nil && fd.Name.Name == "main" {
+			hasMain = true
+		}
+	}
+
+	imports, err := importsFor(fSet, pkgs, spans...)
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	b.WriteString("package main\n\n")
+	if imports != "" {
+		b.WriteString(imports + "\n\n")
+	}
+	b.Write(bytes.Join(rendered, []byte("\n\n")))
+	if !hasMain {
+		b.WriteString("\n\nfunc main() {\n")
+		for _, n := range names {
+			if strings.HasPrefix(n, "Example") {
+				b.WriteString("\t" + n + "()\n")
+			}
+		}
+		b.WriteString("}")
+	}
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("generated program is invalid: %w", err)
+	}
+	if typeCheck {
+		if err := typeCheckProgram(pkg, src); err != nil {
+			return nil, err
+		}
+	}
+	return &expanded{String: strings.TrimRight(string(src), "\n")}, nil
+}
+
+// lookupRoots finds the package, among those loaded, declaring all the named objects at package scope.
+func lookupRoots(pkgs []*packages.Package, names []string) (*packages.Package, []types.Object, error) {
+	var missing string
+	for _, pkg := range pkgs {
+		if pkg.Types == nil || pkg.TypesInfo == nil {
+			continue
+		}
+		roots := make([]types.Object, 0, len(names))
+		for _, n := range names {
+			obj := pkg.Types.Scope().Lookup(n)
+			if obj == nil {
+				missing = n
+				break
+			}
+			roots = append(roots, obj)
+		}
+		if len(roots) == len(names) {
+			return pkg, roots, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("couldn't find %q", missing)
+}
+
+// playgroundDecls computes the top-level declarations needed for the roots to compile: anything in the same package
+// they transitively reference, along with the methods of any types included.
+func playgroundDecls(pkg *packages.Package, roots []types.Object) ([]ast.Decl, error) {
+	var (
+		info    = pkg.TypesInfo
+		declOf  = make(map[types.Object]ast.Decl)
+		methods = make(map[types.Object][]ast.Decl)
+	)
+	for _, f := range pkg.Syntax {
+		for _, d := range f.Decls {
+			switch d := d.(type) {
+			case *ast.FuncDecl:
+				obj, ok := info.Defs[d.Name].(*types.Func)
+				if !ok {
+					continue
+				}
+				declOf[obj] = d
+				if recv := obj.Type().(*types.Signature).Recv(); recv != nil {
+					t := recv.Type()
+					if p, ok := t.(*types.Pointer); ok {
+						t = p.Elem()
+					}
+					if n, ok := t.(*types.Named); ok {
+						methods[n.Obj()] = append(methods[n.Obj()], d)
+					}
+				}
+			case *ast.GenDecl:
+				for _, s := range d.Specs {
+					switch s := s.(type) {
+					case *ast.ValueSpec:
+						for _, n := range s.Names {
+							if obj := info.Defs[n]; obj != nil {
+								declOf[obj] = d
+							}
+						}
+					case *ast.TypeSpec:
+						if obj := info.Defs[s.Name]; obj != nil {
+							declOf[obj] = d
+						}
+					}
+				}
+			}
+		}
+	}
+
+	var (
+		included = make(map[ast.Decl]bool)
+		queue    []ast.Decl
+		add      = func(d ast.Decl) {
+			if d != nil && !included[d] {
+				included[d] = true
+				queue = append(queue, d)
+			}
+		}
+	)
+	for _, r := range roots {
+		d, ok := declOf[r]
+		if !ok {
+			return nil, fmt.Errorf("couldn't find declaration of %q", r.Name())
+		}
+		add(d)
+	}
+	for len(queue) > 0 {
+		d := queue[0]
+		queue = queue[1:]
+
+		ast.Inspect(d, func(n ast.Node) bool {
+			switch x := n.(type) {
+			case *ast.Ident:
+				if obj := info.Uses[x]; obj != nil && obj.Pkg() == pkg.Types {
+					add(declOf[obj])
+				}
+				if obj := info.Defs[x]; obj != nil {
+					for _, m := range methods[obj] { // keep interface satisfaction intact
+						add(m)
+					}
+				}
+			}
+			return true
+		})
+	}
+
+	decls := make([]ast.Decl, 0, len(included))
+	for d := range included {
+		decls = append(decls, d)
+	}
+	return decls, nil
+}
+
+func declDoc(d ast.Decl) *ast.CommentGroup {
+	switch d := d.(type) {
+	case *ast.FuncDecl:
+		return d.Doc
+	case *ast.GenDecl:
+		return d.Doc
+	}
+	return nil
+}
+
+type importerFunc func(path string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) {
+	return f(path)
+}
+
+// typeCheckProgram checks the generated program against the types of the original package's imports, which are
+// already loaded, so no further loading is necessary.
+func typeCheckProgram(pkg *packages.Package, src []byte) error {
+	fSet := token.NewFileSet()
+	f, err := parser.ParseFile(fSet, "main.go", src, 0)
+	if err != nil {
+		return fmt.Errorf("generated program is invalid: %w", err)
+	}
+
+	var errs []string
+	conf := types.Config{
+		Importer: importerFunc(func(path string) (*types.Package, error) {
+			if imp, ok := pkg.Imports[path]; ok && imp.Types != nil {
+				return imp.Types, nil
+			}
+			return nil, fmt.Errorf("package %q not loaded", path)
+		}),
+		Error: func(err error) {
+			errs = append(errs, err.Error())
+		},
+	}
+	_, _ = conf.Check("main", fSet, []*ast.File{f}, nil)
+	if len(errs) > 0 {
+		return fmt.Errorf("generated program doesn't type check: %v", strings.Join(errs, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/packages"
+)
+
+func Test_typeCheckProgram(t *testing.T) {
+	for _, c := range []struct {
+		name, src, err string
+	}{
+		{
+			"valid",
+			"package main\n\nfunc main() {\n\thelper()\n}\n\nfunc helper() {}\n",
+			"",
+		},
+		{
+			"missing dependency",
+			"package main\n\nfunc main() {\n\thelper()\n}\n",
+			"generated program doesn't type check: main.go:4:2: undefined: helper",
+		},
+		{
+			"unloaded import",
+			"package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println()\n}\n",
+			`package "fmt" not loaded`,
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			err := typeCheckProgram(&packages.Package{}, []byte(c.src))
+			var errS string
+			if err != nil {
+				errS = err.Error()
+			}
+			if c.err == "" && errS != "" || !strings.Contains(errS, c.err) {
+				t.Fatalf("expected %q but got %q", c.err, errS)
+			}
+		})
+	}
+}
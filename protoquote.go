@@ -0,0 +1,340 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+func expandProtoQuotes(_ context.Context, pqs []*pullQuote) ([]*expanded, error) {
+	exp := make([]*expanded, 0, len(pqs))
+	for _, pq := range pqs {
+		pat, sym, hasSym := splitObjPath(pq.objPath) // no symbol quotes the whole file
+
+		s, err := func() (string, error) {
+			src, err := ioutil.ReadFile(pat)
+			if err != nil {
+				return "", err
+			}
+			b := bytes.TrimRight(src, "\r\n")
+			if hasSym {
+				if b, err = sprintProtoDecl(src, sym, pq.flags&includeGroup != 0); err != nil {
+					return "", err
+				}
+			}
+			if pq.sub.isSet() {
+				if b, _, err = pq.sub.apply(b); err != nil {
+					return "", fmt.Errorf("selecting within %q: %w", sym, err)
+				}
+				b = dedentTabs(dedentSpaces(b))
+			}
+			return string(b), nil
+		}()
+		if err != nil {
+			return nil, fmt.Errorf("error within %v: %w", pat, err)
+		}
+		exp = append(exp, &expanded{String: s})
+	}
+	return exp, nil
+}
+
+// protoDecl is a message, enum, service, oneof, or rpc within a .proto file.
+type protoDecl struct {
+	kind, name string
+	// start is the offset of the declaration's keyword; end is just past its closing brace or semicolon
+	start, end int
+	parent     *protoDecl
+	children   []*protoDecl
+}
+
+func (d *protoDecl) path() string {
+	if d.parent == nil {
+		return d.name
+	}
+	return d.parent.path() + "." + d.name
+}
+
+type protoFile struct {
+	pkg   string
+	decls []*protoDecl
+}
+
+// sprintProtoDecl finds the declaration at a dotted path like `User.Address` or `UserService.GetUser` -- optionally
+// qualified by the file's package -- and returns its text along with any leading comments. With parent, it returns
+// the enclosing message or service instead.
+func sprintProtoDecl(src []byte, sym string, parent bool) ([]byte, error) {
+	f, err := parseProto(src)
+	if err != nil {
+		return nil, err
+	}
+	d, err := f.find(sym)
+	if err != nil {
+		return nil, err
+	}
+	if parent {
+		if d.parent == nil {
+			return nil, fmt.Errorf("includegroup: %v %q isn't nested within a message or service", d.kind, d.path())
+		}
+		d = d.parent
+	}
+
+	start := lineStart(src, d.start)
+	if len(bytes.TrimSpace(src[start:d.start])) > 0 { // shares its line with something else
+		start = d.start
+	} else {
+		start = protoLeadingComments(src, start)
+	}
+	return dedentTabs(dedentSpaces(src[start:d.end])), nil
+}
+
+func (f *protoFile) find(sym string) (*protoDecl, error) {
+	name := strings.TrimPrefix(sym, ".")
+	if f.pkg != "" {
+		name = strings.TrimPrefix(name, f.pkg+".")
+	}
+	var (
+		path   = strings.Split(name, ".")
+		found  *protoDecl
+		within = f.decls
+	)
+	for i, seg := range path {
+		found = nil
+		for _, d := range within {
+			if d.name == seg {
+				found = d
+				break
+			}
+		}
+		if found == nil {
+			avail := make([]string, 0, len(within))
+			for _, d := range within {
+				avail = append(avail, d.path())
+			}
+			if i == 0 && f.pkg != "" {
+				return nil, fmt.Errorf("couldn't find %q in package %v; available: %v", sym, f.pkg, summarizeKeys(avail))
+			}
+			return nil, fmt.Errorf("couldn't find %q; available: %v", sym, summarizeKeys(avail))
+		}
+		within = found.children
+	}
+	return found, nil
+}
+
+// protoLeadingComments extends start, the beginning of a line, upward over any comment lines directly above it.
+func protoLeadingComments(src []byte, start int) int {
+	for start > 0 {
+		prev := lineStart(src, start-1)
+		l := bytes.TrimSpace(src[prev : start-1])
+		switch {
+		case bytes.HasPrefix(l, []byte("//")):
+			start = prev
+		case bytes.HasSuffix(l, []byte("*/")):
+			open := bytes.LastIndex(src[:start], []byte("/*"))
+			if open < 0 {
+				return start
+			}
+			ls := lineStart(src, open)
+			if len(bytes.TrimSpace(src[ls:open])) > 0 { // trails something else
+				return start
+			}
+			start = ls
+		default:
+			return start
+		}
+	}
+	return start
+}
+
+func lineStart(src []byte, off int) int {
+	return bytes.LastIndexByte(src[:off], '\n') + 1
+}
+
+// parseProto finds the declarations in a .proto file. It knows only as much of the grammar as it takes to find them;
+// everything else is skipped a statement at a time.
+func parseProto(src []byte) (*protoFile, error) {
+	s := &protoScanner{src: src}
+	f := &protoFile{}
+	decls, err := s.parseBody(nil, f)
+	if err != nil {
+		return nil, err
+	}
+	f.decls = decls
+	return f, nil
+}
+
+func (s *protoScanner) parseBody(parent *protoDecl, f *protoFile) ([]*protoDecl, error) {
+	var decls []*protoDecl
+	for {
+		tok, off, err := s.next()
+		if err != nil {
+			return nil, err
+		}
+		switch tok {
+		case "":
+			if parent != nil {
+				return nil, fmt.Errorf("%v: unclosed %v %q", s.position(parent.start), parent.kind, parent.path())
+			}
+			return decls, nil
+		case "}":
+			if parent == nil {
+				return nil, fmt.Errorf("%v: unexpected '}'", s.position(off))
+			}
+			parent.end = s.pos
+			return decls, nil
+		case ";":
+		case "package":
+			name, _, err := s.next()
+			if err != nil {
+				return nil, err
+			}
+			if parent == nil {
+				f.pkg = name
+			}
+			if err := s.skipStatement(); err != nil {
+				return nil, err
+			}
+		case "message", "enum", "service", "oneof", "rpc":
+			name, nameOff, err := s.next()
+			if err != nil {
+				return nil, err
+			}
+			if !isProtoIdent(name) { // e.g. an enum value named `message`
+				s.pos = nameOff
+				if err := s.skipStatement(); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			d := &protoDecl{kind: tok, name: name, start: off, parent: parent}
+			if tok == "rpc" {
+				if err := s.skipStatement(); err != nil {
+					return nil, err
+				}
+				d.end = s.pos
+			} else {
+				open, openOff, err := s.next()
+				if err != nil {
+					return nil, err
+				}
+				if open != "{" {
+					return nil, fmt.Errorf("%v: expected '{' after %v %v, not %q", s.position(openOff), tok, name, open)
+				}
+				if d.children, err = s.parseBody(d, f); err != nil {
+					return nil, err
+				}
+			}
+			decls = append(decls, d)
+		default:
+			if err := s.skipStatement(); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// protoScanner tokenizes a .proto file, skipping whitespace and comments.
+type protoScanner struct {
+	src []byte
+	pos int
+}
+
+// skipStatement advances past a statement's `;` or, if it has a top-level block -- like an aggregate option, a
+// proto2 group, or an rpc's options -- past that block. It stops before a `}` which closes the enclosing body.
+func (s *protoScanner) skipStatement() error {
+	depth := 0
+	for {
+		tok, off, err := s.next()
+		if err != nil {
+			return err
+		}
+		switch tok {
+		case "":
+			return nil
+		case "(", "[", "{":
+			depth++
+		case ")", "]":
+			depth--
+		case "}":
+			if depth == 0 {
+				s.pos = off
+				return nil
+			}
+			if depth--; depth == 0 {
+				return nil
+			}
+		case ";":
+			if depth == 0 {
+				return nil
+			}
+		}
+	}
+}
+
+// next returns the next token and its offset, or "" at the end of the file.
+func (s *protoScanner) next() (string, int, error) {
+	for s.pos < len(s.src) {
+		switch c := s.src[s.pos]; {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			s.pos++
+		case bytes.HasPrefix(s.src[s.pos:], []byte("//")):
+			if i := bytes.IndexByte(s.src[s.pos:], '\n'); i >= 0 {
+				s.pos += i
+			} else {
+				s.pos = len(s.src)
+			}
+		case bytes.HasPrefix(s.src[s.pos:], []byte("/*")):
+			i := bytes.Index(s.src[s.pos+2:], []byte("*/"))
+			if i < 0 {
+				return "", 0, fmt.Errorf("%v: unterminated comment", s.position(s.pos))
+			}
+			s.pos += i + 4
+		case c == '"' || c == '\'':
+			start := s.pos
+			for s.pos++; s.pos < len(s.src) && s.src[s.pos] != c; s.pos++ {
+				if s.src[s.pos] == '\\' {
+					s.pos++
+				} else if s.src[s.pos] == '\n' {
+					break
+				}
+			}
+			if s.pos >= len(s.src) || s.src[s.pos] != c {
+				return "", 0, fmt.Errorf("%v: unterminated string", s.position(start))
+			}
+			s.pos++
+			return string(s.src[start:s.pos]), start, nil
+		case isProtoIdentByte(c):
+			start := s.pos
+			for s.pos < len(s.src) && (isProtoIdentByte(s.src[s.pos]) || s.src[s.pos] == '.') {
+				s.pos++
+			}
+			return string(s.src[start:s.pos]), start, nil
+		default:
+			s.pos++
+			return string(c), s.pos - 1, nil
+		}
+	}
+	return "", s.pos, nil
+}
+
+func (s *protoScanner) position(off int) string {
+	line := bytes.Count(s.src[:off], []byte("\n")) + 1
+	return fmt.Sprintf("line %d, column %d", line, off-lineStart(s.src, off)+1)
+}
+
+func isProtoIdentByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+func isProtoIdent(s string) bool {
+	if s == "" || s[0] >= '0' && s[0] <= '9' {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isProtoIdentByte(s[i]) {
+			return false
+		}
+	}
+	return true
+}
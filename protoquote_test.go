@@ -0,0 +1,73 @@
+package main
+
+import (
+	"testing"
+)
+
+func Test_sprintProtoDecl(t *testing.T) {
+	const src = `syntax = "proto2";
+package demo;
+
+// detached comment
+
+// Outer has a group and an aggregate option.
+message Outer {
+  option (ext) = { name: "x}" };
+  optional group Result = 1 {
+    required string url = 2;
+  }
+  message Inner { enum Kind { message = 0; } } // trailing
+}
+/* Svc */ service Svc {
+  rpc Do(Outer) returns (Outer);
+}
+`
+	for _, c := range []struct {
+		name, sym string
+		parent    bool
+		src       string
+		out, err  string
+	}{
+		{
+			name: "skips detached comments",
+			sym:  "Outer",
+			out: "// Outer has a group and an aggregate option.\n" +
+				"message Outer {\n" +
+				"  option (ext) = { name: \"x}\" };\n" +
+				"  optional group Result = 1 {\n" +
+				"    required string url = 2;\n" +
+				"  }\n" +
+				"  message Inner { enum Kind { message = 0; } } // trailing\n" +
+				"}",
+		},
+		{name: "shared line", sym: "Outer.Inner.Kind", out: "enum Kind { message = 0; }"},
+		{name: "qualified", sym: ".demo.Outer.Inner", out: "message Inner { enum Kind { message = 0; } }"},
+		{name: "trailing block comment", sym: "Svc.Do", out: "rpc Do(Outer) returns (Outer);"},
+		{name: "parent", sym: "Outer.Inner", parent: true, out: "// Outer has a group and an aggregate option.\nmessage Outer {\n  option (ext) = { name: \"x}\" };\n  optional group Result = 1 {\n    required string url = 2;\n  }\n  message Inner { enum Kind { message = 0; } } // trailing\n}"},
+		{name: "top-level parent", sym: "Svc", parent: true, err: `includegroup: service "Svc" isn't nested within a message or service`},
+		{name: "missing", sym: "Nope", err: `couldn't find "Nope" in package demo; available: "Outer", "Svc"`},
+		{name: "missing nested", sym: "Outer.Nope", err: `couldn't find "Outer.Nope"; available: "Outer.Inner"`},
+		{name: "unclosed", sym: "A", src: "message A {\n  string b = 1;\n", err: `line 1, column 1: unclosed message "A"`},
+		{name: "unterminated string", sym: "A", src: "message A {\n  option x = \"y;\n}", err: "line 2, column 14: unterminated string"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			in := c.src
+			if in == "" {
+				in = src
+			}
+			out, err := sprintProtoDecl([]byte(in), c.sym, c.parent)
+			if err != nil {
+				if err.Error() != c.err {
+					t.Fatalf("wanted error %q but got %q", c.err, err)
+				}
+				return
+			}
+			if c.err != "" {
+				t.Fatalf("wanted error %q but got none", c.err)
+			}
+			if string(out) != c.out {
+				t.Errorf("wanted:\n%v\ngot:\n%v", c.out, string(out))
+			}
+		})
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"go/token"
 	"hash"
 	"io"
 	"io/ioutil"
@@ -183,7 +184,7 @@ func processFiles(ctx context.Context, checkMode bool, fns <-chan string) error
 			}
 			inFlight++
 			go func(fn string) {
-				tempFn, err := processFile(processCtx, tmpDir, fn)
+				tempFn, err := processFile(processCtx, tmpDir, fn, checkMode)
 				select {
 				case resultCh <- result{fn, tempFn, err}:
 				case <-processCtx.Done():
@@ -417,7 +418,7 @@ func ctxLogf(ctx context.Context, format string, args ...interface{}) {
 	_ = logger.Output(2, b.String())
 }
 
-func processFile(ctx context.Context, tmpDir, fn string) (string, error) {
+func processFile(ctx context.Context, tmpDir, fn string, checkMode bool) (string, error) {
 	ctx = addLogCtx(ctx, "filename=%q", fn)
 
 	f, err := os.Open(fn)
@@ -446,7 +447,8 @@ func processFile(ctx context.Context, tmpDir, fn string) (string, error) {
 		if pq.src != "" {
 			pq.src = filepath.Join(dir, pq.src)
 		}
-		if pq.objPath != "" && (strings.HasPrefix(pq.objPath, "./") || strings.Contains(pq.objPath, ".go")) {
+		looksLikeFile := strings.HasPrefix(pq.objPath, "./") || strings.Contains(pq.objPath, ".go")
+		if pq.objPath != "" && (!quoteTypes[pq.quoteType].wdRelative || looksLikeFile) {
 			pq.objPath = filepath.Join(dir, pq.objPath)
 		}
 	}
@@ -456,6 +458,26 @@ func processFile(ctx context.Context, tmpDir, fn string) (string, error) {
 		return "", fmt.Errorf("expandedPullQuotes: %w", err)
 	}
 
+	rules, err := loadRedactRules(dir)
+	if err != nil {
+		return "", fmt.Errorf("loadRedactRules: %w", err)
+	}
+	for i, pq := range pqs {
+		redactExpanded(pq, expanded[i], rules.forQuote(pq))
+		if re := rules.findSecret(expanded[i]); re != nil {
+			source := pq.src
+			if source == "" {
+				source = pq.objPath
+			}
+			err := fmt.Errorf("%vquote of %v would publish a secret matching %q", pq.originalTag, source, re)
+			if checkMode {
+				return "", err
+			}
+			ctxLogf(ctx, `msg="redacted secret" err=%q`, err)
+			rules.redactSecrets(expanded[i])
+		}
+	}
+
 	o, err := ioutil.TempFile(tmpDir, "")
 	if err != nil {
 		return "", fmt.Errorf("unable to open tmp file: %w", err)
@@ -586,23 +608,38 @@ func applyPullQuotes(pqs []*pullQuote, expanded []*expanded, r readerAtSeeker, w
 		}
 		readThrough = pq.startIdx
 
-		switch pq.fmt {
-		case fmtExample:
-			if len(exp.Parts) != 2 {
-				writeCodeFence(exp.String, pq.lang)
-				break
+		blocks := exp.Blocks
+		if len(blocks) == 0 {
+			blocks = []string{exp.String}
+		}
+		for _, block := range blocks {
+			switch pq.fmt {
+			case fmtExample:
+				if len(exp.Parts) != 2 {
+					writeCodeFence(block, pq.lang)
+					break
+				}
+				write("\n**Code**:")
+				writeCodeFence(exp.Parts[0], pq.lang)
+				write("**Output**:")
+				writeCodeFence(exp.Parts[1], "")
+			case fmtCodeFence, fmtPlayground:
+				writeCodeFence(block, pq.lang)
+			case fmtMethodSet:
+				if pq.layout == layoutCode {
+					writeCodeFence(block, pq.lang)
+					break
+				}
+				write("\n" + block + "\n")
+			case fmtBlockQuote:
+				write("\n> ")
+				write(strings.Replace(block, "\n", "\n> ", -1) + "\n")
+			default:
+				write("\n" + block + "\n")
 			}
-			write("\n**Code**:")
-			writeCodeFence(exp.Parts[0], pq.lang)
-			write("**Output**:")
-			writeCodeFence(exp.Parts[1], "")
-		case fmtCodeFence:
-			writeCodeFence(exp.String, pq.lang)
-		case fmtBlockQuote:
-			write("\n> ")
-			write(strings.Replace(exp.String, "\n", "\n> ", -1) + "\n")
-		default:
-			write("\n" + exp.String + "\n")
+		}
+		if exp.Link != "" {
+			write("\n" + exp.Link + "\n")
 		}
 
 		if pq.endIdx == idxNoEnd { // add an end tag
@@ -625,6 +662,49 @@ func applyPullQuotes(pqs []*pullQuote, expanded []*expanded, r readerAtSeeker, w
 
 const idxNoEnd = -1
 
+// quoteTags maps the tags which begin pullquotes to their quote types; each is closed by the same tag prefixed with
+// a slash
+var quoteTags = map[string]string{
+	"pullquote":  "pull",
+	"goquote":    "go",
+	"jsonquote":  "json",
+	"yamlquote":  "yaml",
+	"tomlquote":  "toml",
+	"csvquote":   "csv",
+	"protoquote": "proto",
+	"sqlquote":   "sql",
+	"pyquote":    "py",
+	"codequote":  "code",
+	"makequote":  "make",
+	"mdquote":    "md",
+}
+
+// quoteType describes a quote type other than pullquote's own.
+type quoteType struct {
+	// pathKey sets what to quote; it's the unnamed first argument of the quote type's own tag
+	pathKey string
+	// expand renders every quote of the type at once
+	expand func(context.Context, []*pullQuote) ([]*expanded, error)
+	// wdRelative keeps paths relative to the working directory, as goquote and jsonquote always have, unless they look
+	// like files -- they start with `./` or mention `.go`; otherwise, paths are relative to the Markdown file
+	wdRelative bool
+}
+
+// quoteTypes are the quote types other than pullquote's own, by quote type
+var quoteTypes = map[string]quoteType{
+	"go":    {pathKey: keyGoPath, expand: expandGoQuotes, wdRelative: true},
+	"json":  {pathKey: keyJSONPath, expand: expandJSONQuotes, wdRelative: true},
+	"yaml":  {pathKey: keyYAMLPath, expand: expandYAMLQuotes},
+	"toml":  {pathKey: keyTOMLPath, expand: expandTOMLQuotes},
+	"csv":   {pathKey: keyCSVPath, expand: expandCSVQuotes},
+	"proto": {pathKey: keyProtoPath, expand: expandProtoQuotes},
+	"sql":   {pathKey: keySQLPath, expand: expandSQLQuotes},
+	"py":    {pathKey: keyPyPath, expand: expandPyQuotes},
+	"code":  {pathKey: keyCodePath, expand: expandCodeQuotes},
+	"make":  {pathKey: keyMakePath, expand: expandMakeQuotes},
+	"md":    {pathKey: keyMDPath, expand: expandMDQuotes},
+}
+
 func readPullQuotes(ctx context.Context, r io.Reader) ([]*pullQuote, error) {
 	var pqs []*pullQuote
 
@@ -637,15 +717,11 @@ func readPullQuotes(ctx context.Context, r io.Reader) ([]*pullQuote, error) {
 		toks := tokenizingScanner(bytes.NewReader(b[len("<!--") : len(b)-len("-->")]))
 		toks.Scan()
 
-		var tt string
-		switch t := toks.Text(); t {
-		case "pullquote":
-			tt = "pull"
-		case "goquote":
-			tt = "go"
-		case "jsonquote":
-			tt = "json"
-		case "/pullquote", "/goquote", "/jsonquote":
+		t := toks.Text()
+		tt, ok := quoteTags[t]
+		switch {
+		case ok:
+		case strings.HasPrefix(t, "/") && quoteTags[t[1:]] != "":
 			if l := len(pqs) - 1; l >= 0 && pqs[l].endIdx == idxNoEnd && strings.HasPrefix(t, "/"+pqs[l].originalTag) {
 				pqs[l].endIdx = comments.start
 				if debug {
@@ -683,43 +759,44 @@ func readPullQuotes(ctx context.Context, r io.Reader) ([]*pullQuote, error) {
 type expanded struct {
 	String string
 	Parts  []string
+
+	// Blocks, when set, are rendered separately in place of String
+	Blocks []string
+
+	// Pos and End delimit the source of a goquote'd node, when there is one
+	Pos, End token.Pos
+	// Link is a markdown link back to the source, rendered after the quote
+	Link string
 }
 
-// doing it w/o hash maps for s&gs
+// expandPullQuotes expands the quotes of each quote type together, then pullquotes by source file
 func expandPullQuotes(ctx context.Context, pqs []*pullQuote) ([]*expanded, error) {
 	results := make([]*expanded, len(pqs))
 
 	var buf []*pullQuote
 
-	for _, strategy := range []struct {
-		quoteType string
-		expander  func(context.Context, []*pullQuote) ([]*expanded, error)
-	}{
-		{"go", expandGoQuotes},
-		{"json", expandJSONQuotes},
-	} {
-		for i, pq := range pqs {
-			if results[i] != nil {
-				continue
-			}
-			if pq.quoteType == strategy.quoteType {
-				buf = append(buf, pq)
+	for i, pq := range pqs {
+		qt, ok := quoteTypes[pq.quoteType]
+		if results[i] != nil || !ok {
+			continue
+		}
+		for _, other := range pqs[i:] {
+			if other.quoteType == pq.quoteType {
+				buf = append(buf, other)
 			}
 		}
 
-		if len(buf) > 0 {
-			expanded, err := strategy.expander(ctx, buf)
-			if err != nil {
-				return nil, err
-			}
-			for j, cur := 0, 0; j < len(pqs) && cur < len(buf); j++ {
-				if pqs[j] == buf[cur] {
-					results[j] = expanded[cur]
-					cur++
-				}
+		expanded, err := qt.expand(ctx, buf)
+		if err != nil {
+			return nil, err
+		}
+		for j, cur := i, 0; j < len(pqs) && cur < len(buf); j++ {
+			if pqs[j] == buf[cur] {
+				results[j] = expanded[cur]
+				cur++
 			}
-			buf = buf[:0]
 		}
+		buf = buf[:0]
 	}
 
 	for i, pq := range pqs {
@@ -827,11 +904,107 @@ const (
 
 	// keyGoPath sets the path to a go expression or statement to print; can also be specified via goquote tag
 	keyGoPath = "gopath"
-	// keyIncludeGroup includes the whole group declaration, not just the single named statement
+	// keyIncludeGroup includes the whole group declaration, not just the single named statement; for protoquote, the
+	// enclosing message or service
 	keyIncludeGroup = "includegroup"
+	// keyLines selects lines, like `3-10`, `3-` or `-10`, counted from the first line of the goquote's snippet
+	keyLines = "lines"
+	// keyFrom specifies a pattern for the first line within the goquote's snippet to include
+	keyFrom = "from"
+	// keyTo specifies a pattern for the last line within the goquote's snippet to include
+	keyTo = "to"
+	// keyNoPackage omits the package clause (and anything above it) when quoting whole go files or packages
+	keyNoPackage = "nopackage"
+	// keyNoImports omits import declarations when quoting whole go files or packages
+	keyNoImports = "noimports"
+	// keyDeclsOnly quotes only the top-level declarations of whole go files or packages
+	keyDeclsOnly = "declsonly"
+	// keyWithImports prepends an import block for the packages a goquote'd symbol references
+	keyWithImports = "withimports"
+	// keyTypeCheck fails the run if a fmt=playground program doesn't type check
+	keyTypeCheck = "typecheck"
+	// keyLink adds a link to the goquote'd symbol's source -- can be `pkgsite` or `blob`
+	keyLink = "link"
+	// keyLinkTemplate sets the URL for link=blob, with `{commit}`, `{path}`, `{start}`, and `{end}` placeholders
+	keyLinkTemplate = "linktemplate"
+	// keyLinkVersion sets the module version for link=pkgsite; defaults to the module cache version or a tag at HEAD
+	keyLinkVersion = "linkversion"
 
 	// keyJSONPath sets the path to a JSON object to print; can also be specified via jsonquote tag
 	keyJSONPath = "jsonpath"
+	// keyYAMLPath sets the path to a YAML node to print; can also be specified via yamlquote tag
+	keyYAMLPath = "yamlpath"
+	// keyTOMLPath sets the path to a TOML table or key to print; can also be specified via tomlquote tag
+	keyTOMLPath = "tomlpath"
+	// keyCSVPath sets the path to a CSV or TSV file to render as a table; can also be specified via csvquote tag
+	keyCSVPath = "csvpath"
+	// keyProtoPath sets the path to a .proto file and, optionally, a message, enum, service, or rpc within it to print;
+	// can also be specified via protoquote tag
+	keyProtoPath = "protopath"
+	// keySQLPath sets the path to a .sql file and, optionally, a statement named like `-- name: GetUser :one` or a
+	// migration section, `Up` or `Down`, to print; can also be specified via sqlquote tag
+	keySQLPath = "sqlpath"
+	// keyPyPath sets the path to a Python file and, optionally, a def or class within it, like `Client.connect`, to print;
+	// can also be specified via pyquote tag
+	keyPyPath = "pypath"
+	// keyCodePath sets the path to a JavaScript, TypeScript, Java, Kotlin, Scala, Swift, C, C++, C#, or Rust file and,
+	// optionally, a function, class, interface, or struct within it, like `Client.connect`, to print; the language comes
+	// from the file's extension unless set with lang; can also be specified via codequote tag
+	keyCodePath = "codepath"
+	// keyMakePath sets the path to a Makefile or shell script and, optionally, a target or function within it to print;
+	// can also be specified via makequote tag
+	keyMakePath = "makepath"
+	// keyMDPath sets the path to a Markdown file and, optionally, the anchor of a heading, like `quick-start`, whose
+	// section to print; can also be specified via mdquote tag
+	keyMDPath = "mdpath"
+	// keyNoHeading omits a quoted Markdown section's heading
+	keyNoHeading = "noheading"
+	// keyShift shifts the levels of quoted Markdown headings, e.g. shift=1 makes `##` into `###`
+	keyShift = "shift"
+	// keySignature prints only the decorators and header of a Python def or class
+	keySignature = "signature"
+
+	// keyQuery selects values with a JSONPath-style query, like `$.plugins[?(@.enabled)].name`
+	keyQuery = "query"
+	// keySplit renders each of a query's results separately, rather than as an array
+	keySplit = "split"
+	// keyIndent sets the indentation of JSON output -- a number of spaces, or `tab`; defaults to two spaces
+	keyIndent = "indent"
+	// keyCompact renders JSON output without any whitespace
+	keyCompact = "compact"
+	// keySortKeys sorts the keys of JSON objects
+	keySortKeys = "sortkeys"
+	// keyDepth collapses JSON nested deeper than the given number of levels to `{...}` or `[...]`
+	keyDepth = "depth"
+	// keyWhere selects records from a stream of JSON values, like NDJSON, or rows from a CSV file with a filter like
+	// `@.level == 'error'`
+	keyWhere = "where"
+	// keyRecords renders a range of records from a stream of JSON values as NDJSON, like a slice: `:5` for the first five
+	keyRecords = "records"
+	// keyDialect reads JSON as `json`, `jsonc`, `json5`, or `ndjson`; defaults by file extension. JSONC and JSON5 are
+	// quoted as written, comments and all, and a leading number in an NDJSON fragment selects a record.
+	keyDialect = "dialect"
+	// keyColumns chooses and orders the columns of fmt=table with a comma-separated list of keys; defaults to every key
+	keyColumns = "columns"
+	// keySortBy sorts the rows of fmt=table by a column; a leading `-` sorts descending
+	keySortBy = "sortby"
+	// keyAlignNumbers right-aligns the columns of fmt=table which hold only numbers
+	keyAlignNumbers = "alignnumbers"
+
+	// keyDelimiter sets the field delimiter of a CSV file -- a single character, or `tab`; defaults to a tab for .tsv
+	// files and a comma otherwise
+	keyDelimiter = "delimiter"
+	// keyNoHeader reads the first row of a CSV file as data; columns are then named by number, from 1
+	keyNoHeader = "noheader"
+	// keyRows renders a range of the rows of a CSV file, like a slice: `:5` for the first five
+	keyRows = "rows"
+
+	// keyRedact replaces matches of a regular expression -- or, if it has groups, the groups -- with a placeholder; rules
+	// in .pullquote.json apply to every quote
+	keyRedact = "redact"
+	// keyRedactKeys replaces the values of JSON, YAML, or TOML keys matching any of a comma-separated list of patterns,
+	// like `*password*,token`, with a placeholder
+	keyRedactKeys = "redactkeys"
 
 	// keySrc specifies the file from which to take a pullquote
 	keySrc = "src"
@@ -846,6 +1019,8 @@ const (
 	keyFmt = "fmt"
 	// keyLang specifies the language highlighting to be used with a codefence.
 	keyLang = "lang"
+	// keyLayout specifies how a method set is laid out -- can be `code`, `list`, or `table`; defaults to code.
+	keyLayout = "layout"
 
 	// fmtCodeFence specifies that the snippet should be rendered within a "codefence" -- i.e. ```
 	fmtCodeFence = "codefence"
@@ -855,22 +1030,197 @@ const (
 	fmtNone = "none"
 	// fmtExample indicates that the code should be rendered like a godoc example
 	fmtExample = "example"
+	// fmtMethodSet renders the method sets of a go type and its pointer, rather than its declaration
+	fmtMethodSet = "methodset"
+	// fmtPlayground renders go declarations, separated by commas, as a runnable program along with their dependencies
+	fmtPlayground = "playground"
+	// fmtSchema renders the properties of a JSON Schema as a markdown table
+	fmtSchema = "schema"
+	// fmtTable renders an array of objects as a markdown table
+	fmtTable = "table"
+
+	// layoutCode lays out a method set as go signatures in a codefence
+	layoutCode = "code"
+	// layoutList lays out a method set as a markdown list
+	layoutList = "list"
+	// layoutTable lays out a method set as a markdown table
+	layoutTable = "table"
 )
 
 var (
-	keysCommonOptional    = [...]string{keyFmt, keyLang}
-	keysGoQuoteValid      = [...]string{keyGoPath, keyNoReformat, keyIncludeGroup}
-	keysJSONQuoteValid    = [...]string{keyJSONPath, keyNoReformat}
+	keysCommonOptional = [...]string{keyFmt, keyLang, keyRedact}
+	keysGoQuoteValid   = [...]string{
+		keyGoPath,
+		keyNoReformat,
+		keyIncludeGroup,
+		keyLines,
+		keyFrom,
+		keyTo,
+		keyNoPackage,
+		keyNoImports,
+		keyDeclsOnly,
+		keyLayout,
+		keyWithImports,
+		keyTypeCheck,
+		keyLink,
+		keyLinkTemplate,
+		keyLinkVersion,
+	}
+	keysJSONQuoteValid = [...]string{
+		keyJSONPath,
+		keyNoReformat,
+		keyQuery,
+		keySplit,
+		keyIndent,
+		keyCompact,
+		keySortKeys,
+		keyDepth,
+		keyDialect,
+		keyWhere,
+		keyRecords,
+		keyColumns,
+		keySortBy,
+		keyAlignNumbers,
+		keyRedactKeys,
+	}
+	keysYAMLQuoteValid  = [...]string{keyYAMLPath, keyColumns, keySortBy, keyAlignNumbers, keyRedactKeys}
+	keysTOMLQuoteValid  = [...]string{keyTOMLPath, keyColumns, keySortBy, keyAlignNumbers, keyRedactKeys}
+	keysProtoQuoteValid = [...]string{keyProtoPath, keyIncludeGroup, keyLines, keyFrom, keyTo}
+	keysSQLQuoteValid   = [...]string{keySQLPath, keyLines, keyFrom, keyTo}
+	keysPyQuoteValid    = [...]string{keyPyPath, keySignature, keyLines, keyFrom, keyTo}
+	keysCodeQuoteValid  = [...]string{keyCodePath, keyLines, keyFrom, keyTo}
+	keysMakeQuoteValid  = [...]string{keyMakePath, keyLines, keyFrom, keyTo}
+	keysMDQuoteValid    = [...]string{keyMDPath, keyNoHeading, keyShift, keyLines, keyFrom, keyTo}
+	keysCSVQuoteValid   = [...]string{
+		keyCSVPath,
+		keyDelimiter,
+		keyNoHeader,
+		keyWhere,
+		keyRows,
+		keyColumns,
+		keySortBy,
+		keyAlignNumbers,
+		keyRedactKeys,
+	}
+	// keysStructuredQuoteValid are the keys for quote types which select from data files, by quote type
+	keysStructuredQuoteValid = map[string][]string{
+		"json": keysJSONQuoteValid[:],
+		"yaml": keysYAMLQuoteValid[:],
+		"toml": keysTOMLQuoteValid[:],
+		"csv":  keysCSVQuoteValid[:],
+	}
 	keysPullQuoteOptional = [...]string{keyEndCount}
 	keysPullQuoteRequired = [...]string{keySrc, keyStart, keyEnd}
 	validFmts             = map[string]bool{
 		fmtBlockQuote: true,
 		fmtCodeFence:  true,
 		fmtExample:    true,
+		fmtMethodSet:  true,
 		fmtNone:       true,
+		fmtPlayground: true,
+		fmtSchema:     true,
+		fmtTable:      true,
 	}
 )
 
+// sourceQuote describes how to validate a quote type which selects from a source file by the name after its '#'.
+type sourceQuote struct {
+	keys []string
+	// fragment describes what follows the '#', for errors
+	fragment string
+	// lang is the codefence's language for a file, unless one is given
+	lang func(pat string) string
+	// fmts are the formats allowed, the first being the default
+	fmts []string
+	// check validates anything particular to the quote type
+	check func(pq *pullQuote, pat string, hasFrag bool) error
+}
+
+var (
+	// sourceQuoteTypes are the quote types which select a declaration or section from a source file, by quote type
+	sourceQuoteTypes = map[string]sourceQuote{
+		"proto": {
+			keys:     keysProtoQuoteValid[:],
+			fragment: "a symbol",
+			lang:     fixedLang("proto"),
+			fmts:     sourceFmts,
+			check: func(pq *pullQuote, _ string, hasSym bool) error {
+				if !hasSym && pq.flags&includeGroup != 0 {
+					return errors.New("includegroup requires a symbol")
+				}
+				return nil
+			},
+		},
+		"sql": {
+			keys:     keysSQLQuoteValid[:],
+			fragment: "a statement name",
+			lang:     fixedLang("sql"),
+			fmts:     sourceFmts,
+		},
+		"py": {
+			keys:     keysPyQuoteValid[:],
+			fragment: "a symbol",
+			lang:     fixedLang("python"),
+			fmts:     sourceFmts,
+			check: func(pq *pullQuote, _ string, hasSym bool) error {
+				if !hasSym && pq.flags&signatureOnly != 0 {
+					return errors.New("signature requires a symbol")
+				}
+				return nil
+			},
+		},
+		"code": {
+			keys:     keysCodeQuoteValid[:],
+			fragment: "a symbol",
+			lang:     braceLangForPath,
+			fmts:     sourceFmts,
+			check: func(pq *pullQuote, pat string, _ bool) error {
+				switch {
+				case pq.lang == "" && braceLangForPath(pat) == "":
+					return fmt.Errorf("can't tell the language of %v from its extension; set lang", pat)
+				case pq.lang != "" && braceLangs[strings.ToLower(pq.lang)] == nil:
+					return fmt.Errorf("unsupported lang %q; must be one of %v", pq.lang, braceLangNames())
+				case pq.lang == "": // the declaration is found by language, whatever the fmt
+					pq.lang = braceLangForPath(pat)
+				}
+				return nil
+			},
+		},
+		"make": {
+			keys:     keysMakeQuoteValid[:],
+			fragment: "a target or function name",
+			lang:     makeKindForPath,
+			fmts:     sourceFmts,
+			check: func(_ *pullQuote, pat string, _ bool) error {
+				if makeKindForPath(pat) == "" {
+					return fmt.Errorf("%v is neither a Makefile nor a shell script", pat)
+				}
+				return nil
+			},
+		},
+		"md": {
+			keys:     keysMDQuoteValid[:],
+			fragment: "a heading's anchor",
+			lang:     fixedLang("markdown"),
+			fmts:     []string{fmtNone, fmtBlockQuote, fmtCodeFence},
+			check: func(pq *pullQuote, _ string, hasAnchor bool) error {
+				if !hasAnchor && pq.flags&omitHeading != 0 {
+					return errors.New("noheading requires a heading's anchor")
+				}
+				return nil
+			},
+		},
+	}
+	// sourceFmts are the formats for quoted source, the first being the default
+	sourceFmts = []string{fmtCodeFence, fmtBlockQuote, fmtNone}
+)
+
+func fixedLang(lang string) func(string) string {
+	return func(string) string {
+		return lang
+	}
+}
+
 type pullQuote struct {
 	originalTag, quoteType string
 
@@ -878,8 +1228,27 @@ type pullQuote struct {
 	start, end *regexp.Regexp
 	endCount   int
 	fmt, lang  string
+	layout     string
+
+	link, linkTemplate, linkVersion string
+
+	redact     *regexp.Regexp
+	redactKeys []string
+
+	objPath string
+	query   *jsonQuery
+	jsonFmt jsonFormat
+	dialect string
+	where   *jsonFilter
+	records *recordRange
+
+	tableFmt  tableFormat
+	delimiter rune
+	rows      *recordRange
+
+	headingShift int
 
-	objPath, jsonPath string
+	sub lineRange
 
 	flags uint
 
@@ -891,18 +1260,11 @@ func (pq *pullQuote) String() string {
 	var b strings.Builder
 	_, _ = fmt.Fprintf(&b, "<!-- %vquote", pq.originalTag)
 
-	switch pq.quoteType {
-	case "go":
-		if pq.originalTag == "go" {
+	if qt, ok := quoteTypes[pq.quoteType]; ok {
+		if pq.originalTag == pq.quoteType {
 			_, _ = fmt.Fprintf(&b, " %q", pq.objPath)
 		} else {
-			_, _ = fmt.Fprintf(&b, " gopath=%q", pq.objPath)
-		}
-	case "json":
-		if pq.originalTag == "json" {
-			_, _ = fmt.Fprintf(&b, " %q", pq.jsonPath)
-		} else {
-			_, _ = fmt.Fprintf(&b, " jsonpath=%q", pq.jsonPath)
+			_, _ = fmt.Fprintf(&b, " %v=%q", qt.pathKey, pq.objPath)
 		}
 	}
 
@@ -918,8 +1280,40 @@ func (pq *pullQuote) String() string {
 		{keyEndCount, pq.endCount},
 		{keyFmt, pq.fmt},
 		{keyLang, pq.lang},
+		{keyLayout, pq.layout},
+		{keyLink, pq.link},
+		{keyLinkTemplate, pq.linkTemplate},
+		{keyLinkVersion, pq.linkVersion},
+		{keyQuery, pq.query},
+		{keySplit, pq.flags&splitResults != 0},
+		{keyIndent, pq.jsonFmt.indent},
+		{keyCompact, pq.jsonFmt.compact},
+		{keySortKeys, pq.jsonFmt.sortKeys},
+		{keyDepth, pq.jsonFmt.depth},
+		{keyDialect, pq.dialect},
+		{keyWhere, pq.where},
+		{keyRecords, pq.records},
+		{keyColumns, strings.Join(pq.tableFmt.columns, ",")},
+		{keySortBy, pq.tableFmt.sortBy},
+		{keyAlignNumbers, pq.tableFmt.alignNumbers},
+		{keyDelimiter, delimiterString(pq.delimiter)},
+		{keyNoHeader, pq.flags&noHeader != 0},
+		{keyRows, pq.rows},
+		{keyRedact, pq.redact},
+		{keyRedactKeys, strings.Join(pq.redactKeys, ",")},
+		{keyLines, pq.sub.linesString()},
+		{keyFrom, pq.sub.from},
+		{keyTo, pq.sub.to},
 		{keyIncludeGroup, pq.flags&includeGroup != 0},
 		{keyNoReformat, pq.flags&noRealignTabs != 0},
+		{keyNoPackage, pq.flags&omitPackage != 0},
+		{keyNoImports, pq.flags&omitImports != 0},
+		{keyDeclsOnly, pq.flags&declsOnly != 0},
+		{keyWithImports, pq.flags&withImports != 0},
+		{keyTypeCheck, pq.flags&typeCheck != 0},
+		{keySignature, pq.flags&signatureOnly != 0},
+		{keyNoHeading, pq.flags&omitHeading != 0},
+		{keyShift, pq.headingShift},
 	} {
 		switch v := t.val.(type) {
 		case bool:
@@ -939,6 +1333,18 @@ func (pq *pullQuote) String() string {
 			if v != nil {
 				_, _ = fmt.Fprintf(&b, " %v=%q", t.key, v)
 			}
+		case *jsonQuery:
+			if v != nil {
+				_, _ = fmt.Fprintf(&b, " %v=%q", t.key, v)
+			}
+		case *jsonFilter:
+			if v != nil {
+				_, _ = fmt.Fprintf(&b, " %v=%q", t.key, v)
+			}
+		case *recordRange:
+			if v != nil {
+				_, _ = fmt.Fprintf(&b, " %v=%q", t.key, v)
+			}
 		default:
 			_, _ = fmt.Fprintf(&b, " %v=UNKNOWN(%v)", t.key, v)
 		}
@@ -953,8 +1359,26 @@ const (
 	_ = 1 << iota
 	noRealignTabs
 	includeGroup
+	omitPackage
+	omitImports
+	declsOnly
+	withImports
+	typeCheck
+	splitResults
+	noHeader
+	signatureOnly
+	omitHeading
 )
 
+// splitObjPath splits an object path like `./foo.go#Bar` into its file or package and its fragment, if any.
+func splitObjPath(objPath string) (pat, frag string, hasFrag bool) {
+	parts := strings.SplitN(objPath, "#", 2)
+	if len(parts) == 1 {
+		return parts[0], "", false
+	}
+	return parts[0], parts[1], true
+}
+
 type scanner interface {
 	Scan() bool
 	Text() string
@@ -967,11 +1391,8 @@ func setOptions(pq *pullQuote, toks scanner, tagType string) (map[string]struct{
 	// our expressions require maximum three "tokens"
 	window := make([]string, 0, 3)
 
-	switch tagType {
-	case "go":
-		window = append(window, keyGoPath, "=")
-	case "json":
-		window = append(window, keyJSONPath, "=")
+	if qt, ok := quoteTypes[tagType]; ok { // the tag's first argument is its path
+		window = append(window, qt.pathKey, "=")
 	}
 
 	for toks.Scan() && b.err == nil {
@@ -1003,38 +1424,195 @@ func setOptions(pq *pullQuote, toks scanner, tagType string) (map[string]struct{
 
 func validate(pq *pullQuote, seen map[string]struct{}) error {
 	if pq.fmt != "" && !validFmts[pq.fmt] {
-		return errors.New("fmt must be example, codefence, blockquote, or none")
+		return errors.New("fmt must be example, methodset, playground, schema, table, codefence, blockquote, or none")
+	}
+	if (pq.fmt == fmtMethodSet || pq.fmt == fmtPlayground) && pq.quoteType != "go" {
+		return fmt.Errorf("fmt=%v is only supported by goquote", pq.fmt)
+	}
+	if pq.fmt == fmtSchema && pq.quoteType != "json" {
+		return errors.New("fmt=schema is only supported by jsonquote")
+	}
+	if _, ok := keysStructuredQuoteValid[pq.quoteType]; pq.fmt == fmtTable && !ok {
+		return errors.New("fmt=table is only supported by jsonquote, yamlquote, tomlquote, and csvquote")
+	}
+	if pq.quoteType == "csv" && pq.fmt != "" && pq.fmt != fmtTable {
+		return errors.New("csvquote: fmt must be table")
+	}
+	if pq.fmt != fmtTable && pq.quoteType != "csv" &&
+		(pq.tableFmt.columns != nil || pq.tableFmt.sortBy != "" || pq.tableFmt.alignNumbers) {
+		return errors.New("columns, sortby, and alignnumbers only apply to fmt=table")
+	}
+	if _, ok := seen[keyLayout]; ok && pq.fmt != fmtMethodSet {
+		return errors.New("layout only applies to fmt=methodset")
 	}
 
 	for _, s := range keysCommonOptional {
 		delete(seen, s)
 	}
 
-	if pq.quoteType == "json" {
+	if keys, ok := keysStructuredQuoteValid[pq.quoteType]; ok {
+		pat, frag, hasFrag := splitObjPath(pq.objPath)
+		if pat == "" {
+			return fmt.Errorf("%vquote: a file is required", pq.quoteType)
+		}
+		if pq.quoteType == "json" && pq.dialect == "" {
+			pq.dialect = dialectForPath(pat)
+		}
+		if pq.quoteType == "csv" {
+			if hasFrag {
+				return errors.New("csvquote: a fragment isn't supported; select rows with where or rows and columns with columns")
+			}
+			if pq.delimiter == 0 {
+				pq.delimiter = delimiterForPath(pat)
+			}
+			pq.fmt = fmtTable
+		}
 		if pq.fmt == "" {
 			pq.fmt = fmtCodeFence
-			pq.lang = "json"
+			pq.lang = pq.quoteType
+			if pq.dialect != "" && pq.dialect != dialectNDJSON { // a record's just JSON
+				pq.lang = pq.dialect
+			}
 		}
 
-		for _, s := range keysJSONQuoteValid {
+		if pq.flags&splitResults != 0 && pq.query == nil {
+			return fmt.Errorf("%vquote: split requires a query", pq.quoteType)
+		}
+		if pq.jsonFmt.raw = pq.flags&noRealignTabs != 0; pq.jsonFmt.raw &&
+			(pq.jsonFmt.indent != "" || pq.jsonFmt.compact || pq.jsonFmt.sortKeys || pq.jsonFmt.depth > 0) {
+			return fmt.Errorf("%vquote: noreformat can't be combined with indent, compact, sortkeys, or depth", pq.quoteType)
+		}
+		if pq.jsonFmt.compact && pq.jsonFmt.indent != "" {
+			return fmt.Errorf("%vquote: compact can't be combined with indent", pq.quoteType)
+		}
+		if pq.fmt == fmtSchema && (pq.query != nil || pq.jsonFmt != (jsonFormat{})) {
+			return fmt.Errorf("%vquote: fmt=schema can't be combined with query or formatting options", pq.quoteType)
+		}
+		if pq.fmt == fmtTable && (pq.flags&splitResults != 0 || pq.jsonFmt != (jsonFormat{})) {
+			return fmt.Errorf("%vquote: fmt=table can't be combined with split or formatting options", pq.quoteType)
+		}
+		if pq.records != nil {
+			if pq.query != nil || pq.fmt == fmtSchema || pq.jsonFmt != (jsonFormat{}) {
+				return fmt.Errorf("%vquote: records renders NDJSON and can't be combined with query, fmt=schema, or formatting options", pq.quoteType)
+			}
+			if pq.dialect == dialectNDJSON && frag != "" && frag[0] != '/' {
+				return fmt.Errorf("%vquote: records can't be combined with a record index", pq.quoteType)
+			}
+		}
+		if pq.dialect == dialectJSONC || pq.dialect == dialectJSON5 {
+			if pq.query != nil || pq.where != nil || pq.records != nil || pq.fmt == fmtSchema || pq.fmt == fmtTable ||
+				pq.jsonFmt != (jsonFormat{raw: pq.jsonFmt.raw}) {
+				return fmt.Errorf("%vquote: dialect=%v is quoted as written and can't be combined with query, where, records, fmt=schema, fmt=table, or formatting options", pq.quoteType, pq.dialect)
+			}
+		}
+
+		for _, s := range keys {
 			delete(seen, s)
 		}
 
-		delete(seen, keyJSONPath)
 		if err := checkRemaining(seen); err != nil {
-			return fmt.Errorf("jsonquote: %w", err)
+			return fmt.Errorf("%vquote: %w", pq.quoteType, err)
+		}
+		return nil
+	}
+
+	if sq, ok := sourceQuoteTypes[pq.quoteType]; ok {
+		pat, frag, hasFrag := splitObjPath(pq.objPath)
+		switch {
+		case pat == "":
+			return fmt.Errorf("%vquote: a file is required", pq.quoteType)
+		case hasFrag && frag == "":
+			return fmt.Errorf("%vquote: %v is required after '#'", pq.quoteType, sq.fragment)
+		}
+		if sq.check != nil {
+			if err := sq.check(pq, pat, hasFrag); err != nil {
+				return fmt.Errorf("%vquote: %w", pq.quoteType, err)
+			}
+		}
+
+		if pq.fmt == "" {
+			pq.fmt = sq.fmts[0]
+		}
+		var allowed bool
+		for _, f := range sq.fmts {
+			allowed = allowed || f == pq.fmt
+		}
+		if !allowed {
+			return fmt.Errorf(
+				"%vquote: fmt must be %v, or %v",
+				pq.quoteType,
+				strings.Join(sq.fmts[:len(sq.fmts)-1], ", "),
+				sq.fmts[len(sq.fmts)-1],
+			)
+		}
+		if pq.fmt == fmtCodeFence && pq.lang == "" {
+			pq.lang = sq.lang(pat)
+		}
+
+		for _, s := range sq.keys {
+			delete(seen, s)
+		}
+
+		if err := checkRemaining(seen); err != nil {
+			return fmt.Errorf("%vquote: %w", pq.quoteType, err)
 		}
 		return nil
 	}
 
 	if pq.quoteType == "go" {
+		switch pat, sym, hasSym := splitObjPath(pq.objPath); {
+		case pat == "":
+			return errors.New("goquote: a file or package is required")
+		case hasSym && sym == "":
+			return errors.New("goquote: a symbol is required after '#'")
+		case hasSym && pq.flags&(omitPackage|omitImports|declsOnly) != 0:
+			return errors.New("goquote: nopackage, noimports, and declsonly only apply to whole files or packages")
+		case !hasSym && pq.flags&includeGroup != 0:
+			return errors.New("goquote: includegroup requires a symbol")
+		case !hasSym && pq.fmt == fmtMethodSet:
+			return errors.New("goquote: fmt=methodset requires a type name")
+		case !hasSym && pq.flags&withImports != 0:
+			return errors.New("goquote: withimports requires a symbol")
+		case !hasSym && pq.fmt == fmtPlayground:
+			return errors.New("goquote: fmt=playground requires a symbol")
+		case pq.flags&typeCheck != 0 && pq.fmt != fmtPlayground:
+			return errors.New("goquote: typecheck only applies to fmt=playground")
+		case pq.link != "" && !hasSym:
+			return errors.New("goquote: link requires a symbol")
+		case pq.link != "" && (pq.fmt == fmtMethodSet || pq.fmt == fmtPlayground):
+			return fmt.Errorf("goquote: link cannot be used with fmt=%v", pq.fmt)
+		case pq.link != "" && pq.link != linkPkgSite && pq.link != linkBlob:
+			return errors.New("goquote: link must be pkgsite or blob")
+		case pq.linkTemplate != "" && pq.link != linkBlob:
+			return errors.New("goquote: linktemplate only applies to link=blob")
+		case pq.linkVersion != "" && pq.link != linkPkgSite:
+			return errors.New("goquote: linkversion only applies to link=pkgsite")
+		}
+		if pq.fmt == fmtPlayground && pq.lang == "" {
+			pq.lang = "go"
+		}
+		if pq.fmt == fmtMethodSet {
+			switch pq.layout {
+			case "":
+				pq.layout = layoutCode
+			case layoutCode, layoutList, layoutTable:
+			default:
+				return errors.New("goquote: layout must be code, list, or table")
+			}
+			if pq.layout == layoutCode && pq.lang == "" {
+				pq.lang = "go"
+			}
+		}
 		if pq.fmt == "" {
 			pq.fmt = fmtCodeFence
 			pq.lang = "go"
-			if strings.Contains(pq.objPath, "#Example") { // likely example test
+			if strings.Contains(pq.objPath, "#Example") && !pq.sub.isSet() { // likely example test
 				pq.fmt = fmtExample
 			}
 		}
+		if pq.fmt == fmtExample && pq.sub.isSet() {
+			return errors.New("goquote: lines, from, and to cannot be used with fmt=example")
+		}
 
 		for _, s := range keysGoQuoteValid {
 			delete(seen, s)
@@ -1109,6 +1687,119 @@ func (b *builder) set(k, v string, vSet bool) {
 	case keyNoReformat:
 		b.vSetTest(keyNoReformat, false, vSet)
 		b.pq.flags |= noRealignTabs
+	case keyNoPackage:
+		b.vSetTest(keyNoPackage, false, vSet)
+		b.pq.flags |= omitPackage
+	case keyNoImports:
+		b.vSetTest(keyNoImports, false, vSet)
+		b.pq.flags |= omitImports
+	case keyDeclsOnly:
+		b.vSetTest(keyDeclsOnly, false, vSet)
+		b.pq.flags |= declsOnly
+	case keyWithImports:
+		b.vSetTest(keyWithImports, false, vSet)
+		b.pq.flags |= withImports
+	case keyTypeCheck:
+		b.vSetTest(keyTypeCheck, false, vSet)
+		b.pq.flags |= typeCheck
+	case keySplit:
+		b.vSetTest(keySplit, false, vSet)
+		b.pq.flags |= splitResults
+	case keyCompact:
+		b.vSetTest(keyCompact, false, vSet)
+		b.pq.jsonFmt.compact = true
+	case keySortKeys:
+		b.vSetTest(keySortKeys, false, vSet)
+		b.pq.jsonFmt.sortKeys = true
+	case keyIndent:
+		if b.vSetTest(keyIndent, true, vSet) {
+			if v == "tab" {
+				b.pq.jsonFmt.indent = "\t"
+				break
+			}
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > 8 {
+				b.err = fmt.Errorf("invalid indent %q: must be tab or 1 to 8 spaces", v)
+				break
+			}
+			b.pq.jsonFmt.indent = strings.Repeat(" ", n)
+		}
+	case keyDepth:
+		if b.vSetTest(keyDepth, true, vSet) {
+			if b.pq.jsonFmt.depth, b.err = strconv.Atoi(v); b.err != nil || b.pq.jsonFmt.depth < 1 {
+				b.err = fmt.Errorf("invalid depth %q: must be a positive integer", v)
+			}
+		}
+	case keyDialect:
+		if b.vSetTest(keyDialect, true, vSet) {
+			switch v {
+			case dialectJSON, dialectJSONC, dialectJSON5, dialectNDJSON:
+				b.pq.dialect = v
+			default:
+				b.err = fmt.Errorf("invalid dialect %q: must be json, jsonc, json5, or ndjson", v)
+			}
+		}
+	case keyRedact:
+		if b.vSetTest(keyRedact, true, vSet) {
+			if b.pq.redact, b.err = regexp.Compile(v); b.err != nil {
+				b.err = fmt.Errorf("invalid redact %q: %w", v, b.err)
+			}
+		}
+	case keyRedactKeys:
+		if b.vSetTest(keyRedactKeys, true, vSet) {
+			for _, k := range strings.Split(v, ",") {
+				if k = strings.TrimSpace(k); k == "" {
+					continue
+				}
+				if b.err = validKeyPattern(k); b.err != nil {
+					break
+				}
+				b.pq.redactKeys = append(b.pq.redactKeys, strings.ToLower(k))
+			}
+		}
+	case keyColumns:
+		if b.vSetTest(keyColumns, true, vSet) {
+			for _, c := range strings.Split(v, ",") {
+				if c = strings.TrimSpace(c); c != "" {
+					b.pq.tableFmt.columns = append(b.pq.tableFmt.columns, c)
+				}
+			}
+			if b.pq.tableFmt.columns == nil {
+				b.err = fmt.Errorf("invalid columns %q: must name at least one column", v)
+			}
+		}
+	case keyAlignNumbers:
+		b.vSetTest(keyAlignNumbers, false, vSet)
+		b.pq.tableFmt.alignNumbers = true
+	case keyDelimiter:
+		if b.vSetTest(keyDelimiter, true, vSet) {
+			b.pq.delimiter, b.err = parseDelimiter(v)
+		}
+	case keyNoHeader:
+		b.vSetTest(keyNoHeader, false, vSet)
+		b.pq.flags |= noHeader
+	case keyRows:
+		if b.vSetTest(keyRows, true, vSet) {
+			b.pq.rows, b.err = parseRecordRange(keyRows, v)
+		}
+	case keySortBy:
+		if b.vSetTest(keySortBy, true, vSet) {
+			if b.pq.tableFmt.sortBy = v; strings.TrimPrefix(v, "-") == "" {
+				b.err = fmt.Errorf("invalid sortby %q: must name a column", v)
+			}
+		}
+	case keyWhere:
+		if b.vSetTest(keyWhere, true, vSet) {
+			b.pq.where, b.err = parseJSONFilter(v)
+		}
+	case keyRecords:
+		if b.vSetTest(keyRecords, true, vSet) {
+			b.pq.records, b.err = parseRecordRange(keyRecords, v)
+		}
+	case keyQuery:
+		if b.vSetTest(keyQuery, true, vSet) {
+			b.pq.query, b.err = parseJSONQuery(v)
+		}
 	case keySrc:
 		b.vSetTest(keySrc, true, vSet)
 		b.pq.src = v
@@ -1130,17 +1821,67 @@ func (b *builder) set(k, v string, vSet bool) {
 				b.err = fmt.Errorf("invalid endcount %q: %w", v, b.err)
 			}
 		}
+	case keyLines:
+		if b.vSetTest(keyLines, true, vSet) {
+			if b.pq.sub.first, b.pq.sub.last, b.err = parseLines(v); b.err != nil {
+				b.err = fmt.Errorf("invalid lines %q: %w", v, b.err)
+			}
+		}
+	case keyFrom:
+		if b.vSetTest(keyFrom, true, vSet) {
+			if b.pq.sub.from, b.err = regexp.Compile(v); b.err != nil {
+				b.err = fmt.Errorf("invalid from %q: %w", v, b.err)
+			}
+		}
+	case keyTo:
+		if b.vSetTest(keyTo, true, vSet) {
+			if b.pq.sub.to, b.err = regexp.Compile(v); b.err != nil {
+				b.err = fmt.Errorf("invalid to %q: %w", v, b.err)
+			}
+		}
 	case keyFmt:
 		b.pq.fmt = v
 	case keyLang:
 		b.pq.lang = v
-	case keyGoPath:
-		b.pq.objPath = v
-		b.pq.quoteType = "go"
-	case keyJSONPath:
-		b.pq.objPath = v
-		b.pq.quoteType = "json"
+	case keyLayout:
+		if b.vSetTest(keyLayout, true, vSet) {
+			b.pq.layout = v
+		}
+	case keyLink:
+		if b.vSetTest(keyLink, true, vSet) {
+			b.pq.link = v
+		}
+	case keyLinkTemplate:
+		if b.vSetTest(keyLinkTemplate, true, vSet) {
+			b.pq.linkTemplate = v
+		}
+	case keyLinkVersion:
+		if b.vSetTest(keyLinkVersion, true, vSet) {
+			b.pq.linkVersion = v
+		}
+	case keyNoHeading:
+		b.vSetTest(keyNoHeading, false, vSet)
+		b.pq.flags |= omitHeading
+	case keyShift:
+		if b.vSetTest(keyShift, true, vSet) {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < -5 || n > 5 || n == 0 {
+				b.err = fmt.Errorf("invalid shift %q: must be a nonzero integer from -5 to 5", v)
+				break
+			}
+			b.pq.headingShift = n
+		}
+	case keySignature:
+		b.vSetTest(keySignature, false, vSet)
+		b.pq.flags |= signatureOnly
 	default:
+		for t, qt := range quoteTypes {
+			if qt.pathKey == k {
+				b.pq.objPath = v
+				b.pq.quoteType = t
+				return
+			}
+		}
 		if vSet {
 			b.err = fmt.Errorf("unknown key %q with value %q", k, v)
 			break
@@ -1318,7 +2059,19 @@ func htmlCommentScanner(r io.Reader) *trackingScanner {
 		}
 
 		if cfStart == -1 { // no codefence ahead but still couldn't find comment -- jump to end of data
-			return len(data), nil, nil
+			if atEOF {
+				return len(data), nil, nil
+			}
+			// ...unless a comment is opened but not yet closed, in which case the next read may close it
+			if open := bytes.LastIndex(data[i:], []byte("<!--")); open != -1 &&
+				!bytes.Contains(data[i+open:], []byte("-->")) {
+				return i + open, nil, nil
+			}
+			// the last few bytes may be the start of a `<!--` or "\n```" cut off by the end of this read, so keep them
+			if keep := len(data) - len("\n```") + 1; keep > i {
+				return keep, nil, nil
+			}
+			return i, nil, nil
 		}
 
 		// codefence start ahead with no comment intervening, but no end present -- jump to start of codefence
@@ -193,7 +193,7 @@ bye
 				writeFile(t, f[0], f[1])
 			}
 
-			s, err := processFile(context.Background(), d.tmpDir, c.input)
+			s, err := processFile(context.Background(), d.tmpDir, c.input, false)
 			var errS string
 			if err != nil {
 				errS = err.Error()
@@ -332,6 +332,602 @@ func Test_parseLine(t *testing.T) {
 			},
 			"",
 		},
+		{
+			"goquote lines",
+			`<!-- goquote .#ExampleFooBar lines=2-4 from="^\\tfoo" -->`,
+			&pullQuote{
+				quoteType:   "go",
+				originalTag: "go",
+				objPath:     ".#ExampleFooBar",
+				fmt:         "codefence",
+				lang:        "go",
+				sub:         lineRange{first: 2, last: 4, from: reg(`^\tfoo`)},
+			},
+			"",
+		},
+		{
+			"goquote lines example",
+			`<!-- goquote .#ExampleFooBar lines=2-4 fmt=example -->`,
+			nil,
+			"validating pullquote at offset 0: goquote: lines, from, and to cannot be used with fmt=example",
+		},
+		{
+			"goquote whole file",
+			`<!-- goquote ./main.go nopackage noimports -->`,
+			&pullQuote{
+				quoteType:   "go",
+				originalTag: "go",
+				objPath:     "./main.go",
+				fmt:         "codefence",
+				lang:        "go",
+				flags:       omitPackage | omitImports,
+			},
+			"",
+		},
+		{
+			"goquote empty symbol",
+			`<!-- goquote ./main.go# -->`,
+			nil,
+			"validating pullquote at offset 0: goquote: a symbol is required after '#'",
+		},
+		{
+			"goquote empty path",
+			`<!-- goquote "" -->`,
+			nil,
+			"validating pullquote at offset 0: goquote: a file or package is required",
+		},
+		{
+			"goquote declsonly with symbol",
+			`<!-- goquote ./main.go#main declsonly -->`,
+			nil,
+			"validating pullquote at offset 0: goquote: nopackage, noimports, and declsonly only apply to whole files or packages",
+		},
+		{
+			"goquote withimports",
+			`<!-- goquote ./main.go#main withimports -->`,
+			&pullQuote{
+				quoteType:   "go",
+				originalTag: "go",
+				objPath:     "./main.go#main",
+				fmt:         "codefence",
+				lang:        "go",
+				flags:       withImports,
+			},
+			"",
+		},
+		{
+			"goquote playground",
+			`<!-- goquote .#ExampleFoo,helper fmt=playground typecheck -->`,
+			&pullQuote{
+				quoteType:   "go",
+				originalTag: "go",
+				objPath:     ".#ExampleFoo,helper",
+				fmt:         "playground",
+				lang:        "go",
+				flags:       typeCheck,
+			},
+			"",
+		},
+		{
+			"typecheck without playground",
+			`<!-- goquote .#Foo typecheck -->`,
+			nil,
+			"validating pullquote at offset 0: goquote: typecheck only applies to fmt=playground",
+		},
+		{
+			"goquote methodset",
+			`<!-- goquote .#Server fmt=methodset layout=table -->`,
+			&pullQuote{
+				quoteType:   "go",
+				originalTag: "go",
+				objPath:     ".#Server",
+				fmt:         "methodset",
+				layout:      "table",
+			},
+			"",
+		},
+		{
+			"goquote methodset no type",
+			`<!-- goquote . fmt=methodset -->`,
+			nil,
+			"validating pullquote at offset 0: goquote: fmt=methodset requires a type name",
+		},
+		{
+			"layout without methodset",
+			`<!-- goquote .#Server layout=list -->`,
+			nil,
+			"validating pullquote at offset 0: layout only applies to fmt=methodset",
+		},
+		{
+			"link",
+			`<!-- goquote .#Server link=blob linktemplate="https://example.com/{commit}/{path}#L{start}" -->`,
+			&pullQuote{
+				quoteType:    "go",
+				originalTag:  "go",
+				objPath:      ".#Server",
+				fmt:          "codefence",
+				lang:         "go",
+				link:         "blob",
+				linkTemplate: "https://example.com/{commit}/{path}#L{start}",
+			},
+			"",
+		},
+		{
+			"link without symbol",
+			`<!-- goquote . link=pkgsite -->`,
+			nil,
+			"validating pullquote at offset 0: goquote: link requires a symbol",
+		},
+		{
+			"link unknown",
+			`<!-- goquote .#Server link=github -->`,
+			nil,
+			"validating pullquote at offset 0: goquote: link must be pkgsite or blob",
+		},
+		{
+			"linkversion without pkgsite",
+			`<!-- goquote .#Server link=blob linkversion=v1.0.0 -->`,
+			nil,
+			"validating pullquote at offset 0: goquote: linkversion only applies to link=pkgsite",
+		},
+		{
+			"jsonquote query",
+			`<!-- jsonquote foo/bar.json query="$.plugins[*].name" split -->`,
+			&pullQuote{
+				quoteType:   "json",
+				originalTag: "json",
+				objPath:     "foo/bar.json",
+				fmt:         "codefence",
+				lang:        "json",
+				flags:       splitResults,
+			},
+			"",
+		},
+		{
+			"jsonquote invalid query",
+			`<!-- jsonquote foo/bar.json query="$.plugins[" -->`,
+			nil,
+			`parsing pullquote at offset 0: invalid query "$.plugins[" at offset 10: expected a name, index, slice, wildcard, or filter`,
+		},
+		{
+			"jsonquote split without query",
+			`<!-- jsonquote foo/bar.json split -->`,
+			nil,
+			"validating pullquote at offset 0: jsonquote: split requires a query",
+		},
+		{
+			"yamlquote",
+			`<!-- yamlquote deploy.yaml#1/spec -->`,
+			&pullQuote{
+				quoteType:   "yaml",
+				originalTag: "yaml",
+				objPath:     "deploy.yaml#1/spec",
+				fmt:         "codefence",
+				lang:        "yaml",
+			},
+			"",
+		},
+		{
+			"yamlquote no file",
+			`<!-- yamlquote #/spec -->`,
+			nil,
+			"validating pullquote at offset 0: yamlquote: a file is required",
+		},
+		{
+			"yamlquote query",
+			`<!-- yamlquote deploy.yaml query="$.spec" -->`,
+			nil,
+			"validating pullquote at offset 0: yamlquote: invalid keys: query",
+		},
+		{
+			"tomlquote",
+			`<!-- tomlquote pyproject.toml#tool.poetry -->`,
+			&pullQuote{
+				quoteType:   "toml",
+				originalTag: "toml",
+				objPath:     "pyproject.toml#tool.poetry",
+				fmt:         "codefence",
+				lang:        "toml",
+			},
+			"",
+		},
+		{
+			"jsonquote noreformat with indent",
+			`<!-- jsonquote foo/bar.json indent=4 noreformat -->`,
+			nil,
+			"validating pullquote at offset 0: jsonquote: noreformat can't be combined with indent, compact, sortkeys, or depth",
+		},
+		{
+			"jsonquote compact with indent",
+			`<!-- jsonquote foo/bar.json indent=tab compact -->`,
+			nil,
+			"validating pullquote at offset 0: jsonquote: compact can't be combined with indent",
+		},
+		{
+			"jsonquote invalid indent",
+			`<!-- jsonquote foo/bar.json indent=wide -->`,
+			nil,
+			`parsing pullquote at offset 0: invalid indent "wide": must be tab or 1 to 8 spaces`,
+		},
+		{
+			"jsonquote invalid depth",
+			`<!-- jsonquote foo/bar.json depth=0 -->`,
+			nil,
+			`parsing pullquote at offset 0: invalid depth "0": must be a positive integer`,
+		},
+		{
+			"jsonquote schema",
+			`<!-- jsonquote schema.json#/definitions/Server fmt=schema -->`,
+			&pullQuote{
+				quoteType:   "json",
+				originalTag: "json",
+				objPath:     "schema.json#/definitions/Server",
+				fmt:         "schema",
+			},
+			"",
+		},
+		{
+			"jsonquote schema with query",
+			`<!-- jsonquote schema.json fmt=schema query="$.properties" -->`,
+			nil,
+			"validating pullquote at offset 0: jsonquote: fmt=schema can't be combined with query or formatting options",
+		},
+		{
+			"yamlquote schema",
+			`<!-- yamlquote schema.yaml fmt=schema -->`,
+			nil,
+			"validating pullquote at offset 0: fmt=schema is only supported by jsonquote",
+		},
+		{
+			"yamlquote table",
+			`<!-- yamlquote services.yaml#/services fmt=table columns="name, port" sortby=-port -->`,
+			&pullQuote{
+				quoteType:   "yaml",
+				originalTag: "yaml",
+				objPath:     "services.yaml#/services",
+				fmt:         "table",
+				tableFmt:    tableFormat{columns: []string{"name", "port"}, sortBy: "-port"},
+			},
+			"",
+		},
+		{
+			"jsonquote table with split",
+			`<!-- jsonquote plugins.json fmt=table query="$.plugins[*]" split -->`,
+			nil,
+			"validating pullquote at offset 0: jsonquote: fmt=table can't be combined with split or formatting options",
+		},
+		{
+			"jsonquote columns without table",
+			`<!-- jsonquote plugins.json#/plugins columns=name -->`,
+			nil,
+			"validating pullquote at offset 0: columns, sortby, and alignnumbers only apply to fmt=table",
+		},
+		{
+			"goquote table",
+			`<!-- goquote .#Foo fmt=table -->`,
+			nil,
+			"validating pullquote at offset 0: fmt=table is only supported by jsonquote, yamlquote, tomlquote, and csvquote",
+		},
+		{
+			"jsonquote empty sortby",
+			`<!-- jsonquote plugins.json#/plugins fmt=table sortby=- -->`,
+			nil,
+			`parsing pullquote at offset 0: invalid sortby "-": must name a column`,
+		},
+		{
+			"csvquote tsv",
+			`<!-- csvquote matrix.tsv noheader rows=1: columns=1,4 alignnumbers -->`,
+			&pullQuote{
+				quoteType:   "csv",
+				originalTag: "csv",
+				objPath:     "matrix.tsv",
+				fmt:         "table",
+				delimiter:   '\t',
+				rows:        &recordRange{start: 1, end: -1},
+				tableFmt:    tableFormat{columns: []string{"1", "4"}, alignNumbers: true},
+				flags:       noHeader,
+			},
+			"",
+		},
+		{
+			"csvquote fragment",
+			`<!-- csvquote data.csv#2 -->`,
+			nil,
+			"validating pullquote at offset 0: csvquote: a fragment isn't supported; select rows with where or rows and columns with columns",
+		},
+		{
+			"csvquote codefence",
+			`<!-- csvquote data.csv fmt=codefence -->`,
+			nil,
+			"validating pullquote at offset 0: csvquote: fmt must be table",
+		},
+		{
+			"csvquote invalid delimiter",
+			`<!-- csvquote data.csv delimiter=;; -->`,
+			nil,
+			`parsing pullquote at offset 0: invalid delimiter ";;": must be a single character or tab`,
+		},
+		{
+			"protoquote rpc",
+			`<!-- protoquote api/v1/user.proto#UserService.GetUser includegroup -->`,
+			&pullQuote{
+				quoteType:   "proto",
+				originalTag: "proto",
+				objPath:     "api/v1/user.proto#UserService.GetUser",
+				fmt:         "codefence",
+				lang:        "proto",
+				flags:       includeGroup,
+			},
+			"",
+		},
+		{
+			"protoquote includegroup without symbol",
+			`<!-- protoquote api/v1/user.proto includegroup -->`,
+			nil,
+			"validating pullquote at offset 0: protoquote: includegroup requires a symbol",
+		},
+		{
+			"protoquote unknown key",
+			`<!-- protoquote api/v1/user.proto#User query="$.x" -->`,
+			nil,
+			"validating pullquote at offset 0: protoquote: invalid keys: query",
+		},
+		{
+			"protoquote explicit codefence",
+			`<!-- protoquote api/v1/user.proto#User fmt=codefence -->`,
+			&pullQuote{
+				quoteType:   "proto",
+				originalTag: "proto",
+				objPath:     "api/v1/user.proto#User",
+				fmt:         "codefence",
+				lang:        "proto",
+			},
+			"",
+		},
+		{
+			"protoquote fmt",
+			`<!-- protoquote api/v1/user.proto#User fmt=example -->`,
+			nil,
+			"validating pullquote at offset 0: protoquote: fmt must be codefence, blockquote, or none",
+		},
+		{
+			"sqlquote named",
+			`<!-- sqlquote queries.sql#GetUser lines=2-4 -->`,
+			&pullQuote{
+				quoteType:   "sql",
+				originalTag: "sql",
+				objPath:     "queries.sql#GetUser",
+				fmt:         "codefence",
+				lang:        "sql",
+				sub:         lineRange{first: 2, last: 4},
+			},
+			"",
+		},
+		{
+			"sqlquote empty name",
+			`<!-- sqlquote queries.sql# -->`,
+			nil,
+			"validating pullquote at offset 0: sqlquote: a statement name is required after '#'",
+		},
+		{
+			"sqlquote explicit codefence",
+			`<!-- sqlquote queries.sql#GetUser fmt=codefence -->`,
+			&pullQuote{
+				quoteType:   "sql",
+				originalTag: "sql",
+				objPath:     "queries.sql#GetUser",
+				fmt:         "codefence",
+				lang:        "sql",
+			},
+			"",
+		},
+		{
+			"pyquote signature",
+			`<!-- pyquote sdk/client.py#Client.connect signature -->`,
+			&pullQuote{
+				quoteType:   "py",
+				originalTag: "py",
+				objPath:     "sdk/client.py#Client.connect",
+				fmt:         "codefence",
+				lang:        "python",
+				flags:       signatureOnly,
+			},
+			"",
+		},
+		{
+			"pyquote signature without symbol",
+			`<!-- pyquote sdk/client.py signature -->`,
+			nil,
+			"validating pullquote at offset 0: pyquote: signature requires a symbol",
+		},
+		{
+			"pyquote fmt",
+			`<!-- pyquote sdk/client.py#Client fmt=example -->`,
+			nil,
+			"validating pullquote at offset 0: pyquote: fmt must be codefence, blockquote, or none",
+		},
+		{
+			"codequote by extension",
+			`<!-- codequote src/client.ts#Client.connect -->`,
+			&pullQuote{
+				quoteType:   "code",
+				originalTag: "code",
+				objPath:     "src/client.ts#Client.connect",
+				fmt:         "codefence",
+				lang:        "typescript",
+			},
+			"",
+		},
+		{
+			"codequote lang override",
+			`<!-- codequote src/widget.jsm#Widget lang=js -->`,
+			&pullQuote{
+				quoteType:   "code",
+				originalTag: "code",
+				objPath:     "src/widget.jsm#Widget",
+				fmt:         "codefence",
+				lang:        "js",
+			},
+			"",
+		},
+		{
+			"codequote unknown extension",
+			`<!-- codequote src/widget.jsm#Widget -->`,
+			nil,
+			"validating pullquote at offset 0: codequote: can't tell the language of src/widget.jsm from its extension; set lang",
+		},
+		{
+			"codequote unsupported lang",
+			`<!-- codequote src/widget.jsm#Widget lang=cobol -->`,
+			nil,
+			"validating pullquote at offset 0: codequote: unsupported lang \"cobol\"; must be one of " + braceLangNames(),
+		},
+		{
+			"codequote blockquote",
+			`<!-- codequote src/client.ts#Client fmt=blockquote -->`,
+			&pullQuote{
+				quoteType:   "code",
+				originalTag: "code",
+				objPath:     "src/client.ts#Client",
+				fmt:         "blockquote",
+				lang:        "typescript",
+			},
+			"",
+		},
+		{
+			"codequote fmt",
+			`<!-- codequote src/client.ts#Client fmt=example -->`,
+			nil,
+			"validating pullquote at offset 0: codequote: fmt must be codefence, blockquote, or none",
+		},
+		{
+			"makequote target",
+			`<!-- makequote Makefile#test -->`,
+			&pullQuote{
+				quoteType:   "make",
+				originalTag: "make",
+				objPath:     "Makefile#test",
+				fmt:         "codefence",
+				lang:        "makefile",
+			},
+			"",
+		},
+		{
+			"makequote shell function",
+			`<!-- makequote scripts/action-entrypoint.sh#main lines=2-4 -->`,
+			&pullQuote{
+				quoteType:   "make",
+				originalTag: "make",
+				objPath:     "scripts/action-entrypoint.sh#main",
+				fmt:         "codefence",
+				lang:        "sh",
+				sub:         lineRange{first: 2, last: 4},
+			},
+			"",
+		},
+		{
+			"makequote unknown file",
+			`<!-- makequote build.gradle#test -->`,
+			nil,
+			"validating pullquote at offset 0: makequote: build.gradle is neither a Makefile nor a shell script",
+		},
+		{
+			"makequote explicit codefence",
+			`<!-- makequote scripts/release.bash#main fmt=codefence -->`,
+			&pullQuote{
+				quoteType:   "make",
+				originalTag: "make",
+				objPath:     "scripts/release.bash#main",
+				fmt:         "codefence",
+				lang:        "bash",
+			},
+			"",
+		},
+		{
+			"mdquote section",
+			`<!-- mdquote ../docs/install.md#quick-start noheading shift=-1 -->`,
+			&pullQuote{
+				quoteType:    "md",
+				originalTag:  "md",
+				objPath:      "../docs/install.md#quick-start",
+				fmt:          "none",
+				flags:        omitHeading,
+				headingShift: -1,
+			},
+			"",
+		},
+		{
+			"mdquote noheading without anchor",
+			`<!-- mdquote ../docs/install.md noheading -->`,
+			nil,
+			"validating pullquote at offset 0: mdquote: noheading requires a heading's anchor",
+		},
+		{
+			"mdquote invalid shift",
+			`<!-- mdquote ../docs/install.md#quick-start shift=up -->`,
+			nil,
+			`parsing pullquote at offset 0: invalid shift "up": must be a nonzero integer from -5 to 5`,
+		},
+		{
+			"mdquote fmt",
+			`<!-- mdquote ../docs/install.md#quick-start fmt=example -->`,
+			nil,
+			"validating pullquote at offset 0: mdquote: fmt must be none, blockquote, or codefence",
+		},
+		{
+			"jsonquote jsonc by extension",
+			`<!-- jsonquote .vscode/settings.jsonc#/editor.tabSize -->`,
+			&pullQuote{
+				quoteType:   "json",
+				originalTag: "json",
+				objPath:     ".vscode/settings.jsonc#/editor.tabSize",
+				fmt:         "codefence",
+				lang:        "jsonc",
+			},
+			"",
+		},
+		{
+			"jsonquote json5 with sortkeys",
+			`<!-- jsonquote tsconfig.json dialect=json5 sortkeys -->`,
+			nil,
+			"validating pullquote at offset 0: jsonquote: dialect=json5 is quoted as written and can't be combined with query, where, records, fmt=schema, fmt=table, or formatting options",
+		},
+		{
+			"jsonquote invalid dialect",
+			`<!-- jsonquote tsconfig.json dialect=hjson -->`,
+			nil,
+			`parsing pullquote at offset 0: invalid dialect "hjson": must be json, jsonc, json5, or ndjson`,
+		},
+		{
+			"jsonquote records with index",
+			`<!-- jsonquote events.ndjson#3/payload records=:5 -->`,
+			nil,
+			"validating pullquote at offset 0: jsonquote: records can't be combined with a record index",
+		},
+		{
+			"jsonquote records with query",
+			`<!-- jsonquote events.ndjson records=:5 query="$.payload" -->`,
+			nil,
+			"validating pullquote at offset 0: jsonquote: records renders NDJSON and can't be combined with query, fmt=schema, or formatting options",
+		},
+		{
+			"jsonquote invalid where",
+			`<!-- jsonquote events.ndjson where="@.level ==" -->`,
+			nil,
+			`parsing pullquote at offset 0: invalid filter "@.level ==" at offset 10: expected a path, literal, or '('`,
+		},
+		{
+			"jsonquote whole file",
+			`<!-- jsonquote foo/bar.json -->`,
+			&pullQuote{
+				quoteType:   "json",
+				originalTag: "json",
+				objPath:     "foo/bar.json",
+				fmt:         "codefence",
+				lang:        "json",
+			},
+			"",
+		},
 		{
 			"jsonquote example",
 			`<!-- jsonquote foo/bar#/biz/0/baz -->`,
@@ -363,6 +959,39 @@ func Test_parseLine(t *testing.T) {
 	}
 }
 
+func Test_quoteTypes(t *testing.T) {
+	for tag, typ := range quoteTags {
+		if typ == "pull" {
+			continue
+		}
+		qt, ok := quoteTypes[typ]
+		if !ok {
+			t.Fatalf("%v has no quote type", tag)
+		}
+		t.Run(tag, func(t *testing.T) {
+			path := "a.rs" // every quote type is checked as it's read, and makequote and codequote are choosy
+			if typ == "make" {
+				path = "a.sh"
+			}
+			for line, want := range map[string]string{
+				"<!-- " + tag + " " + path + " -->":                  "<!-- " + tag + ` "` + path + `"`,
+				"<!-- pullquote " + qt.pathKey + "=" + path + " -->": "<!-- pullquote " + qt.pathKey + `="` + path + `"`,
+			} {
+				pqs, err := readPullQuotes(context.Background(), strings.NewReader(line))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if pqs[0].quoteType != typ || pqs[0].objPath != path {
+					t.Fatalf("%v: wanted a %vquote of %v but got %v", line, typ, path, pqs[0])
+				}
+				if got := pqs[0].String(); !strings.HasPrefix(got, want) {
+					t.Fatalf("%v: wanted prefix %q but got %q", line, want, got)
+				}
+			}
+		})
+	}
+}
+
 func Test_readPullQuotes(t *testing.T) {
 	type testCase struct {
 		name, contents string
@@ -890,6 +1519,110 @@ const a int = 23`,
 			},
 			"",
 		},
+		{
+			"scoped",
+			"my/path.go",
+			[][2]string{
+				{"my/local.go",
+					`package main
+
+func main() {
+	cfg := load()
+	if cfg == nil {
+		cfg := defaults()
+		_ = cfg
+	}
+	// retry is where we go again
+retry:
+	for {
+		continue retry
+	}
+}
+
+type server struct{}
+
+func (s *server) serve() {
+	var (
+		// limit caps things
+		limit = 10
+		burst = 20
+	)
+}
+`},
+				{"my/local_test.go",
+					`package main
+
+func TestServer(t *testing.T) {
+	handler := func() {}
+	t.Run("handles empty input", func(t *testing.T) {
+		handler := func() {
+			panic("empty")
+		}
+		handler()
+	})
+}
+`},
+			},
+			[]*pullQuote{
+				{quoteType: "go", objPath: "./#main/cfg/2"},
+				{quoteType: "go", objPath: "./#main/retry"},
+				{quoteType: "go", objPath: "./#server.serve/limit"},
+				{quoteType: "go", objPath: "./#TestServer/handler"},
+				{quoteType: "go", objPath: "./#TestServer/handles_empty_input/handler"},
+				{quoteType: "go", objPath: "./#TestServer/handles empty input"},
+			},
+			[]string{
+				"cfg := defaults()",
+				"retry:\n\tfor {\n\t\tcontinue retry\n\t}",
+				"// limit caps things\nlimit = 10",
+				"handler := func() {}",
+				"handler := func() {\n\tpanic(\"empty\")\n}",
+				"t.Run(\"handles empty input\", func(t *testing.T) {\n\thandler := func() {\n\t\tpanic(\"empty\")\n\t}\n\thandler()\n})",
+			},
+			"",
+		},
+		{
+			"scoped missing occurrence",
+			"my/path.go",
+			[][2]string{{"my/local.go",
+				`package main
+
+func main() {
+	cfg := load()
+}
+`}},
+			[]*pullQuote{
+				{quoteType: "go", objPath: "./#main/cfg/2"},
+			},
+			nil,
+			"error within my/: wanted occurrence 2 of \"cfg\" within main but only found 1",
+		},
+		{
+			"sub range",
+			"my/path.go",
+			[][2]string{{"my/local.go",
+				`package main
+
+// doc comment
+func fooBar() {
+	a := 23
+	for i := 0; i < a; i++ {
+		// interesting part
+		fmt.Println(i)
+	}
+	return
+}
+`}},
+			[]*pullQuote{
+				{quoteType: "go", objPath: "local.go#fooBar", sub: lineRange{first: 4, from: reg("interesting"), to: reg("Println")}},
+				{quoteType: "go", objPath: "local.go#fooBar", sub: lineRange{first: 4, last: 7}},
+			},
+			[]string{
+				"// interesting part\nfmt.Println(i)",
+				"for i := 0; i < a; i++ {\n\t// interesting part\n\tfmt.Println(i)\n}",
+			},
+			"",
+		},
 	} {
 		t.Run(c.name, func(t *testing.T) {
 			d := changeTmpDir(t)
@@ -989,11 +1722,19 @@ func comparePQ(t *testing.T, label string, src string, expected, got *pullQuote)
 		{"src", expected.src, got.src},
 		{"fmt", expected.fmt, got.fmt},
 		{"lang", expected.lang, got.lang},
+		{"layout", expected.layout, got.layout},
+		{"link", expected.link, got.link},
+		{"linkTemplate", expected.linkTemplate, got.linkTemplate},
+		{"linkVersion", expected.linkVersion, got.linkVersion},
 
 		{"endCount", expected.endCount, got.endCount},
 		{"start", expected.start, got.start},
 		{"end", expected.end, got.end},
 
+		{"lines", expected.sub.linesString(), got.sub.linesString()},
+		{"from", expected.sub.from, got.sub.from},
+		{"to", expected.sub.to, got.sub.to},
+
 		{"flags", int(expected.flags), int(got.flags)},
 	}
 
@@ -1185,6 +1926,35 @@ bye
 				{str: "<!-- /goquote -->"},
 			},
 		},
+		// bufio.Scanner first reads 4096 bytes, so these split comments and codefences between reads
+		{
+			"comment split across reads",
+			strings.Repeat("a", 4090) + "<!-- goquote .#fooBar -->\n<!-- /goquote -->",
+			[]pos{
+				{str: "<!-- goquote .#fooBar -->"},
+				{str: "<!-- /goquote -->"},
+			},
+		},
+		{
+			"comment opener split across reads",
+			strings.Repeat("a", 4094) + "<!-- goquote .#fooBar -->",
+			[]pos{{str: "<!-- goquote .#fooBar -->"}},
+		},
+		{
+			"comment after a stray closer split across reads",
+			"a --> " + strings.Repeat("a", 4080) + "<!-- goquote .#fooBar -->",
+			[]pos{{str: "<!-- goquote .#fooBar -->"}},
+		},
+		{
+			"codefence split across reads",
+			strings.Repeat("a", 4093) + "\n```\n<!-- goquote .#inFence -->\n```\n<!-- goquote .#fooBar -->",
+			[]pos{{str: "<!-- goquote .#fooBar -->"}},
+		},
+		{
+			"long text between comments",
+			"<!-- one -->" + strings.Repeat("a", 10000) + "<!-- two -->",
+			[]pos{{str: "<!-- one -->"}, {str: "<!-- two -->"}},
+		},
 	}
 	if readMe := loadReadMe(t); readMe != "" {
 		cases = append(cases, testCase{
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+)
+
+func expandPyQuotes(_ context.Context, pqs []*pullQuote) ([]*expanded, error) {
+	exp := make([]*expanded, 0, len(pqs))
+	for _, pq := range pqs {
+		pat, sym, hasSym := splitObjPath(pq.objPath) // no symbol quotes the whole file
+
+		s, err := func() (string, error) {
+			src, err := ioutil.ReadFile(pat)
+			if err != nil {
+				return "", err
+			}
+			b := bytes.TrimRight(src, "\r\n")
+			if hasSym {
+				if b, err = sprintPyDecl(src, sym, pq.flags&signatureOnly != 0); err != nil {
+					return "", err
+				}
+			}
+			if pq.sub.isSet() {
+				inString := pyStringLines(b)
+				var skipped int
+				if b, skipped, err = pq.sub.apply(b); err != nil {
+					return "", fmt.Errorf("selecting within %q: %w", sym, err)
+				}
+				b = dedentPython(b, -1, inString[skipped:])
+			}
+			return string(b), nil
+		}()
+		if err != nil {
+			return nil, fmt.Errorf("error within %v: %w", pat, err)
+		}
+		exp = append(exp, &expanded{String: s})
+	}
+	return exp, nil
+}
+
+// pyDecl is a def or class, from its first decorator through the last line of its body.
+type pyDecl struct {
+	kind, name string
+	start, end int
+	// colon is the offset of the colon which ends the header
+	colon    int
+	indent   int
+	parent   *pyDecl
+	children []*pyDecl
+}
+
+func (d *pyDecl) path() string {
+	if d.parent == nil {
+		return d.name
+	}
+	return d.parent.path() + "." + d.name
+}
+
+// sprintPyDecl finds the def or class at a dotted path like `Client.connect` or `Outer.Inner` and returns its text,
+// decorators and docstring included, dedented. With signature, it returns only the decorators and header.
+func sprintPyDecl(src []byte, sym string, signature bool) ([]byte, error) {
+	decls, err := parsePython(src)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		found  *pyDecl
+		within = decls
+	)
+	for _, seg := range strings.Split(sym, ".") {
+		found = nil
+		for _, d := range within {
+			if d.name == seg {
+				found = d
+				break
+			}
+		}
+		if found == nil {
+			avail := make([]string, 0, len(within))
+			for _, d := range within {
+				avail = append(avail, d.path())
+			}
+			return nil, fmt.Errorf("couldn't find %q; available: %v", sym, summarizeKeys(avail))
+		}
+		within = found.children
+	}
+
+	end := found.end
+	if signature {
+		end = found.colon + 1
+	}
+	return dedentPython(src[found.start:end], found.indent, nil), nil
+}
+
+// dedentPython removes indent bytes of leading whitespace from each line of b which has that many or, if indent is
+// negative, the whitespace common to its lines of code. Lines which continue a multi-line string, per inString, don't
+// count toward that: a string's contents at column 0 mustn't keep the code around it indented, and they're otherwise
+// left as written.
+func dedentPython(b []byte, indent int, inString []bool) []byte {
+	lines := bytes.SplitAfter(b, []byte("\n"))
+	if indent < 0 {
+		for i, l := range lines {
+			if i < len(inString) && inString[i] || len(bytes.TrimSpace(l)) == 0 {
+				continue
+			}
+			if n := len(l) - len(bytes.TrimLeft(l, " \t")); indent < 0 || n < indent {
+				indent = n
+			}
+		}
+	}
+
+	out := make([]byte, 0, len(b))
+	for _, l := range lines {
+		n := 0
+		for n < indent && n < len(l) && (l[n] == ' ' || l[n] == '\t') {
+			n++
+		}
+		if n == indent || len(bytes.TrimSpace(l)) == 0 {
+			l = l[n:]
+		}
+		out = append(out, l...)
+	}
+	return out
+}
+
+// pyStringLines reports, for each line of src, whether it begins within a multi-line string. If src can't be scanned,
+// no lines are.
+func pyStringLines(src []byte) []bool {
+	lines, err := scanPython(src)
+	if err != nil {
+		return nil
+	}
+	inString := make([]bool, bytes.Count(src, []byte("\n"))+1)
+	for _, l := range lines {
+		for _, off := range l.strLines {
+			inString[bytes.Count(src[:off], []byte("\n"))] = true
+		}
+	}
+	return inString
+}
+
+var pyDeclHeader = regexp.MustCompile(`^(?:async\s+)?(def|class)\s+([\p{L}_][\p{L}\p{N}_]*)`)
+
+// parsePython finds the defs and classes in a file, nested by indentation. Those within other blocks, like
+// `if TYPE_CHECKING:`, belong to the nearest enclosing def or class.
+func parsePython(src []byte) ([]*pyDecl, error) {
+	lines, err := scanPython(src)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		decls      []*pyDecl
+		stack      []*pyDecl
+		decorators = -1 // the start of any decorators awaiting their def or class
+	)
+	for _, l := range lines {
+		for len(stack) > 0 && stack[len(stack)-1].indent >= l.indent {
+			stack = stack[:len(stack)-1]
+		}
+		for _, d := range stack {
+			d.end = l.end
+		}
+
+		text := string(src[l.start+l.indent : l.firstEnd])
+		if strings.HasPrefix(text, "@") {
+			if decorators < 0 {
+				decorators = l.start
+			}
+			continue
+		}
+		m := pyDeclHeader.FindStringSubmatch(text)
+		if m == nil || l.colon < 0 {
+			decorators = -1
+			continue
+		}
+
+		d := &pyDecl{kind: m[1], name: m[2], start: l.start, end: l.end, colon: l.colon, indent: l.indent}
+		if decorators >= 0 {
+			d.start, decorators = decorators, -1
+		}
+		if len(stack) > 0 {
+			d.parent = stack[len(stack)-1]
+			d.parent.children = append(d.parent.children, d)
+		} else {
+			decls = append(decls, d)
+		}
+		stack = append(stack, d)
+	}
+	return decls, nil
+}
+
+// pyLogicalLine is a statement's worth of physical lines, joined by brackets, strings, or backslashes.
+type pyLogicalLine struct {
+	// start is the offset of its first physical line; firstEnd and end are those of its first and last
+	start, firstEnd, end int
+	// indent is the length of its leading whitespace
+	indent int
+	// colon is the offset of its first colon outside of brackets, strings, and comments, or -1
+	colon int
+	// strLines are the offsets of its physical lines which begin within a string
+	strLines []int
+}
+
+// scanPython splits a file into logical lines, skipping blank lines and comments.
+func scanPython(src []byte) ([]pyLogicalLine, error) {
+	var (
+		lines []pyLogicalLine
+		cur   *pyLogicalLine // nil between logical lines
+		depth int
+		quote string // the delimiter which closes the current string
+		qOff  int
+	)
+	lineEnd := func(i int) int {
+		if i > 0 && src[i-1] == '\r' {
+			return i - 1
+		}
+		return i
+	}
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		if quote != "" {
+			switch {
+			case c == '\\':
+				i++
+			case bytes.HasPrefix(src[i:], []byte(quote)):
+				i += len(quote) - 1
+				quote = ""
+			case c == '\n' && len(quote) == 1:
+				return nil, fmt.Errorf("line %d: unterminated string", bytes.Count(src[:qOff], []byte("\n"))+1)
+			}
+			if i < len(src) && src[i] == '\n' && quote != "" {
+				cur.strLines = append(cur.strLines, i+1)
+			}
+			continue
+		}
+
+		switch {
+		case c == '\n':
+			if cur != nil && cur.firstEnd < 0 {
+				cur.firstEnd = lineEnd(i)
+			}
+			if cur != nil && depth == 0 {
+				cur.end = lineEnd(i)
+				lines = append(lines, *cur)
+				cur = nil
+			}
+			continue
+		case c == ' ' || c == '\t' || c == '\f' || c == '\r':
+			continue
+		case c == '#':
+			if j := bytes.IndexByte(src[i:], '\n'); j >= 0 {
+				i += j - 1
+			} else {
+				i = len(src)
+			}
+			continue
+		case c == '\\' && (bytes.HasPrefix(src[i+1:], []byte("\n")) || bytes.HasPrefix(src[i+1:], []byte("\r\n"))):
+			if cur != nil && cur.firstEnd < 0 {
+				cur.firstEnd = i
+			}
+			i += bytes.IndexByte(src[i:], '\n')
+			continue
+		}
+
+		if cur == nil {
+			ls := lineStart(src, i)
+			cur = &pyLogicalLine{start: ls, firstEnd: -1, indent: i - ls, colon: -1}
+		}
+		switch c {
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			if depth > 0 {
+				depth--
+			}
+		case ':':
+			if depth == 0 && cur.colon < 0 {
+				cur.colon = i
+			}
+		case '"', '\'':
+			quote, qOff = string(c), i
+			if bytes.HasPrefix(src[i:], bytes.Repeat([]byte{c}, 3)) {
+				quote = strings.Repeat(quote, 3)
+			}
+			i += len(quote) - 1
+		}
+	}
+	if quote != "" {
+		return nil, fmt.Errorf("line %d: unterminated string", bytes.Count(src[:qOff], []byte("\n"))+1)
+	}
+	if cur != nil {
+		if cur.firstEnd < 0 {
+			cur.firstEnd = len(src)
+		}
+		cur.end = len(src)
+		lines = append(lines, *cur)
+	}
+	return lines, nil
+}
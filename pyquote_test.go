@@ -0,0 +1,83 @@
+package main
+
+import (
+	"testing"
+)
+
+func Test_sprintPyDecl(t *testing.T) {
+	const src = "class A:\n" +
+		"    if True:\n" +
+		"        def f(self, s='#:', d={'k': 1}):  # comment: here\n" +
+		"            return \\\n" +
+		"                s\n" +
+		"\n" +
+		"    # trailing comments stay behind\n" +
+		"\n" +
+		"\tdef g(self): pass\n" +
+		"def h():\n" +
+		"    x = '''\n" +
+		"def nope():\n" +
+		"'''\n"
+	for _, c := range []struct {
+		name, sym string
+		signature bool
+		src       string
+		out, err  string
+	}{
+		{name: "within a block", sym: "A.f", out: "def f(self, s='#:', d={'k': 1}):  # comment: here\n    return \\\n        s"},
+		{name: "signature", sym: "A.f", signature: true, out: "def f(self, s='#:', d={'k': 1}):"},
+		{name: "tabs", sym: "A.g", out: "def g(self): pass"},
+		{name: "strings aren't code", sym: "h", out: "def h():\n    x = '''\ndef nope():\n'''"},
+		{name: "missing", sym: "A.nope", err: `couldn't find "A.nope"; available: "A.f", "A.g"`},
+		{name: "missing top-level", sym: "nope", err: `couldn't find "nope"; available: "A", "h"`},
+		{
+			name: "multi-line string at column 0",
+			sym:  "M.name",
+			src:  "class M:\n    def name(self):\n        return \"\"\"\\\nx\n  y\n\"\"\"\n",
+			out:  "def name(self):\n    return \"\"\"\\\nx\n  y\n\"\"\"",
+		},
+		{name: "unterminated", sym: "x", src: "def x():\n    return 'a\n", err: "line 2: unterminated string"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			in := c.src
+			if in == "" {
+				in = src
+			}
+			out, err := sprintPyDecl([]byte(in), c.sym, c.signature)
+			if err != nil {
+				if err.Error() != c.err {
+					t.Fatalf("wanted error %q but got %q", c.err, err)
+				}
+				return
+			}
+			if c.err != "" {
+				t.Fatalf("wanted error %q but got none", c.err)
+			}
+			if string(out) != c.out {
+				t.Errorf("wanted:\n%v\ngot:\n%v", c.out, string(out))
+			}
+		})
+	}
+}
+
+func Test_dedentPython(t *testing.T) {
+	const src = "class M:\n    def name(self):\n        s = '''\nx\n'''\n        return s\n"
+	for _, c := range []struct {
+		name     string
+		in       string
+		indent   int
+		inString []bool
+		out      string
+	}{
+		{"fixed", src, 4, nil, "class M:\ndef name(self):\n    s = '''\nx\n'''\n    return s\n"},
+		{"common", "  a\n\n    b\n", -1, nil, "a\n\n  b\n"},
+		{"common past strings", "        s = '''\nx\n'''\n        return s", -1, []bool{false, true, true}, "s = '''\nx\n'''\nreturn s"},
+		{"scanned", src[9:], -1, pyStringLines([]byte(src[9:])), "def name(self):\n    s = '''\nx\n'''\n    return s\n"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			if out := string(dedentPython([]byte(c.in), c.indent, c.inString)); out != c.out {
+				t.Errorf("wanted:\n%q\ngot:\n%q", c.out, out)
+			}
+		})
+	}
+}
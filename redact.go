@@ -0,0 +1,592 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// redactConfigName is the repo-wide configuration file, found in the quoting file's directory or any parent up to
+	// the root of its git repository
+	redactConfigName = ".pullquote.json"
+	// defaultPlaceholder replaces redacted values
+	defaultPlaceholder = "REDACTED"
+)
+
+// defaultSecretPatterns are secrets which are never meant to be published; finding one in a quote fails check mode and is
+// otherwise redacted.
+var defaultSecretPatterns = []string{
+	`AKIA[0-9A-Z]{16}`,                         // AWS access key IDs
+	`-----BEGIN [A-Z ]*PRIVATE KEY-----`,       // PEM private keys
+	`gh[pousr]_[A-Za-z0-9]{36,}`,               // GitHub tokens
+	`xox[abprs]-[A-Za-z0-9-]{10,}`,             // Slack tokens
+	`sk_live_[0-9A-Za-z]{24,}`,                 // Stripe secret keys
+	`eyJ[\w-]{10,}\.eyJ[\w-]{10,}\.[\w-]{10,}`, // JWTs
+}
+
+// redactConfig is the format of redactConfigName.
+type redactConfig struct {
+	Redact struct {
+		// Keys are glob patterns, like `*password*`, for the JSON, YAML, or TOML keys whose values are redacted
+		Keys []string `json:"keys"`
+		// Patterns are regular expressions whose matches -- or, if they have groups, whose groups -- are redacted
+		Patterns []string `json:"patterns"`
+		// Placeholder replaces redacted values; defaults to REDACTED
+		Placeholder string `json:"placeholder"`
+		// Secrets are regular expressions, in addition to the defaults, which fail check mode if they'd be published and
+		// are otherwise redacted
+		Secrets []string `json:"secrets"`
+	} `json:"redact"`
+}
+
+type redactRules struct {
+	keys        []string
+	patterns    []*regexp.Regexp
+	placeholder string
+	secrets     []*regexp.Regexp
+}
+
+// loadRedactRules reads the nearest redactConfigName above dir, if there is one.
+func loadRedactRules(dir string) (redactRules, error) {
+	rules := redactRules{placeholder: defaultPlaceholder}
+	for _, p := range defaultSecretPatterns {
+		rules.secrets = append(rules.secrets, regexp.MustCompile(p))
+	}
+
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return rules, err
+	}
+	for d := abs; ; d = filepath.Dir(d) {
+		fn := filepath.Join(d, redactConfigName)
+		b, err := ioutil.ReadFile(fn)
+		if err == nil {
+			if err := rules.configure(b); err != nil {
+				return rules, fmt.Errorf("%v: %w", fn, err)
+			}
+			return rules, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return rules, err
+		}
+		if _, err := os.Stat(filepath.Join(d, ".git")); err == nil || filepath.Dir(d) == d {
+			return rules, nil
+		}
+	}
+}
+
+func (r *redactRules) configure(b []byte) error {
+	var cfg redactConfig
+	dec := json.NewDecoder(strings.NewReader(string(b)))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return err
+	}
+
+	for _, k := range cfg.Redact.Keys {
+		if err := validKeyPattern(k); err != nil {
+			return err
+		}
+		r.keys = append(r.keys, strings.ToLower(k))
+	}
+	for _, p := range cfg.Redact.Patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", p, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	for _, p := range cfg.Redact.Secrets {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return fmt.Errorf("invalid secret %q: %w", p, err)
+		}
+		r.secrets = append(r.secrets, re)
+	}
+	if cfg.Redact.Placeholder != "" {
+		r.placeholder = cfg.Redact.Placeholder
+	}
+	return nil
+}
+
+func validKeyPattern(k string) error {
+	if _, err := path.Match(k, ""); err != nil {
+		return fmt.Errorf("invalid key pattern %q: %w", k, err)
+	}
+	return nil
+}
+
+// forQuote adds a directive's own rules to the repo-wide ones.
+func (r redactRules) forQuote(pq *pullQuote) redactRules {
+	if len(pq.redactKeys) > 0 {
+		r.keys = append(r.keys[:len(r.keys):len(r.keys)], pq.redactKeys...)
+	}
+	if pq.redact != nil {
+		r.patterns = append(r.patterns[:len(r.patterns):len(r.patterns)], pq.redact)
+	}
+	return r
+}
+
+func (r redactRules) matchKey(k string) bool {
+	k = strings.ToLower(k)
+	for _, p := range r.keys {
+		if ok, _ := path.Match(p, k); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// redactExpanded applies the rules to everything a quote renders.
+func redactExpanded(pq *pullQuote, exp *expanded, r redactRules) {
+	kind := pq.quoteType
+	if pq.fmt == fmtTable {
+		kind = fmtTable
+	}
+	exp.String = r.apply(kind, exp.String)
+	for i := range exp.Parts {
+		exp.Parts[i] = r.apply(kind, exp.Parts[i])
+	}
+	for i := range exp.Blocks {
+		exp.Blocks[i] = r.apply(kind, exp.Blocks[i])
+	}
+}
+
+// apply redacts s, which is the source text of quoteType or, if quoteType is `table`, the output of fmt=table.
+func (r redactRules) apply(quoteType, s string) string {
+	if len(r.keys) > 0 {
+		switch quoteType {
+		case fmtTable:
+			s = redactTableKeys(s, r)
+		case "json":
+			s = redactJSONKeys(s, r)
+		case "yaml":
+			s = redactYAMLKeys(s, r)
+		case "toml":
+			s = redactTOMLKeys(s, r)
+		}
+	}
+	for _, re := range r.patterns {
+		s = redactPattern(s, re, r.placeholder)
+	}
+	return s
+}
+
+// findSecret returns the first secret pattern matched by anything a quote renders.
+func (r redactRules) findSecret(exp *expanded) *regexp.Regexp {
+	for _, re := range r.secrets {
+		for _, s := range append(append([]string{exp.String}, exp.Parts...), exp.Blocks...) {
+			if re.MatchString(s) {
+				return re
+			}
+		}
+	}
+	return nil
+}
+
+// redactSecrets replaces every whole match of the secret patterns in anything a quote renders.
+func (r redactRules) redactSecrets(exp *expanded) {
+	for _, re := range r.secrets {
+		exp.String = re.ReplaceAllLiteralString(exp.String, r.placeholder)
+		for i := range exp.Parts {
+			exp.Parts[i] = re.ReplaceAllLiteralString(exp.Parts[i], r.placeholder)
+		}
+		for i := range exp.Blocks {
+			exp.Blocks[i] = re.ReplaceAllLiteralString(exp.Blocks[i], r.placeholder)
+		}
+	}
+}
+
+// redactPattern replaces each match of re, or, if re has groups, each group within each match.
+func redactPattern(s string, re *regexp.Regexp, placeholder string) string {
+	if re.NumSubexp() == 0 {
+		return re.ReplaceAllLiteralString(s, placeholder)
+	}
+	var (
+		b    strings.Builder
+		last int
+	)
+	for _, m := range re.FindAllStringSubmatchIndex(s, -1) {
+		for g := 2; g < len(m); g += 2 {
+			if m[g] < last { // unmatched, or nested within a group already replaced
+				continue
+			}
+			b.WriteString(s[last:m[g]])
+			b.WriteString(placeholder)
+			last = m[g+1]
+		}
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// jsonLexeme is a string, a bare word -- a number, literal, or JSON5 key -- or a punctuation character.
+type jsonLexeme struct {
+	start, end int
+}
+
+// lexJSON splits JSON, JSONC, or JSON5 text into lexemes, skipping whitespace and comments. It needn't be valid: a
+// quote which isn't closed on its line is read as punctuation.
+func lexJSON(s string) []jsonLexeme {
+	var lexemes []jsonLexeme
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case strings.HasPrefix(s[i:], "//"):
+			if j := strings.IndexByte(s[i:], '\n'); j >= 0 {
+				i += j
+			} else {
+				i = len(s)
+			}
+		case strings.HasPrefix(s[i:], "/*"):
+			if j := strings.Index(s[i+2:], "*/"); j >= 0 {
+				i += j + 4
+			} else {
+				i = len(s)
+			}
+		case c == '"' || c == '\'':
+			end := i + 1
+			for ; end < len(s) && s[end] != c && s[end] != '\n'; end++ {
+				if s[end] == '\\' {
+					end++
+				}
+			}
+			if end >= len(s) || s[end] != c {
+				end = i // unterminated
+			}
+			lexemes = append(lexemes, jsonLexeme{i, end + 1})
+			i = end + 1
+		case isJSONWordByte(c):
+			end := i + 1
+			for end < len(s) && isJSONWordByte(s[end]) {
+				end++
+			}
+			lexemes = append(lexemes, jsonLexeme{i, end})
+			i = end
+		default:
+			lexemes = append(lexemes, jsonLexeme{i, i + 1})
+			i++
+		}
+	}
+	return lexemes
+}
+
+func isJSONWordByte(c byte) bool {
+	return c >= 0x80 || c == '_' || c == '$' || c == '.' || c == '+' || c == '-' ||
+		'0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// redactJSONKeys replaces the values of matching keys, whole objects and arrays included, in JSON, JSONC, or JSON5 text.
+// The text is lexed rather
+// than parsed, so that it may be a fragment, like a table cell: a key is a string or bare word which follows `{` or
+// `,` and precedes `:`, and text within strings and comments is never taken for one.
+func redactJSONKeys(s string, r redactRules) string {
+	var (
+		b       strings.Builder
+		last    int
+		lexemes = lexJSON(s)
+	)
+	scalar := func(l jsonLexeme) bool {
+		return l.end-l.start > 1 || isJSONWordByte(s[l.start])
+	}
+	for i := 1; i+2 < len(lexemes); i++ {
+		prev, key, colon, val := lexemes[i-1], lexemes[i], lexemes[i+1], lexemes[i+2]
+		if p := s[prev.start:prev.end]; p != "{" && p != "," || !scalar(key) || s[colon.start:colon.end] != ":" {
+			continue
+		}
+		k := s[key.start:key.end]
+		switch k[0] {
+		case '"':
+			if uq, err := strconv.Unquote(k); err == nil {
+				k = uq
+			}
+		case '\'':
+			k = k[1 : len(k)-1]
+		}
+		if !r.matchKey(k) {
+			continue
+		}
+
+		end := i + 2 // the value's last lexeme
+		if c := s[val.start]; !scalar(val) && (c == '{' || c == '[') {
+			// the whole object or array, or everything left of it if the text cuts it off
+			for depth := 0; end < len(lexemes); end++ {
+				switch s[lexemes[end].start] {
+				case '{', '[':
+					depth++
+				case '}', ']':
+					depth--
+				}
+				if depth == 0 {
+					break
+				}
+			}
+			if end == len(lexemes) {
+				end--
+			}
+		} else if !scalar(val) {
+			continue
+		}
+		b.WriteString(s[last:val.start])
+		if s[val.start] == '\'' {
+			b.WriteString("'" + strings.Replace(r.placeholder, "'", `\'`, -1) + "'")
+		} else {
+			b.WriteString(quoteJSON(r.placeholder))
+		}
+		last = lexemes[end].end
+		i = end
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// redactTableKeys replaces the cells of matching columns in the output of fmt=table, along with the values of matching
+// keys nested within its cells.
+func redactTableKeys(s string, r redactRules) string {
+	lines := strings.Split(s, "\n")
+	if len(lines) < 2 {
+		return s
+	}
+	var cols []int
+	for i, h := range splitTableRow(lines[0]) {
+		if r.matchKey(strings.Replace(h, `\|`, "|", -1)) {
+			cols = append(cols, i)
+		}
+	}
+	if len(cols) > 0 {
+		for i := 2; i < len(lines); i++ {
+			cells := splitTableRow(lines[i])
+			for _, c := range cols {
+				if c < len(cells) && cells[c] != "" {
+					cells[c] = escapeTableCell(r.placeholder)
+				}
+			}
+			lines[i] = "| " + strings.Join(cells, " | ") + " |"
+		}
+	}
+	return redactJSONKeys(strings.Join(lines, "\n"), r)
+}
+
+// splitTableRow splits a row written by sprintTable into its cells; pipes within cells are always escaped.
+func splitTableRow(l string) []string {
+	return strings.Split(strings.TrimSuffix(strings.TrimPrefix(l, "| "), " |"), " | ")
+}
+
+var yamlKeyLine = regexp.MustCompile(`^(\s*(?:-\s+)*)("(?:[^"\\]|\\.)*"|'[^']*'|[^\s#'"{\[\-][^#]*?)\s*:(?:\s+|$)`)
+
+// redactYAMLKeys replaces the values of matching keys in block-style YAML: scalars, including block scalars, flow
+// collections, and nested blocks alike.
+func redactYAMLKeys(s string, r redactRules) string {
+	lines := strings.Split(s, "\n")
+	out := make([]string, 0, len(lines))
+	for i := 0; i < len(lines); i++ {
+		l := lines[i]
+		m := yamlKeyLine.FindStringSubmatchIndex(l)
+		if m == nil {
+			out = append(out, l)
+			continue
+		}
+		key := l[m[4]:m[5]]
+		switch key[0] {
+		case '"':
+			if k, err := strconv.Unquote(key); err == nil {
+				key = k
+			}
+		case '\'':
+			key = strings.Replace(key[1:len(key)-1], "''", "'", -1)
+		}
+		if !r.matchKey(key) {
+			out = append(out, l)
+			continue
+		}
+
+		start, keyIndent := m[1], m[3]
+		for start < len(l) && (l[start] == '&' || l[start] == '!') { // an anchor or tag goes along with the value
+			if j := strings.IndexAny(l[start:], " \t"); j >= 0 {
+				start += j + len(l[start+j:]) - len(strings.TrimLeft(l[start+j:], " \t"))
+			} else {
+				start = len(l)
+			}
+		}
+		switch {
+		case start == len(l) || l[start] == '#': // a nested block, if anything
+			if !childBlock(lines[i+1:], keyIndent) {
+				out = append(out, l)
+				continue
+			}
+			out = append(out, strings.TrimRight(l[:m[1]], " \t")+" "+yamlPlaceholder(r.placeholder))
+			for i+1 < len(lines) && (strings.TrimSpace(lines[i+1]) == "" || childBlock(lines[i+1:i+2], keyIndent)) {
+				if strings.TrimSpace(lines[i+1]) == "" && !childBlock(lines[i+1:], keyIndent) {
+					break
+				}
+				i++
+			}
+		case l[start] == '[' || l[start] == '{':
+			j, end := yamlFlowEnd(lines, i, start)
+			out = append(out, l[:m[1]]+yamlPlaceholder(r.placeholder)+lines[j][end:])
+			i = j
+		default:
+			out = append(out, l[:m[1]]+yamlPlaceholder(r.placeholder)+l[yamlScalarEnd(l, start):])
+			if c := l[start]; c == '|' || c == '>' { // drop the block scalar's lines
+				for i+1 < len(lines) && (strings.TrimSpace(lines[i+1]) == "" || indentOf(lines[i+1]) > keyIndent) {
+					if strings.TrimSpace(lines[i+1]) == "" && !moreIndented(lines[i+1:], keyIndent) {
+						break
+					}
+					i++
+				}
+			}
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+// childBlock reports whether, past any blank lines, the next line belongs to the block value of a key at indent:
+// either it's indented more, or it's a sequence entry at the key's own indentation.
+func childBlock(lines []string, indent int) bool {
+	for _, l := range lines {
+		if trimmed := strings.TrimSpace(l); trimmed != "" {
+			return indentOf(l) > indent ||
+				indentOf(l) == indent && (trimmed == "-" || strings.HasPrefix(trimmed, "- "))
+		}
+	}
+	return false
+}
+
+// yamlFlowEnd finds the end of the flow collection opening at lines[i][start], returning the line it closes on and the
+// offset just past its closing bracket, or the end of the text if it never closes.
+func yamlFlowEnd(lines []string, i, start int) (int, int) {
+	depth := 0
+	for ; i < len(lines); i, start = i+1, 0 {
+		l := lines[i]
+		for k := start; k < len(l); k++ {
+			switch c := l[k]; c {
+			case '[', '{':
+				depth++
+			case ']', '}':
+				if depth--; depth == 0 {
+					return i, k + 1
+				}
+			case '"', '\'':
+				k = yamlScalarEnd(l, k) - 1
+			case '#':
+				if k == 0 || l[k-1] == ' ' || l[k-1] == '\t' {
+					k = len(l)
+				}
+			}
+		}
+	}
+	return len(lines) - 1, len(lines[len(lines)-1])
+}
+
+// moreIndented reports whether, past any blank lines, the next line is indented more than indent.
+func moreIndented(lines []string, indent int) bool {
+	for _, l := range lines {
+		if strings.TrimSpace(l) != "" {
+			return indentOf(l) > indent
+		}
+	}
+	return false
+}
+
+// yamlScalarEnd finds the end of a scalar starting at l[start:], before any trailing comment.
+func yamlScalarEnd(l string, start int) int {
+	if start >= len(l) {
+		return start
+	}
+	switch q := l[start]; q {
+	case '"', '\'':
+		for i := start + 1; i < len(l); i++ {
+			switch {
+			case q == '"' && l[i] == '\\':
+				i++
+			case l[i] == q && q == '\'' && i+1 < len(l) && l[i+1] == '\'':
+				i++
+			case l[i] == q:
+				return i + 1
+			}
+		}
+		return len(l)
+	}
+	end := len(l)
+	if i := strings.Index(l[start:], " #"); i >= 0 {
+		end = start + i
+	}
+	return start + len(strings.TrimRight(l[start:end], " \t"))
+}
+
+func quoteJSON(s string) string {
+	var b bytes.Buffer
+	writeJSONString(&b, s)
+	return b.String()
+}
+
+func yamlPlaceholder(p string) string {
+	if p == "" || strings.IndexByte("-?:,[]{}#&*!|>'\"%@`", p[0]) >= 0 || strings.TrimSpace(p) != p ||
+		strings.Contains(p, ": ") || strings.Contains(p, " #") {
+		return quoteJSON(p)
+	}
+	return p
+}
+
+var tomlKeyLine = regexp.MustCompile(
+	`^\s*((?:[A-Za-z0-9_-]+|"(?:[^"\\]|\\.)*"|'[^']*')(?:\s*\.\s*(?:[A-Za-z0-9_-]+|"(?:[^"\\]|\\.)*"|'[^']*'))*)\s*=\s*`,
+)
+
+// redactTOMLKeys replaces the scalar values of matching keys in TOML, matching dotted keys by their last part.
+func redactTOMLKeys(s string, r redactRules) string {
+	lines := strings.Split(s, "\n")
+	out := make([]string, 0, len(lines))
+	for i := 0; i < len(lines); i++ {
+		l := lines[i]
+		m := tomlKeyLine.FindStringSubmatchIndex(l)
+		if m == nil {
+			out = append(out, l)
+			continue
+		}
+		parts, err := parseTOMLPath(l[m[2]:m[3]])
+		start := m[1]
+		if err != nil || len(parts) == 0 || !r.matchKey(parts[len(parts)-1]) ||
+			start >= len(l) || strings.IndexByte("[{#", l[start]) >= 0 {
+			out = append(out, l)
+			continue
+		}
+
+		var rest string
+		switch c := l[start]; {
+		case strings.HasPrefix(l[start:], `"""`), strings.HasPrefix(l[start:], "'''"):
+			delim := l[start : start+3]
+			endL, endC, ok := findTOMLDelim(lines, i, start+3, delim, delim == `"""`)
+			if !ok {
+				out = append(out, l)
+				continue
+			}
+			rest, i = lines[endL][endC:], endL
+		case c == '"' || c == '\'':
+			_, endC, ok := findTOMLDelim(lines[i:i+1], 0, start+1, string(c), c == '"')
+			if !ok {
+				out = append(out, l)
+				continue
+			}
+			rest = l[endC:]
+		default:
+			end := len(l)
+			if j := strings.IndexByte(l[start:], '#'); j >= 0 {
+				end = start + j
+			}
+			end = start + len(strings.TrimRight(l[start:end], " \t"))
+			rest = l[end:]
+		}
+		out = append(out, l[:start]+quoteJSON(r.placeholder)+rest)
+	}
+	return strings.Join(out, "\n")
+}
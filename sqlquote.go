@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+)
+
+func expandSQLQuotes(_ context.Context, pqs []*pullQuote) ([]*expanded, error) {
+	exp := make([]*expanded, 0, len(pqs))
+	for _, pq := range pqs {
+		pat, name, _ := splitObjPath(pq.objPath) // no name quotes the whole file
+
+		s, err := func() (string, error) {
+			src, err := ioutil.ReadFile(pat)
+			if err != nil {
+				return "", err
+			}
+			b := bytes.TrimRight(src, "\r\n")
+			if name != "" {
+				s, err := parseSQL(string(src), name)
+				if err != nil {
+					return "", err
+				}
+				b = []byte(s)
+			}
+			if pq.sub.isSet() {
+				if b, _, err = pq.sub.apply(b); err != nil {
+					return "", fmt.Errorf("selecting within %q: %w", name, err)
+				}
+				b = dedentTabs(dedentSpaces(b))
+			}
+			return string(b), nil
+		}()
+		if err != nil {
+			return nil, fmt.Errorf("error within %v: %w", pat, err)
+		}
+		exp = append(exp, &expanded{String: s})
+	}
+	return exp, nil
+}
+
+var (
+	// sqlNameHeader matches the sqlc and yesql convention, e.g. `-- name: GetUser :one`
+	sqlNameHeader = regexp.MustCompile(`^\s*--\s*name:\s*([^\s]+)`)
+	// sqlMigrationMarker matches sql-migrate, goose, and dbmate sections, e.g. `-- +migrate Up`
+	sqlMigrationMarker = regexp.MustCompile(`(?i)^\s*--\s*(?:\+(?:migrate|goose)\s+(up|down)|migrate:(up|down))\b`)
+)
+
+// sqlSection runs from a header line up to the next one, or the end of the file.
+type sqlSection struct {
+	// name is the statement's name or, for a migration section, `Up` or `Down`
+	name       string
+	migration  bool
+	start, end int
+}
+
+func scanSQL(lines []string) []sqlSection {
+	var sections []sqlSection
+	for i, l := range lines {
+		var sec sqlSection
+		if m := sqlNameHeader.FindStringSubmatch(l); m != nil {
+			sec = sqlSection{name: m[1], start: i}
+		} else if m := sqlMigrationMarker.FindStringSubmatch(l); m != nil {
+			dir := strings.ToLower(m[1] + m[2])
+			sec = sqlSection{name: strings.ToUpper(dir[:1]) + dir[1:], migration: true, start: i}
+		} else {
+			continue
+		}
+		if n := len(sections); n > 0 {
+			sections[n-1].end = i
+		}
+		sections = append(sections, sec)
+	}
+	if n := len(sections); n > 0 {
+		sections[n-1].end = len(lines)
+	}
+	return sections
+}
+
+// parseSQL finds the statement named by a header comment like `-- name: GetUser :one` and returns it from its
+// leading comments through the end of the statement, or up to the next header. For a migration, `Up` or `Down`
+// returns the whole section under a marker like `-- +migrate Up`.
+func parseSQL(src, name string) (string, error) {
+	lines := strings.Split(strings.Replace(src, "\r\n", "\n", -1), "\n")
+	sections := scanSQL(lines)
+
+	var (
+		sec   *sqlSection
+		avail []string
+		seen  = make(map[string]bool)
+	)
+	for i := range sections {
+		s := &sections[i]
+		if !seen[s.name] {
+			seen[s.name] = true
+			avail = append(avail, s.name)
+		}
+		if sec == nil && s.name == name && !s.migration {
+			sec = s
+		}
+	}
+	for i := range sections {
+		if s := &sections[i]; sec == nil && s.migration && strings.EqualFold(s.name, name) {
+			sec = s
+		}
+	}
+	if sec == nil {
+		return "", fmt.Errorf("couldn't find %q; available: %v", name, summarizeKeys(avail))
+	}
+
+	start, end := sec.start, sec.end
+	if !sec.migration {
+		start = sqlLeadingComments(lines, start)
+		if l := sqlStatementEnd(lines, sec.start+1, sec.end); l >= 0 {
+			end = l + 1
+		} else if end < len(lines) {
+			end = sqlLeadingComments(lines, end) // they belong to the next statement
+		}
+	}
+	for end > start && strings.TrimSpace(lines[end-1]) == "" {
+		end--
+	}
+	return string(dedentTabs(dedentSpaces([]byte(strings.Join(lines[start:end], "\n"))))), nil
+}
+
+// sqlLeadingComments extends start upward over any comment lines directly above it which aren't headers or tool
+// annotations like `-- +goose StatementBegin`.
+func sqlLeadingComments(lines []string, start int) int {
+	for start > 0 {
+		l := strings.TrimSpace(lines[start-1])
+		if !strings.HasPrefix(l, "--") || strings.HasPrefix(strings.TrimSpace(l[2:]), "+") ||
+			sqlNameHeader.MatchString(l) || sqlMigrationMarker.MatchString(l) {
+			break
+		}
+		start--
+	}
+	return start
+}
+
+// sqlStatementEnd returns the line, within [from, to), on which the first statement ends with a semicolon outside of
+// any string, quoted identifier, comment, or dollar-quoted body; or -1 if none does. A backslash escapes the next
+// character within single- or double-quoted strings, as in MySQL or Postgres's escape strings, like `E'it\'s'`.
+func sqlStatementEnd(lines []string, from, to int) int {
+	var (
+		inBlock bool   // within /* */
+		quote   string // the closing delimiter of a string, identifier, or dollar-quoted body
+	)
+	for i := from; i < to; i++ {
+		l := lines[i]
+		for j := 0; j < len(l); j++ {
+			switch {
+			case inBlock:
+				if strings.HasPrefix(l[j:], "*/") {
+					inBlock = false
+					j++
+				}
+			case quote == "'" || quote == `"`:
+				if l[j] == '\\' {
+					j++
+				} else if l[j] == quote[0] {
+					quote = ""
+				}
+			case quote != "":
+				if strings.HasPrefix(l[j:], quote) {
+					j += len(quote) - 1
+					quote = ""
+				}
+			case strings.HasPrefix(l[j:], "--"):
+				j = len(l)
+			case strings.HasPrefix(l[j:], "/*"):
+				inBlock = true
+				j++
+			case l[j] == '\'' || l[j] == '"' || l[j] == '`':
+				quote = l[j : j+1] // doubled quotes close and reopen, which comes to the same thing
+			case l[j] == '$':
+				if m := sqlDollarTag.FindString(l[j:]); m != "" {
+					quote = m
+					j += len(m) - 1
+				}
+			case l[j] == ';':
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+var sqlDollarTag = regexp.MustCompile(`^\$(?:[A-Za-z_][A-Za-z0-9_]*)?\$`)
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"testing"
+)
+
+func Test_parseSQL(t *testing.T) {
+	const src = `-- +goose Up
+-- +goose StatementBegin
+-- name: up
+SELECT 'a;b', "c;d", /* e; */ 1; -- trailing
+SELECT 2;
+-- +goose StatementEnd
+
+-- +goose Down
+  -- name: Indented :one
+  -- notes
+  SELECT 3;
+-- name: Escaped
+SELECT 'it\'s; fine',
+  'C:\\';
+SELECT 4;
+-- migrate:down
+`
+	for _, c := range []struct {
+		name, out, err string
+	}{
+		{"up", "-- name: up\nSELECT 'a;b', \"c;d\", /* e; */ 1; -- trailing", ""},
+		{"Up", "-- +goose Up\n-- +goose StatementBegin", ""},
+		{"Indented", "-- name: Indented :one\n-- notes\nSELECT 3;", ""},
+		{"Escaped", "-- name: Escaped\nSELECT 'it\\'s; fine',\n  'C:\\\\';", ""},
+		{"DOWN", "-- +goose Down", ""},
+		{"Nope", "", `couldn't find "Nope"; available: "Up", "up", "Down", "Indented", "Escaped"`},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			out, err := parseSQL(src, c.name)
+			if err != nil {
+				if err.Error() != c.err {
+					t.Fatalf("wanted error %q but got %q", c.err, err)
+				}
+				return
+			}
+			if c.err != "" {
+				t.Fatalf("wanted error %q but got none", c.err)
+			}
+			if out != c.out {
+				t.Errorf("wanted:\n%v\ngot:\n%v", c.out, out)
+			}
+		})
+	}
+}
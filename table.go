@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// tableFormat controls how fmt=table lays out an array of objects.
+type tableFormat struct {
+	// columns chooses and orders the columns; by default, there's a column for each key, in order of appearance
+	columns []string
+	// sortBy sorts rows by a column; a leading `-` sorts descending
+	sortBy string
+	// alignNumbers right-aligns columns whose values are all numbers
+	alignNumbers bool
+}
+
+// sprintTable renders an array of objects, as produced by decodeJSON, as a Markdown table.
+func sprintTable(v interface{}, f tableFormat) (string, error) {
+	arr, ok := v.([]interface{})
+	if !ok {
+		return "", fmt.Errorf("fmt=table requires an array of objects, not %v", describeJSONValue(v))
+	}
+	rows := make([]*jsonObject, 0, len(arr))
+	for i, e := range arr {
+		obj, ok := e.(*jsonObject)
+		if !ok {
+			return "", fmt.Errorf("fmt=table requires an array of objects, but element %d is %v", i, describeJSONValue(e))
+		}
+		rows = append(rows, obj)
+	}
+	if len(rows) == 0 {
+		return "", fmt.Errorf("fmt=table requires an array of objects, but the array is empty")
+	}
+
+	available := newJSONObject() // the union of keys, in order of appearance
+	for _, r := range rows {
+		for _, k := range r.keys {
+			available.set(k, nil)
+		}
+	}
+	columns := f.columns
+	if columns == nil {
+		columns = available.keys
+	}
+	for _, c := range columns {
+		if _, ok := available.get(c); !ok {
+			return "", fmt.Errorf("no column %q; available: %v", c, summarizeKeys(available.keys))
+		}
+	}
+
+	if f.sortBy != "" {
+		col, desc := strings.TrimPrefix(f.sortBy, "-"), strings.HasPrefix(f.sortBy, "-")
+		if _, ok := available.get(col); !ok {
+			return "", fmt.Errorf("can't sort by %q; available: %v", col, summarizeKeys(available.keys))
+		}
+		sort.SliceStable(rows, func(i, j int) bool {
+			a, aOK := rows[i].get(col)
+			b, bOK := rows[j].get(col)
+			switch {
+			case !aOK || !bOK: // missing values sort last either way
+				return aOK && !bOK
+			case desc:
+				return lessJSON(b, a)
+			}
+			return lessJSON(a, b)
+		})
+	}
+
+	var b strings.Builder
+	writeRow := func(cells []string) {
+		b.WriteString("|")
+		for _, c := range cells {
+			b.WriteString(" " + c + " |")
+		}
+		b.WriteString("\n")
+	}
+
+	header, rule := make([]string, len(columns)), make([]string, len(columns))
+	for i, c := range columns {
+		header[i], rule[i] = escapeTableCell(c), "---"
+		if f.alignNumbers && numericColumn(rows, c) {
+			rule[i] = "---:"
+		}
+	}
+	writeRow(header)
+	writeRow(rule)
+	for _, r := range rows {
+		cells := make([]string, len(columns))
+		for i, c := range columns {
+			if v, ok := r.get(c); ok {
+				cells[i] = tableCell(v)
+			}
+		}
+		writeRow(cells)
+	}
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
+
+// numericColumn reports whether every row with a value for col has a number; blanks, as from CSV, are skipped.
+func numericColumn(rows []*jsonObject, col string) bool {
+	seen := false
+	for _, r := range rows {
+		v, ok := r.get(col)
+		if !ok || v == "" {
+			continue
+		}
+		if _, ok := jsonNumber(v); !ok {
+			return false
+		}
+		seen = true
+	}
+	return seen
+}
+
+// tableCell renders scalars as text and anything nested as inline code.
+func tableCell(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return escapeTableCell(v)
+	case *jsonObject, []interface{}:
+		s, err := encodeJSON(v, jsonFormat{compact: true})
+		if err != nil {
+			return ""
+		}
+		return escapeTableCell(inlineCode(s))
+	case nil:
+		return "null"
+	}
+	return escapeTableCell(fmt.Sprint(v))
+}
+
+// inlineCode wraps s in enough backticks to contain any it has.
+func inlineCode(s string) string {
+	fence := "`"
+	for strings.Contains(s, fence) {
+		fence += "`"
+	}
+	if strings.HasPrefix(s, "`") || strings.HasSuffix(s, "`") {
+		s = " " + s + " "
+	}
+	return fence + s + fence
+}
+
+// escapeTableCell keeps content from breaking out of a Markdown table cell.
+func escapeTableCell(s string) string {
+	return strings.NewReplacer("|", `\|`, "\r\n", "<br>", "\n", "<br>").Replace(s)
+}
+
+// lessJSON orders numbers numerically and everything else by its text.
+func lessJSON(a, b interface{}) bool {
+	if af, ok := jsonNumber(a); ok {
+		if bf, ok := jsonNumber(b); ok {
+			return af < bf
+		}
+	}
+	return tableCell(a) < tableCell(b)
+}
+
+func describeJSONValue(v interface{}) string {
+	switch v.(type) {
+	case *jsonObject:
+		return "an object"
+	case []interface{}:
+		return "an array"
+	case string:
+		return "a string"
+	case bool:
+		return "a boolean"
+	case nil:
+		return "null"
+	}
+	return "a number"
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"testing"
+)
+
+func Test_sprintTable(t *testing.T) {
+	const src = `[
+  {"name": "b", "size": 10, "tags": ["x"]},
+  {"name": "a|z", "size": 9, "note": "two\nlines"},
+  {"name": "c", "tags": null}
+]`
+	for _, c := range []struct {
+		name    string
+		columns []string
+		sortBy  string
+		align   bool
+		src     string
+		out     string
+		err     string
+	}{
+		{
+			name: "all columns",
+			out: "| name | size | tags | note |\n" +
+				"| --- | --- | --- | --- |\n" +
+				"| b | 10 | `[\"x\"]` |  |\n" +
+				"| a\\|z | 9 |  | two<br>lines |\n" +
+				"| c |  | null |  |",
+		},
+		{
+			name:    "sorted numerically",
+			columns: []string{"size", "name"},
+			sortBy:  "size",
+			out:     "| size | name |\n| --- | --- |\n| 9 | a\\|z |\n| 10 | b |\n|  | c |",
+		},
+		{
+			name:    "descending",
+			columns: []string{"name"},
+			sortBy:  "-name",
+			out:     "| name |\n| --- |\n| c |\n| b |\n| a\\|z |",
+		},
+		{name: "unknown column", columns: []string{"nope"}, err: `no column "nope"; available: "name", "size", "tags", "note"`},
+		{name: "unknown sort", sortBy: "-nope", err: `can't sort by "nope"; available: "name", "size", "tags", "note"`},
+		{name: "not an array", src: `{"a": 1}`, err: "fmt=table requires an array of objects, not an object"},
+		{name: "not objects", src: `[{"a": 1}, 2]`, err: "fmt=table requires an array of objects, but element 1 is a number"},
+		{name: "empty", src: `[]`, err: "fmt=table requires an array of objects, but the array is empty"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			in := c.src
+			if in == "" {
+				in = src
+			}
+			v, err := decodeRawJSON([]byte(in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, err := sprintTable(v, tableFormat{columns: c.columns, sortBy: c.sortBy, alignNumbers: c.align})
+			if err != nil {
+				if err.Error() != c.err {
+					t.Fatalf("wanted error %q but got %q", c.err, err)
+				}
+				return
+			}
+			if c.err != "" {
+				t.Fatalf("wanted error %q but got none", c.err)
+			}
+			if out != c.out {
+				t.Fatalf("wanted:\n%v\ngot:\n%v", c.out, out)
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package com.example.store;
+
+import java.util.Map;
+
+/** A Store keeps values by key. */
+public class Store<V> {
+    private final Map<String, V> values;
+
+    public Store(Map<String, V> values) {
+        this.values = values;
+    }
+
+    /**
+     * Gets a value.
+     *
+     * @throws IllegalArgumentException if the key is missing
+     */
+    @Override
+    public V get(String key) throws IllegalArgumentException {
+        if (!values.containsKey(key)) {
+            throw new IllegalArgumentException("missing: {" + key + "}");
+        }
+        return values.get(key);
+    }
+
+    interface Listener {
+        void changed(String key);
+    }
+}
@@ -0,0 +1,32 @@
+import { Session } from "./session";
+
+const DEFAULT_TIMEOUT = 30_000
+
+/**
+ * Options configure a Client.
+ */
+export interface Options {
+  baseURL: string;
+  timeout?: number;
+}
+
+export class Client {
+  private session?: Session
+
+  constructor(private readonly opts: Options) {}
+
+  /** connect opens a session, retrying once. */
+  async connect(token: string): Promise<Session> {
+    const url = `${this.opts.baseURL}/sessions?t=${encodeURIComponent(token)}`;
+    // a brace in a string shouldn't end the method: "}"
+    this.session = await open(url, { timeout: this.opts.timeout ?? DEFAULT_TIMEOUT });
+    return this.session;
+  }
+}
+
+@sealed
+export class Admin extends Client {}
+
+export function open(url: string, init: { timeout: number }): Promise<Session> {
+  return fetch(url).then((r) => r.json());
+}
@@ -0,0 +1,21 @@
+use std::fmt;
+
+/// A Token is an opaque credential.
+#[derive(Clone, Debug)]
+pub struct Token<'a> {
+    raw: &'a str,
+}
+
+impl<'a> Token<'a> {
+    /// Returns the token with all but its last four characters masked.
+    pub fn masked(&self) -> String {
+        let keep = self.raw.len().saturating_sub(4);
+        format!("{}{}", "*".repeat(keep), &self.raw[keep..])
+    }
+}
+
+impl fmt::Display for Token<'_> {
+    fn fmt(&self, f: &mut fmt::Formatter) -> fmt::Result {
+        write!(f, r#"Token("{}")"#, self.masked())
+    }
+}
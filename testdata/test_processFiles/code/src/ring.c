@@ -0,0 +1,20 @@
+#include <stdlib.h>
+
+#define RING_MAX 64
+
+/* ring is a fixed-size queue. */
+struct ring {
+	int buf[RING_MAX];
+	size_t head, len;
+};
+
+// ring_push appends v, returning 0 if the ring is full.
+static int
+ring_push(struct ring *r, int v)
+{
+	if (r->len == RING_MAX) {
+		return 0; /* full: '}' */
+	}
+	r->buf[(r->head + r->len++) % RING_MAX] = v;
+	return 1;
+}
@@ -0,0 +1,7 @@
+package link
+
+// Greet returns a greeting for name.
+func Greet(name string) string {
+	greeting := "hello, " + name
+	return greeting
+}
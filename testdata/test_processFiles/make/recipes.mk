@@ -0,0 +1,22 @@
+GOFLAGS := -trimpath
+BIN ::= bin/pullquote
+
+define USAGE
+usage: make build
+  not: a rule
+endef
+
+.PHONY: build
+build: $(BIN)
+
+# build compiles pullquote
+# into bin.
+$(BIN) build: GOFLAGS += -race
+$(BIN) build: $(wildcard *.go) \
+		go.mod
+	@mkdir -p bin
+	go build $(GOFLAGS) \
+		-o $(BIN) .
+
+# the next target's comment
+clean: ; rm -rf bin
@@ -0,0 +1,21 @@
+#!/bin/sh
+
+# main greets everyone named on the command line
+main() {
+  for name in "$@"; do
+    if is_loud; then
+      echo "HELLO, ${name}!"
+    else
+      echo "hello, ${name}"
+    fi
+  done
+}
+
+is_loud() {
+  case "${LOUD}" in
+  1 | yes) ;;
+  *) return 1 ;;
+  esac
+}
+
+main "$@"
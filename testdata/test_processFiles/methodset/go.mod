@@ -0,0 +1,3 @@
+module example.com/methodset
+
+go 1.22
@@ -0,0 +1,28 @@
+package methodset
+
+// Base provides naming.
+type Base struct{}
+
+// Name returns the name. It is stable.
+func (Base) Name() string { return "base" }
+
+// Logger logs.
+type Logger interface {
+	// Logf logs a formatted message.
+	Logf(format string, args ...interface{})
+}
+
+// Server serves things.
+type Server struct {
+	*Base
+	Logger
+	addr string
+}
+
+// Addr returns the listen address.
+func (s Server) Addr() string { return s.addr }
+
+// Close stops the server | quickly.
+func (s *Server) Close() error { return nil }
+
+func (s *Server) reset() {}
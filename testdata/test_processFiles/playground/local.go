@@ -0,0 +1,25 @@
+package playground
+
+import (
+	"fmt"
+	"strings"
+)
+
+const defaultGreeting = "hello"
+
+// greeter builds greetings.
+type greeter struct {
+	greeting string
+}
+
+func (g greeter) String() string {
+	return strings.ToUpper(g.greeting[:1]) + g.greeting[1:]
+}
+
+// Greet greets name.
+func Greet(name string) string {
+	return fmt.Sprintf("%v, %v!", greeter{defaultGreeting}, name)
+}
+
+// Unrelated isn't needed.
+func Unrelated() {}
@@ -0,0 +1,8 @@
+package playground
+
+import "fmt"
+
+func ExampleGreet() {
+	fmt.Println(Greet("world"))
+	// Output: Hello, world!
+}
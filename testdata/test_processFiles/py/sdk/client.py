@@ -0,0 +1,51 @@
+"""A client for the Acme API."""
+
+import functools
+from typing import TYPE_CHECKING
+
+if TYPE_CHECKING:
+    from .session import Session
+
+DEFAULT_TIMEOUT = 30
+
+
+def retry(times: int = 3):
+    """Retries the wrapped call."""
+    def wrap(fn):
+        @functools.wraps(fn)
+        def inner(*args, **kwargs):
+            return fn(*args, **kwargs)
+        return inner
+    return wrap
+
+
+class Client:
+    """Talks to the API.
+
+    Docstrings can mention def and class: neither starts a block.
+    """
+
+    class Options:
+        timeout: int = DEFAULT_TIMEOUT
+
+    def __init__(self, url: str, options: "Client.Options | None" = None):
+        self.url = url
+        self.options = options or Client.Options()
+
+    @retry(times=5)
+    async def connect(
+        self,
+        token: str,
+        *,
+        verify: bool = True,
+    ) -> "Session":
+        """Opens a session.
+
+        Raises ConnectionError if the server is unreachable.
+        """
+        query = {"token": token, "verify": verify}  # sent as JSON
+        return await self._post("/sessions", query)
+
+    # helpers below
+
+    def _post(self, path, body): return self.url + path
@@ -0,0 +1,4 @@
+#!/bin/sh
+# start the worker
+export API_KEY=k-9f8e7d
+exec worker --notify ops.team@example.com
@@ -0,0 +1,9 @@
+-- +migrate Up
+CREATE TABLE users (
+  id SERIAL PRIMARY KEY,
+  email TEXT NOT NULL UNIQUE
+);
+CREATE INDEX users_email ON users (email);
+
+-- +migrate Down
+DROP TABLE users;
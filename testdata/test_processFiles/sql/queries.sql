@@ -0,0 +1,25 @@
+-- name: GetUser :one
+-- GetUser fetches a user by ID.
+SELECT id, email, created_at
+FROM users
+WHERE id = $1;
+
+-- Lists are ordered by creation time.
+-- name: ListUsers :many
+SELECT id, email
+FROM users
+WHERE email LIKE '%;%' -- semicolons in strings don't end statements
+ORDER BY created_at DESC
+LIMIT $1;
+
+-- name: touch-user
+-- yesql-style names work too
+UPDATE users SET updated_at = now() WHERE id = :id
+
+-- name: CreateAuditFunction :exec
+CREATE FUNCTION audit() RETURNS trigger AS $body$
+BEGIN
+  INSERT INTO audit_log VALUES (NEW.*);
+  RETURN NEW;
+END;
+$body$ LANGUAGE plpgsql;
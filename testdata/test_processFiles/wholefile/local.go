@@ -0,0 +1,11 @@
+// Package wholefile is quoted in its entirety.
+package wholefile
+
+import (
+	"fmt"
+)
+
+// fooBar does some stuff
+func fooBar() {
+	fmt.Println("OK COOL")
+}
@@ -0,0 +1,6 @@
+package wholefile
+
+import "strings"
+
+// fooBaz does some other stuff
+var fooBaz = strings.ToUpper("ok cool")
@@ -0,0 +1,3 @@
+module example.com/withimports
+
+go 1.22
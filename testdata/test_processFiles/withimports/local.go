@@ -0,0 +1,23 @@
+package withimports
+
+import (
+	"fmt"
+	"os"
+	str "strings"
+)
+
+// Shout prints loudly.
+func Shout(s string) {
+	fmt.Println(str.ToUpper(s))
+}
+
+// Exit leaves.
+func Exit() {
+	os.Exit(1)
+}
+
+// Report prints and leaves.
+func Report(s string) {
+	fmt.Println(s)
+	os.Exit(1)
+}
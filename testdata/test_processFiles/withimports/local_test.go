@@ -0,0 +1,11 @@
+package withimports_test
+
+import (
+	"testing"
+
+	"example.com/withimports"
+)
+
+func TestShout(t *testing.T) {
+	withimports.Shout("hi")
+}
@@ -0,0 +1,416 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+func expandTOMLQuotes(_ context.Context, pqs []*pullQuote) ([]*expanded, error) {
+	exp := make([]*expanded, 0, len(pqs))
+	for _, pq := range pqs {
+		pat, frag, _ := splitObjPath(pq.objPath) // no fragment quotes the whole file
+
+		src, err := ioutil.ReadFile(pat)
+		if err != nil {
+			return nil, err
+		}
+		var s string
+		if pq.fmt == fmtTable {
+			s, err = tableTOML(string(src), frag, pq)
+		} else {
+			s, err = parseTOML(string(src), frag)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error within %v: %w", pat, err)
+		}
+		exp = append(exp, &expanded{String: s})
+	}
+	return exp, nil
+}
+
+// tomlSpan is a range of lines, [start, end), including any comments directly above.
+type tomlSpan struct {
+	start, end int
+}
+
+type tomlEntry struct {
+	key []string
+	tomlSpan
+}
+
+// tomlSection is the root table or anything under a header. Elements of arrays of tables are addressed with a
+// `[i]` segment in their path.
+type tomlSection struct {
+	path    []string
+	header  bool
+	entries []tomlEntry
+	tomlSpan
+}
+
+// parseTOML selects the table, array of tables element, or key at path, like `tool.poetry.dependencies`,
+// `servers[1]`, or `servers[1].host`, and returns its original text.
+func parseTOML(src, path string) (string, error) {
+	lines := strings.Split(strings.Replace(src, "\r\n", "\n", -1), "\n")
+	if path == "" {
+		return strings.Trim(strings.Join(lines, "\n"), "\n"), nil
+	}
+	want, err := parseTOMLPath(path)
+	if err != nil {
+		return "", err
+	}
+	sections, err := scanTOML(lines)
+	if err != nil {
+		return "", err
+	}
+
+	var spans []tomlSpan
+	for _, s := range sections { // whole tables, arrays of tables, or their elements
+		if s.header && hasPathPrefix(s.path, want) {
+			spans = append(spans, s.tomlSpan)
+		}
+	}
+	if len(spans) == 0 {
+		for _, s := range sections { // keys, including dotted keys which define a table implicitly
+			if !hasPathPrefix(want, s.path) {
+				continue
+			}
+			for _, e := range s.entries {
+				if hasPathPrefix(e.key, want[len(s.path):]) {
+					spans = append(spans, e.tomlSpan)
+				}
+			}
+		}
+	}
+	if len(spans) == 0 {
+		return "", fmt.Errorf("no table or key %q; available: %v", path, summarizeKeys(tomlCandidates(sections, want)))
+	}
+
+	var blocks []string
+	for i, sp := range spans {
+		if i > 0 && spans[i-1].end == sp.start { // contiguous
+			blocks[len(blocks)-1] += "\n" + strings.Join(lines[sp.start:sp.end], "\n")
+			continue
+		}
+		blocks = append(blocks, strings.Join(lines[sp.start:sp.end], "\n"))
+	}
+	return string(dedentSpaces([]byte(strings.TrimRight(strings.Join(blocks, "\n\n"), "\n")))), nil
+}
+
+// tableTOML renders the array of tables, or array of inline tables, at path as a table.
+func tableTOML(src, path string, pq *pullQuote) (string, error) {
+	lines := strings.Split(strings.Replace(src, "\r\n", "\n", -1), "\n")
+	want, err := parseTOMLPath(path)
+	if err != nil {
+		return "", err
+	}
+	sections, err := scanTOML(lines)
+	if err != nil {
+		return "", err
+	}
+	root, err := decodeTOML(lines, sections)
+	if err != nil {
+		return "", err
+	}
+	v, ok := lookupTOML(root, want)
+	if !ok {
+		return "", fmt.Errorf("no table or key %q; available: %v", path, summarizeKeys(tomlCandidates(sections, want)))
+	}
+	return sprintTable(v, pq.tableFmt)
+}
+
+// tomlCandidates lists what's available at the deepest part of want which exists, for error messages.
+func tomlCandidates(sections []tomlSection, want []string) []string {
+	var (
+		seen  = make(map[string]bool)
+		cands []string
+	)
+	add := func(p []string) {
+		if s := formatTOMLPath(p); !seen[s] {
+			seen[s] = true
+			cands = append(cands, s)
+		}
+	}
+	for depth := len(want) - 1; depth >= 0 && len(cands) == 0; depth-- {
+		prefix := want[:depth]
+		for _, s := range sections {
+			if s.header && len(s.path) > depth && hasPathPrefix(s.path, prefix) {
+				add(s.path[:depth+1])
+			}
+			if !hasPathPrefix(prefix, s.path) {
+				continue
+			}
+			for _, e := range s.entries {
+				full := append(append([]string(nil), s.path...), e.key...)
+				if len(full) > depth && hasPathPrefix(full, prefix) {
+					add(full[:depth+1])
+				}
+			}
+		}
+	}
+	return cands
+}
+
+func hasPathPrefix(p, prefix []string) bool {
+	if len(p) < len(prefix) {
+		return false
+	}
+	for i := range prefix {
+		if p[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func formatTOMLPath(p []string) string {
+	var b strings.Builder
+	for i, s := range p {
+		switch {
+		case strings.HasPrefix(s, "["):
+			b.WriteString(s)
+			continue
+		case i > 0:
+			b.WriteByte('.')
+		}
+		if strings.Trim(s, tomlBareKeyChars) != "" || s == "" {
+			s = strconv.Quote(s)
+		}
+		b.WriteString(s)
+	}
+	return b.String()
+}
+
+// parseTOMLPath parses a dotted path of bare or quoted keys, where `[i]` picks an element of an array of tables.
+func parseTOMLPath(path string) ([]string, error) {
+	var (
+		parts []string
+		rest  = path
+	)
+	for rest != "" {
+		if strings.HasPrefix(rest, "[") {
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid path %q: unclosed '['", path)
+			}
+			idx, err := parseArrayIndex(rest[1:end])
+			if err != nil {
+				return nil, fmt.Errorf("invalid path %q: %w", path, err)
+			}
+			parts = append(parts, "["+strconv.Itoa(idx)+"]")
+			rest = strings.TrimPrefix(rest[end+1:], ".")
+			continue
+		}
+		key, n, err := scanTOMLKey(rest)
+		if err != nil {
+			return nil, fmt.Errorf("invalid path %q: %w", path, err)
+		}
+		parts = append(parts, key)
+		rest = rest[n:]
+		if !strings.HasPrefix(rest, "[") {
+			if rest != "" && rest[0] != '.' {
+				return nil, fmt.Errorf("invalid path %q: unexpected %q", path, rest)
+			}
+			rest = strings.TrimPrefix(rest, ".")
+		}
+	}
+	return parts, nil
+}
+
+const tomlBareKeyChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_-"
+
+// scanTOMLKey reads a single bare, basic, or literal key from the start of s, returning it and the bytes consumed.
+func scanTOMLKey(s string) (string, int, error) {
+	switch {
+	case strings.HasPrefix(s, `"`):
+		for i := 1; i < len(s); i++ {
+			switch s[i] {
+			case '\\':
+				i++
+			case '"':
+				key, err := strconv.Unquote(s[:i+1])
+				return key, i + 1, err
+			}
+		}
+		return "", 0, errors.New("unterminated key")
+	case strings.HasPrefix(s, "'"):
+		if end := strings.IndexByte(s[1:], '\''); end >= 0 {
+			return s[1 : end+1], end + 2, nil
+		}
+		return "", 0, errors.New("unterminated key")
+	}
+	n := 0
+	for n < len(s) && strings.IndexByte(tomlBareKeyChars, s[n]) >= 0 {
+		n++
+	}
+	if n == 0 {
+		return "", 0, fmt.Errorf("expected a key at %q", s)
+	}
+	return s[:n], n, nil
+}
+
+// scanTOMLKeys reads a dotted key, like `a."b.c".d`, from the start of s.
+func scanTOMLKeys(s string) ([]string, int, error) {
+	var (
+		keys []string
+		pos  int
+	)
+	for {
+		pos += len(s[pos:]) - len(strings.TrimLeft(s[pos:], " \t"))
+		key, n, err := scanTOMLKey(s[pos:])
+		if err != nil {
+			return nil, 0, err
+		}
+		keys = append(keys, key)
+		pos += n
+		pos += len(s[pos:]) - len(strings.TrimLeft(s[pos:], " \t"))
+		if !strings.HasPrefix(s[pos:], ".") {
+			return keys, pos, nil
+		}
+		pos++
+	}
+}
+
+// scanTOML splits lines into sections and their entries. It understands only as much TOML as is needed to find where
+// values end: strings, multi-line strings, and arrays spanning lines.
+func scanTOML(lines []string) ([]tomlSection, error) {
+	var (
+		sections   = []tomlSection{{}}
+		arrayCount = make(map[string]int)
+		// commentStart is the first line of the run of comments directly above the current line, if any
+		commentStart = -1
+	)
+	closeSection := func(at int) {
+		end := at
+		for end > sections[len(sections)-1].start && strings.TrimSpace(lines[end-1]) == "" {
+			end--
+		}
+		sections[len(sections)-1].end = end
+	}
+
+	for i := 0; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		start := i
+		if commentStart >= 0 {
+			start = commentStart
+		}
+
+		switch {
+		case trimmed == "":
+			commentStart = -1
+			continue
+		case strings.HasPrefix(trimmed, "#"):
+			if commentStart < 0 {
+				commentStart = i
+			}
+			continue
+		case strings.HasPrefix(trimmed, "["):
+			isArray := strings.HasPrefix(trimmed, "[[")
+			inner := strings.TrimPrefix(trimmed, "[")
+			if isArray {
+				inner = strings.TrimPrefix(inner, "[")
+			}
+			keys, n, err := scanTOMLKeys(inner)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+1, err)
+			}
+			if closing := strings.TrimSpace(inner[n:]); !strings.HasPrefix(closing, "]") || (isArray && !strings.HasPrefix(closing, "]]")) {
+				return nil, fmt.Errorf("line %d: invalid table header", i+1)
+			}
+
+			closeSection(start)
+			// within an array of tables, like `[servers.meta]` following `[[servers]]`, headers belong to its last element
+			var path []string
+			for j, k := range keys {
+				path = append(path, k)
+				if n := arrayCount[formatTOMLPath(path)]; n > 0 && j < len(keys)-1 {
+					path = append(path, "["+strconv.Itoa(n-1)+"]")
+				}
+			}
+			if isArray {
+				name := formatTOMLPath(path)
+				path = append(path, "["+strconv.Itoa(arrayCount[name])+"]")
+				arrayCount[name]++
+			}
+			sections = append(sections, tomlSection{path: path, header: true, tomlSpan: tomlSpan{start: start}})
+		default:
+			keys, n, err := scanTOMLKeys(trimmed)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+1, err)
+			}
+			rest := strings.TrimSpace(trimmed[n:])
+			if !strings.HasPrefix(rest, "=") {
+				return nil, fmt.Errorf("line %d: expected '=' after key", i+1)
+			}
+			last, err := tomlValueEnd(lines, i, strings.Index(lines[i], rest)+1)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+1, err)
+			}
+			s := &sections[len(sections)-1]
+			s.entries = append(s.entries, tomlEntry{key: keys, tomlSpan: tomlSpan{start: start, end: last + 1}})
+			i = last
+		}
+		commentStart = -1
+	}
+	closeSection(len(lines))
+	return sections, nil
+}
+
+// tomlValueEnd finds the last line of the value starting at lines[line][col:].
+func tomlValueEnd(lines []string, line, col int) (int, error) {
+	var depth int
+	for l := line; l < len(lines); l, col = l+1, 0 {
+		s := lines[l]
+		for i := col; i < len(s); i++ {
+			switch c := s[i]; {
+			case c == '#':
+				i = len(s)
+			case strings.HasPrefix(s[i:], `"""`), strings.HasPrefix(s[i:], "'''"):
+				delim := s[i : i+3]
+				endL, endC, ok := findTOMLDelim(lines, l, i+3, delim, delim == `"""`)
+				if !ok {
+					return 0, errors.New("unterminated multi-line string")
+				}
+				l, s, i = endL, lines[endL], endC-1
+			case c == '"' || c == '\'':
+				_, endC, ok := findTOMLDelim(lines[l:l+1], 0, i+1, string(c), c == '"')
+				if !ok {
+					return 0, errors.New("unterminated string")
+				}
+				i = endC - 1
+			case c == '[' || c == '{':
+				depth++
+			case c == ']' || c == '}':
+				depth--
+			}
+		}
+		if depth <= 0 {
+			return l, nil
+		}
+	}
+	return 0, errors.New("unterminated array")
+}
+
+// findTOMLDelim finds the end of a string starting at lines[line][col:], returning the position just past delim.
+func findTOMLDelim(lines []string, line, col int, delim string, escapes bool) (int, int, bool) {
+	for l := line; l < len(lines); l, col = l+1, 0 {
+		s := lines[l]
+		for i := col; i < len(s); i++ {
+			if escapes && s[i] == '\\' {
+				i++
+				continue
+			}
+			if strings.HasPrefix(s[i:], delim) {
+				end := i + len(delim)
+				for len(delim) == 3 && end < len(s) && s[end] == delim[0] && end-i < 5 { // up to two quotes may abut
+					end++
+				}
+				return l, end, true
+			}
+		}
+	}
+	return 0, 0, false
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_parseTOMLPath(t *testing.T) {
+	for _, c := range []struct {
+		in      string
+		out     []string
+		wantErr bool
+	}{
+		{"tool.poetry", []string{"tool", "poetry"}, false},
+		{`tool."my.key".x`, []string{"tool", "my.key", "x"}, false},
+		{"'literal key'", []string{"literal key"}, false},
+		{"servers[1].host", []string{"servers", "[1]", "host"}, false},
+		{"servers[1][0]", []string{"servers", "[1]", "[0]"}, false},
+		{"servers[01]", nil, true},
+		{"servers[1", nil, true},
+		{"a..b", nil, true},
+		{`"unterminated`, nil, true},
+	} {
+		t.Run(c.in, func(t *testing.T) {
+			out, err := parseTOMLPath(c.in)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("wantErr %v but %v", c.wantErr, err)
+			}
+			if !reflect.DeepEqual(out, c.out) {
+				t.Errorf("wanted %q but got %q", c.out, out)
+			}
+		})
+	}
+}
+
+func Test_parseTOML(t *testing.T) {
+	const src = `title = "example"
+
+[tool.poetry]
+name = "sdk"
+
+  [tool.poetry.dependencies]
+  python = "^3.9"
+  "requests.x" = { version = "2.0" }
+
+[[bin]]
+name = "a"
+
+[[bin]]
+name = "b"
+`
+	for _, c := range []struct {
+		name, path, out, err string
+	}{
+		{"implicit table", "tool", "[tool.poetry]\nname = \"sdk\"\n\n  [tool.poetry.dependencies]\n  python = \"^3.9\"\n  \"requests.x\" = { version = \"2.0\" }", ""},
+		{"indented table", "tool.poetry.dependencies", "[tool.poetry.dependencies]\npython = \"^3.9\"\n\"requests.x\" = { version = \"2.0\" }", ""},
+		{"quoted key", `tool.poetry.dependencies."requests.x"`, `"requests.x" = { version = "2.0" }`, ""},
+		{"root key", "title", `title = "example"`, ""},
+		{"array element", "bin[1].name", `name = "b"`, ""},
+		{"missing key", "tool.poetry.version", "", `no table or key "tool.poetry.version"; available: "tool.poetry.name", "tool.poetry.dependencies"`},
+		{"missing element", "bin[2]", "", `no table or key "bin[2]"; available: "bin[0]", "bin[1]"`},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			out, err := parseTOML(src, c.path)
+			if err != nil {
+				if err.Error() != c.err {
+					t.Fatalf("wanted error %q but got %q", c.err, err)
+				}
+				return
+			}
+			if c.err != "" {
+				t.Fatalf("wanted error %q but got none", c.err)
+			}
+			if out != c.out {
+				t.Errorf("wanted:\n%v\ngot:\n%v", c.out, out)
+			}
+		})
+	}
+}
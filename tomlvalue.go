@@ -0,0 +1,347 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// decodeTOML builds the same tree decodeJSON does from scanned sections. Integers and floats are kept as json.Numbers;
+// dates and times are kept as strings.
+func decodeTOML(lines []string, sections []tomlSection) (*jsonObject, error) {
+	root := newJSONObject()
+	for _, s := range sections {
+		tbl, err := tomlTable(root, s.path)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range s.entries {
+			line := e.start
+			for trimmed := strings.TrimSpace(lines[line]); trimmed == "" || strings.HasPrefix(trimmed, "#"); {
+				line++
+				trimmed = strings.TrimSpace(lines[line])
+			}
+			text := strings.TrimLeft(lines[line], " \t")
+			_, n, err := scanTOMLKeys(text)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line+1, err)
+			}
+			text = strings.TrimLeft(text[n:], " \t")
+			text = strings.Join(append([]string{strings.TrimPrefix(text, "=")}, lines[line+1:e.end]...), "\n")
+
+			p := tomlValueParser{s: text}
+			v, err := p.parseValue()
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line+1, err)
+			}
+			if err := setTOMLKey(tbl, e.key, v); err != nil {
+				return nil, fmt.Errorf("line %d: %w", line+1, err)
+			}
+		}
+	}
+	return root, nil
+}
+
+// tomlTable finds or creates the table at path, where a `[i]` segment is an element of an array of tables.
+func tomlTable(root *jsonObject, path []string) (*jsonObject, error) {
+	cur := root
+	for i := 0; i < len(path); i++ {
+		k := path[i]
+		next, ok := cur.get(k)
+		if i+1 < len(path) && strings.HasPrefix(path[i+1], "[") {
+			arr, _ := next.([]interface{})
+			idx, _ := strconv.Atoi(strings.Trim(path[i+1], "[]"))
+			if idx == len(arr) {
+				arr = append(arr, newJSONObject())
+				cur.set(k, arr)
+			}
+			if cur, ok = arr[idx].(*jsonObject); !ok {
+				return nil, fmt.Errorf("%v is not an array of tables", formatTOMLPath(path[:i+1]))
+			}
+			i++
+			continue
+		}
+		if !ok {
+			next = newJSONObject()
+			cur.set(k, next)
+		}
+		if cur, ok = next.(*jsonObject); !ok {
+			return nil, fmt.Errorf("%v is not a table", formatTOMLPath(path[:i+1]))
+		}
+	}
+	return cur, nil
+}
+
+func setTOMLKey(tbl *jsonObject, key []string, v interface{}) error {
+	parent, err := tomlTable(tbl, key[:len(key)-1])
+	if err != nil {
+		return err
+	}
+	parent.set(key[len(key)-1], v)
+	return nil
+}
+
+// lookupTOML resolves a path from parseTOMLPath within a decoded document.
+func lookupTOML(root *jsonObject, path []string) (interface{}, bool) {
+	var cur interface{} = root
+	for _, k := range path {
+		switch c := cur.(type) {
+		case *jsonObject:
+			v, ok := c.get(k)
+			if !ok {
+				return nil, false
+			}
+			cur = v
+		case []interface{}:
+			idx, err := strconv.Atoi(strings.Trim(k, "[]"))
+			if !strings.HasPrefix(k, "[") || err != nil || idx >= len(c) {
+				return nil, false
+			}
+			cur = c[idx]
+		default:
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// tomlValueParser parses a single value, ignoring anything after it.
+type tomlValueParser struct {
+	s   string
+	pos int
+}
+
+// skip advances past spaces and, within arrays, newlines and comments.
+func (p *tomlValueParser) skip(multiline bool) {
+	for p.pos < len(p.s) {
+		switch c := p.s[p.pos]; {
+		case c == ' ' || c == '\t':
+			p.pos++
+		case multiline && (c == '\n' || c == '\r'):
+			p.pos++
+		case multiline && c == '#':
+			if i := strings.IndexByte(p.s[p.pos:], '\n'); i >= 0 {
+				p.pos += i
+			} else {
+				p.pos = len(p.s)
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (p *tomlValueParser) parseValue() (interface{}, error) {
+	p.skip(false)
+	rest := p.s[p.pos:]
+	switch {
+	case rest == "":
+		return nil, errors.New("expected a value")
+	case strings.HasPrefix(rest, `"""`), strings.HasPrefix(rest, "'''"):
+		return p.parseMultilineString()
+	case rest[0] == '"':
+		end := 1
+		for ; end < len(rest) && rest[end] != '"'; end++ {
+			if rest[end] == '\\' {
+				end++
+			}
+		}
+		if end >= len(rest) {
+			return nil, errors.New("unterminated string")
+		}
+		p.pos += end + 1
+		return unescapeTOML(rest[1:end])
+	case rest[0] == '\'':
+		end := strings.IndexByte(rest[1:], '\'')
+		if end < 0 {
+			return nil, errors.New("unterminated string")
+		}
+		p.pos += end + 2
+		return rest[1 : end+1], nil
+	case rest[0] == '[':
+		return p.parseArray()
+	case rest[0] == '{':
+		return p.parseInlineTable()
+	}
+
+	end := 0
+	for end < len(rest) && (strings.IndexByte(",]}# \t\r\n", rest[end]) < 0 ||
+		// local date-times may separate the date and time with a space
+		(rest[end] == ' ' && end == 10 && end+1 < len(rest) && rest[end+1] >= '0' && rest[end+1] <= '9')) {
+		end++
+	}
+	tok := rest[:end]
+	p.pos += end
+	switch tok {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "inf", "+inf", "-inf", "nan", "+nan", "-nan": // JSON has no numbers for these, so they're strings
+		return tok, nil
+	case "":
+		return nil, fmt.Errorf("unexpected %q", rest[:1])
+	}
+	if n, ok := tomlNumber(tok); ok {
+		return n, nil
+	}
+	if tok[0] >= '0' && tok[0] <= '9' && strings.ContainsAny(tok, "-:") { // dates and times
+		return tok, nil
+	}
+	return tok, fmt.Errorf("invalid value %q", tok)
+}
+
+// tomlNumber converts TOML integers and finite floats to json.Numbers; parseValue keeps infinities and NaNs as strings.
+func tomlNumber(tok string) (json.Number, bool) {
+	clean := strings.Replace(tok, "_", "", -1)
+	switch {
+	case strings.HasPrefix(clean, "0x"), strings.HasPrefix(clean, "0o"), strings.HasPrefix(clean, "0b"):
+		i, err := strconv.ParseInt(clean, 0, 64)
+		return json.Number(strconv.FormatInt(i, 10)), err == nil
+	case strings.Contains(clean, "inf"), strings.Contains(clean, "nan"):
+		return "", false
+	}
+	if _, err := strconv.ParseFloat(clean, 64); err != nil {
+		return "", false
+	}
+	return json.Number(strings.TrimPrefix(clean, "+")), true
+}
+
+func (p *tomlValueParser) parseMultilineString() (interface{}, error) {
+	delim := p.s[p.pos : p.pos+3]
+	p.pos += 3
+	end := strings.Index(p.s[p.pos:], delim)
+	if end < 0 {
+		return nil, errors.New("unterminated multi-line string")
+	}
+	end += p.pos
+	for extra := 0; extra < 2 && end+3 < len(p.s) && p.s[end+3] == delim[0]; extra++ { // up to two quotes may abut
+		end++
+	}
+	body := p.s[p.pos:end]
+	p.pos = end + 3
+
+	body = strings.TrimPrefix(strings.TrimPrefix(body, "\r"), "\n") // a newline directly after the delimiter is trimmed
+	if delim == "'''" {
+		return body, nil
+	}
+	return unescapeTOML(body)
+}
+
+// unescapeTOML interprets the escapes within a basic string, including multi-line strings' line-ending backslashes.
+func unescapeTOML(s string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i++; i >= len(s) {
+			return "", errors.New("invalid escape at end of string")
+		}
+		switch c := s[i]; c {
+		case 'b':
+			b.WriteByte('\b')
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'f':
+			b.WriteByte('\f')
+		case 'r':
+			b.WriteByte('\r')
+		case 'e':
+			b.WriteByte(0x1b)
+		case '"', '\\':
+			b.WriteByte(c)
+		case 'u', 'U':
+			n := 4
+			if c == 'U' {
+				n = 8
+			}
+			if i+n >= len(s) {
+				return "", fmt.Errorf("invalid escape %q", s[i-1:])
+			}
+			r, err := strconv.ParseUint(s[i+1:i+1+n], 16, 32)
+			if err != nil {
+				return "", fmt.Errorf("invalid escape %q", s[i-1:i+1+n])
+			}
+			b.WriteRune(rune(r))
+			i += n
+		case ' ', '\t', '\r', '\n': // a line-ending backslash trims all whitespace up to the next content
+			rest := strings.TrimLeft(s[i:], " \t")
+			if !strings.HasPrefix(rest, "\n") && !strings.HasPrefix(rest, "\r\n") {
+				return "", fmt.Errorf("invalid escape %q", s[i-1:i+1])
+			}
+			i = len(s) - len(strings.TrimLeft(rest, " \t\r\n")) - 1
+		default:
+			return "", fmt.Errorf("invalid escape %q", s[i-1:i+1])
+		}
+	}
+	return b.String(), nil
+}
+
+func (p *tomlValueParser) parseArray() (interface{}, error) {
+	p.pos++ // '['
+	arr := make([]interface{}, 0)
+	for {
+		p.skip(true)
+		if p.pos < len(p.s) && p.s[p.pos] == ']' {
+			p.pos++
+			return arr, nil
+		}
+		v, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		arr = append(arr, v)
+		p.skip(true)
+		switch {
+		case p.pos >= len(p.s):
+			return nil, errors.New("unterminated array")
+		case p.s[p.pos] == ',':
+			p.pos++
+		case p.s[p.pos] != ']':
+			return nil, fmt.Errorf("unexpected %q in array", p.s[p.pos])
+		}
+	}
+}
+
+func (p *tomlValueParser) parseInlineTable() (interface{}, error) {
+	p.pos++ // '{'
+	tbl := newJSONObject()
+	for {
+		p.skip(false)
+		if p.pos < len(p.s) && p.s[p.pos] == '}' {
+			p.pos++
+			return tbl, nil
+		}
+		key, n, err := scanTOMLKeys(p.s[p.pos:])
+		if err != nil {
+			return nil, err
+		}
+		p.pos += n
+		if p.skip(false); p.pos >= len(p.s) || p.s[p.pos] != '=' {
+			return nil, errors.New("expected '=' in inline table")
+		}
+		p.pos++
+		v, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		if err := setTOMLKey(tbl, key, v); err != nil {
+			return nil, err
+		}
+		p.skip(false)
+		switch {
+		case p.pos >= len(p.s):
+			return nil, errors.New("unterminated inline table")
+		case p.s[p.pos] == ',':
+			p.pos++
+		case p.s[p.pos] != '}':
+			return nil, fmt.Errorf("unexpected %q in inline table", p.s[p.pos])
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_decodeTOML(t *testing.T) {
+	for _, c := range []struct {
+		name, src, out, err string
+	}{
+		{
+			"scalars",
+			"a = 1_000\nb = 0x1F\nc = +1.5e3\nd = true\ne = 1979-05-27 07:32:00Z\nf = 'C:\\dir'\ng = \"tab\\tq\\\"\\u00e9\"",
+			`{"a":1000,"b":31,"c":1.5e3,"d":true,"e":"1979-05-27 07:32:00Z","f":"C:\\dir","g":"tab\tq\"é"}`,
+			"",
+		},
+		{
+			"special floats",
+			"a = inf\nb = +inf\nc = -inf\nd = nan\ne = [-nan, 1]",
+			`{"a":"inf","b":"+inf","c":"-inf","d":"nan","e":["-nan",1]}`,
+			"",
+		},
+		{
+			"multi-line strings",
+			"a = \"\"\"\none \\\n   two\"\"\"\nb = '''\nraw\\n'''",
+			`{"a":"one two","b":"raw\\n"}`,
+			"",
+		},
+		{
+			"tables",
+			"x.y = 1\n[t]\narr = [\n  1, # one\n  2,\n]\ninline = { k.j = 'v' }\n[[p]]\nn = 1\n[[p]]\nn = 2\n[p.q]\nm = 3",
+			`{"x":{"y":1},"t":{"arr":[1,2],"inline":{"k":{"j":"v"}}},"p":[{"n":1},{"n":2,"q":{"m":3}}]}`,
+			"",
+		},
+		{"invalid value", "a = nope", "", "line 1: invalid value \"nope\""},
+		{"invalid escape", `a = "\q"`, "", `line 1: invalid escape "\\q"`},
+		{"missing comma", "a = [1 2]", "", `line 1: unexpected '2' in array`},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			lines := strings.Split(c.src, "\n")
+			sections, err := scanTOML(lines)
+			if err != nil {
+				t.Fatal(err)
+			}
+			root, err := decodeTOML(lines, sections)
+			if err != nil {
+				if err.Error() != c.err {
+					t.Fatalf("wanted error %q but got %q", c.err, err)
+				}
+				return
+			}
+			if c.err != "" {
+				t.Fatalf("wanted error %q but got none", c.err)
+			}
+			out, err := encodeJSON(root, jsonFormat{compact: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out != c.out {
+				t.Errorf("wanted:\n%v\ngot:\n%v", c.out, out)
+			}
+		})
+	}
+}
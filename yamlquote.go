@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+func expandYAMLQuotes(_ context.Context, pqs []*pullQuote) ([]*expanded, error) {
+	exp := make([]*expanded, 0, len(pqs))
+	for _, pq := range pqs {
+		pat, frag, _ := splitObjPath(pq.objPath) // no fragment quotes the first document
+
+		src, err := ioutil.ReadFile(pat)
+		if err != nil {
+			return nil, err
+		}
+		var s string
+		if pq.fmt == fmtTable {
+			s, err = tableYAML(src, frag, pq)
+		} else {
+			s, err = parseYAML(src, frag)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error within %v: %w", pat, err)
+		}
+		exp = append(exp, &expanded{String: s})
+	}
+	return exp, nil
+}
+
+// splitDocIndex splits a fragment like `2/spec/template` into a document index and a JSON pointer.
+func splitDocIndex(frag string) (int, string, error) {
+	i := strings.IndexByte(frag, '/')
+	if i < 0 {
+		i = len(frag)
+	}
+	if i == 0 {
+		return 0, frag, nil
+	}
+	idx, err := parseArrayIndex(frag[:i])
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid document index: %w", err)
+	}
+	return idx, frag[i:], nil
+}
+
+// parseYAML finds the node at frag and returns its original text, dedented, so that comments and formatting survive.
+func parseYAML(src []byte, frag string) (string, error) {
+	node, ownerIndent, err := selectYAML(src, frag)
+	if err != nil {
+		return "", err
+	}
+	return yamlNodeText(src, node, ownerIndent), nil
+}
+
+// tableYAML renders the sequence at frag as a table.
+func tableYAML(src []byte, frag string, pq *pullQuote) (string, error) {
+	node, _, err := selectYAML(src, frag)
+	if err != nil {
+		return "", err
+	}
+	v, err := yamlValue(node)
+	if err != nil {
+		return "", err
+	}
+	return sprintTable(v, pq.tableFmt)
+}
+
+// selectYAML finds the node at frag, along with the indentation of its owner -- its key, or the dash of its sequence
+// entry -- or -1 for a whole document.
+func selectYAML(src []byte, frag string) (*yaml.Node, int, error) {
+	docIdx, ptr, err := splitDocIndex(frag)
+	if err != nil {
+		return nil, 0, err
+	}
+	parts, err := parseJSONPointer(ptr)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(src))
+	var doc yaml.Node
+	for i := 0; i <= docIdx; i++ {
+		if err := dec.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, 0, fmt.Errorf("no document %d; the file has %d", docIdx, i)
+			}
+			return nil, 0, fmt.Errorf("parsing document %d: %w", i, err)
+		}
+	}
+	if len(doc.Content) == 0 {
+		return nil, 0, fmt.Errorf("document %d is empty", docIdx)
+	}
+
+	node, ownerIndent := doc.Content[0], -1
+	for depth, part := range parts {
+		prefix := formatJSONPointer(parts[:depth])
+		switch node.Kind {
+		case yaml.MappingNode:
+			var (
+				keys  []string
+				found bool
+			)
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if k := node.Content[i]; k.Value == part {
+					node, ownerIndent, found = node.Content[i+1], k.Column-1, true
+					break
+				}
+				keys = append(keys, node.Content[i].Value)
+			}
+			if !found {
+				return nil, 0, fmt.Errorf("no key %q at %q; available keys: %v", part, prefix, summarizeKeys(keys))
+			}
+		case yaml.SequenceNode:
+			idx, err := parseArrayIndex(part)
+			if err != nil {
+				return nil, 0, fmt.Errorf("at %q: %w", prefix, err)
+			}
+			if idx >= len(node.Content) {
+				return nil, 0, fmt.Errorf("no index %d at %q; %v", idx, prefix, summarizeIndices(len(node.Content)))
+			}
+			node, ownerIndent = node.Content[idx], node.Column-1
+		case yaml.AliasNode:
+			return nil, 0, fmt.Errorf("can't resolve %q: %q is an alias of %q", part, prefix, node.Value)
+		default:
+			return nil, 0, fmt.Errorf("can't resolve %q: %q is a scalar, not a mapping or sequence", part, prefix)
+		}
+	}
+
+	return node, ownerIndent, nil
+}
+
+// yamlNodeText recovers the original text of a block-style node. It runs from the node's start through every line
+// indented more than the node's owner -- its key, or the dash of its sequence entry -- or, for a sequence at the same
+// indentation as its key, continuing its entries.
+func yamlNodeText(src []byte, node *yaml.Node, ownerIndent int) string {
+	lines := strings.Split(strings.Replace(string(src), "\r\n", "\n", -1), "\n")
+
+	first := lines[node.Line-1]
+	col := node.Column - 1 // yaml.v3 counts columns in runes
+	if runes := []rune(first); col <= len(runes) {
+		col = len(string(runes[:col]))
+	}
+	partial := col > indentOf(first) // e.g. following a key or a dash on the same line
+
+	body := []string{first[col:]}
+	if !partial {
+		body[0] = first
+	}
+	if ownerIndent < 0 { // a whole document keeps any comments above its content
+		start := node.Line - 1
+		for start > 0 && !strings.HasPrefix(lines[start-1], "---") {
+			start--
+		}
+		for start < node.Line-1 && strings.TrimSpace(lines[start]) == "" {
+			start++
+		}
+		body = append(lines[start:node.Line-1:node.Line-1], body...)
+	}
+	for _, l := range lines[node.Line:] {
+		trimmed, ind := strings.TrimSpace(l), indentOf(l)
+		if ind == 0 && (strings.HasPrefix(l, "---") || strings.HasPrefix(l, "...")) { // next document
+			break
+		}
+		if trimmed != "" && ind <= ownerIndent && !(node.Kind == yaml.SequenceNode && ind == col && col == ownerIndent &&
+			(trimmed == "-" || strings.HasPrefix(trimmed, "- ") || strings.HasPrefix(trimmed, "#"))) {
+			break
+		}
+		body = append(body, l)
+	}
+	for len(body) > 1 { // trailing comments at the owner's indentation belong to what follows
+		last := body[len(body)-1]
+		if trimmed := strings.TrimSpace(last); trimmed != "" && !(strings.HasPrefix(trimmed, "#") && indentOf(last) <= ownerIndent) {
+			break
+		}
+		body = body[:len(body)-1]
+	}
+
+	rest := body
+	if partial {
+		rest = body[1:]
+	}
+	dedent := -1
+	for _, l := range rest {
+		if strings.TrimSpace(l) == "" {
+			continue
+		}
+		if ind := indentOf(l); dedent < 0 || ind < dedent {
+			dedent = ind
+		}
+	}
+	if partial && dedent > col {
+		dedent = col
+	}
+	if partial && node.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 && dedent >= 2 {
+		dedent -= 2 // block scalars must be indented beneath their indicator
+	}
+	for i, l := range rest {
+		if strings.TrimSpace(l) == "" {
+			rest[i] = ""
+		} else if dedent > 0 {
+			rest[i] = l[dedent:]
+		}
+	}
+	return strings.TrimRight(strings.Join(body, "\n"), " \t")
+}
+
+func indentOf(l string) int {
+	return len(l) - len(strings.TrimLeft(l, " "))
+}
+
+// yamlValue converts a node to the same tree decodeJSON builds, keeping the order of mappings and numbers as written.
+func yamlValue(node *yaml.Node) (interface{}, error) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			return nil, nil
+		}
+		return yamlValue(node.Content[0])
+	case yaml.AliasNode:
+		return yamlValue(node.Alias)
+	case yaml.MappingNode:
+		obj := newJSONObject()
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			v, err := yamlValue(node.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+			obj.set(node.Content[i].Value, v)
+		}
+		return obj, nil
+	case yaml.SequenceNode:
+		arr := make([]interface{}, 0, len(node.Content))
+		for _, c := range node.Content {
+			v, err := yamlValue(c)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, v)
+		}
+		return arr, nil
+	}
+
+	switch node.ShortTag() {
+	case "!!int":
+		if json.Valid([]byte(node.Value)) {
+			return json.Number(node.Value), nil
+		}
+		// e.g. `0x1F`, `0o17`, or `1_000`
+		var i int64
+		if err := node.Decode(&i); err != nil {
+			var u uint64
+			if node.Decode(&u) != nil {
+				return nil, err
+			}
+			return json.Number(strconv.FormatUint(u, 10)), nil
+		}
+		return json.Number(strconv.FormatInt(i, 10)), nil
+	case "!!float":
+		var f float64
+		if err := node.Decode(&f); err != nil {
+			return nil, err
+		}
+		switch {
+		case math.IsInf(f, 0) || math.IsNaN(f): // JSON has no numbers for these, so they're strings
+			return node.Value, nil
+		case json.Valid([]byte(node.Value)):
+			return json.Number(node.Value), nil
+		}
+		// e.g. `+.5` or `1.`
+		return json.Number(strconv.FormatFloat(f, 'g', -1, 64)), nil
+	case "!!bool":
+		var b bool
+		err := node.Decode(&b)
+		return b, err
+	case "!!null":
+		return nil, nil
+	}
+	return node.Value, nil
+}
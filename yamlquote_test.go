@@ -0,0 +1,93 @@
+package main
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func Test_parseYAML(t *testing.T) {
+	const src = `jobs:
+  test:
+    runs-on: ubuntu-latest
+    steps:
+    - uses: actions/checkout@v4
+    # run the tests
+    - run: go test ./...
+  lint:
+    steps: [a, b]
+paths:
+  /users/{id}:
+    get: {}
+ключ: value
+список:
+- имя: a
+  тип: b
+---
+second: true
+`
+	for _, c := range []struct {
+		name, frag, out, err string
+	}{
+		{"mapping", "/jobs/test", "runs-on: ubuntu-latest\nsteps:\n- uses: actions/checkout@v4\n# run the tests\n- run: go test ./...", ""},
+		{"sequence at key indent", "/jobs/test/steps", "- uses: actions/checkout@v4\n# run the tests\n- run: go test ./...", ""},
+		{"sequence entry", "/jobs/test/steps/1", "run: go test ./...", ""},
+		{"flow", "/jobs/lint/steps", "[a, b]", ""},
+		{"escaped key", "/paths/~1users~1{id}", "get: {}", ""},
+		{"non-ASCII key", "/ключ", "value", ""},
+		{"non-ASCII sequence entry", "/список/0", "имя: a\nтип: b", ""},
+		{"second document", "1", "second: true", ""},
+		{"second document key", "1/second", "true", ""},
+		{"missing key", "/jobs/build", "", `no key "build" at "/jobs"; available keys: "test", "lint"`},
+		{"missing index", "/jobs/test/steps/2", "", `no index 2 at "/jobs/test/steps"; available indices: 0-1`},
+		{"scalar", "/jobs/test/runs-on/os", "", `can't resolve "os": "/jobs/test/runs-on" is a scalar, not a mapping or sequence`},
+		{"missing document", "2/second", "", "no document 2; the file has 2"},
+		{"invalid document", "01/second", "", `invalid document index: invalid array index "01"`},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			out, err := parseYAML([]byte(src), c.frag)
+			if err != nil {
+				if err.Error() != c.err {
+					t.Fatalf("wanted error %q but got %q", c.err, err)
+				}
+				return
+			}
+			if c.err != "" {
+				t.Fatalf("wanted error %q but got none", c.err)
+			}
+			if out != c.out {
+				t.Errorf("wanted:\n%v\ngot:\n%v", c.out, out)
+			}
+		})
+	}
+}
+
+func Test_yamlValue(t *testing.T) {
+	for _, c := range []struct {
+		name, src, out string
+	}{
+		{"decimal", "a: 12\nb: -3\nc: 1.5e3\nd: 0.25", `{"a":12,"b":-3,"c":1.5e3,"d":0.25}`},
+		{"other bases", "a: 0x1F\nb: 0o17\nc: 1_000\nd: +7", `{"a":31,"b":15,"c":1000,"d":7}`},
+		{"unsigned", "a: 0xFFFFFFFFFFFFFFFF", `{"a":18446744073709551615}`},
+		{"other floats", "a: +.5\nb: 1.\nc: -.5e1", `{"a":0.5,"b":1,"c":-5}`},
+		{"special floats", "a: .inf\nb: -.Inf\nc: .nan", `{"a":".inf","b":"-.Inf","c":".nan"}`},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			var node yaml.Node
+			if err := yaml.Unmarshal([]byte(c.src), &node); err != nil {
+				t.Fatal(err)
+			}
+			v, err := yamlValue(&node)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, err := encodeJSON(v, jsonFormat{compact: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out != c.out {
+				t.Errorf("wanted:\n%v\ngot:\n%v", c.out, out)
+			}
+		})
+	}
+}